@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
@@ -12,12 +14,23 @@ import (
 	"github.com/richmondwang/symph-url-shortener/internal/cache"
 	"github.com/richmondwang/symph-url-shortener/internal/db"
 	"github.com/richmondwang/symph-url-shortener/internal/handlers"
+	"github.com/richmondwang/symph-url-shortener/internal/health"
+	"github.com/richmondwang/symph-url-shortener/internal/metadata"
+	"github.com/richmondwang/symph-url-shortener/internal/pages"
+	"github.com/richmondwang/symph-url-shortener/internal/qr"
 	"github.com/richmondwang/symph-url-shortener/internal/router"
+	"github.com/richmondwang/symph-url-shortener/internal/safety"
 	"github.com/richmondwang/symph-url-shortener/internal/services"
+	"github.com/richmondwang/symph-url-shortener/internal/targeting"
 
 	redis "github.com/redis/go-redis/v9"
 )
 
+// @title URL Shortener API
+// @version 1.0.0
+// @description This API allows clients to create and resolve shortened URLs. Users may specify a long URL, an optional custom slug, an optional expiration time and optional UTM parameters. Newly created links are cached in Redis for quick retrieval.
+// @host localhost:8080
+// @BasePath /
 func main() {
 	// Create a root context for initial connection attempts
 	ctx := context.Background()
@@ -38,7 +51,7 @@ func main() {
 		collName = "links"
 	}
 	coll := mongoClient.Database(dbName).Collection(collName)
-	// Ensure unique indexes on slug
+	// Ensure unique indexes on (domain, slug)
 	if err := db.EnsureIndexes(ctx, coll); err != nil {
 		log.Fatalf("failed to create indexes: %v", err)
 	}
@@ -63,13 +76,129 @@ func main() {
 		baseURL = "http://localhost:" + port
 	}
 
+	// Optional directory of branded page templates overriding the
+	// built-in visitor pages (e.g. not_found.html, expired.html)
+	if dir := os.Getenv("PAGES_TEMPLATE_DIR"); dir != "" {
+		if err := pages.LoadDir(dir); err != nil {
+			log.Fatalf("failed to load page templates: %v", err)
+		}
+	}
+
 	// Construct service implementations
 	urlShortenerService := services.NewMongoURLShortenerService(coll)
 	userColl := mongoClient.Database(dbName).Collection("users")
 	userService := services.NewMongoUserService(userColl)
+	domainColl := mongoClient.Database(dbName).Collection("domains")
+	if err := db.EnsureDomainIndexes(ctx, domainColl); err != nil {
+		log.Fatalf("failed to create domain indexes: %v", err)
+	}
+	domainService := services.NewMongoDomainService(domainColl, net.DefaultResolver)
+	clickColl := mongoClient.Database(dbName).Collection("clicks")
+	if err := db.EnsureClickIndexes(ctx, clickColl); err != nil {
+		log.Fatalf("failed to create click indexes: %v", err)
+	}
+	clickService := services.NewMongoClickService(clickColl)
+	reportColl := mongoClient.Database(dbName).Collection("reports")
+	auditColl := mongoClient.Database(dbName).Collection("audit")
+	if err := db.EnsureModerationIndexes(ctx, reportColl, auditColl); err != nil {
+		log.Fatalf("failed to create moderation indexes: %v", err)
+	}
+	moderationService := services.NewMongoModerationService(reportColl, auditColl)
+	folderColl := mongoClient.Database(dbName).Collection("folders")
+	if err := db.EnsureFolderIndexes(ctx, folderColl); err != nil {
+		log.Fatalf("failed to create folder indexes: %v", err)
+	}
+	folderService := services.NewMongoFolderService(folderColl)
+	presetColl := mongoClient.Database(dbName).Collection("utmPresets")
+	vocabColl := mongoClient.Database(dbName).Collection("utmVocabularies")
+	if err := db.EnsureUTMIndexes(ctx, presetColl, vocabColl); err != nil {
+		log.Fatalf("failed to create UTM indexes: %v", err)
+	}
+	utmService := services.NewMongoUTMService(presetColl, vocabColl)
 
 	// Inject services into handler
 	h := handlers.NewHandler(urlShortenerService, userService, baseURL)
+	h.Domains = domainService
+	h.Clicks = clickService
+	h.Moderation = moderationService
+	h.Folders = folderService
+	h.UTM = utmService
+	// Optional GeoIP table ("network,country" CSV) for country targeting
+	if path := os.Getenv("GEOIP_FILE"); path != "" {
+		geo, err := targeting.LoadGeoIP(path)
+		if err != nil {
+			log.Fatalf("failed to load GeoIP file: %v", err)
+		}
+		h.Geo = geo
+	}
+	// Apps served in the default host's app association files
+	defaultApps, err := handlers.ParseDefaultApps(os.Getenv("APPLE_APP_IDS"), os.Getenv("ANDROID_APP_PACKAGE"), os.Getenv("ANDROID_APP_FINGERPRINTS"))
+	if err != nil {
+		log.Fatalf("invalid app association settings: %v", err)
+	}
+	h.DefaultApps = defaultApps
+	// Optional logo drawn in the centre of QR codes
+	if path := os.Getenv("QR_LOGO_FILE"); path != "" {
+		if h.QRLogo, err = qr.LoadLogo(path); err != nil {
+			log.Fatalf("failed to load QR code logo: %v", err)
+		}
+	}
+	// Destination safety checks.  Links back to this shortener count as
+	// shortener destinations so they cannot form redirect loops.
+	safetyOpts := safety.Options{Shorteners: safety.NewDomainList(safety.KnownShorteners...)}
+	if u, err := url.Parse(baseURL); err == nil {
+		safetyOpts.Shorteners.Add(u.Hostname())
+	}
+	if path := os.Getenv("SAFETY_BLOCKLIST_FILE"); path != "" {
+		if safetyOpts.Blocklist, err = safety.LoadDomainList(path); err != nil {
+			log.Fatalf("failed to load safety block list: %v", err)
+		}
+	}
+	if path := os.Getenv("SAFETY_ALLOWLIST_FILE"); path != "" {
+		if safetyOpts.Allowlist, err = safety.LoadDomainList(path); err != nil {
+			log.Fatalf("failed to load safety allow list: %v", err)
+		}
+	}
+	// Redirect-time checks stay offline so redirects are not slowed down
+	if os.Getenv("SAFETY_CHECK_ON_REDIRECT") == "true" {
+		h.RedirectSafety = safety.NewScanner(safetyOpts)
+	}
+	if os.Getenv("SAFETY_NETWORK_CHECKS") != "false" {
+		safetyOpts.Client = metadata.NewClient(metadata.Options{Timeout: 5 * time.Second})
+		safetyOpts.Resolver = net.DefaultResolver
+	}
+	h.Safety = safety.NewScanner(safetyOpts)
+	// Background worker fetching destination titles, icons and OG tags
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	metadataWorker := metadata.NewWorker(metadata.NewFetcher(metadata.Options{}), urlShortenerService, 1000)
+	metadataWorker.Start(workerCtx, 4)
+	// Links created before destination keys existed are keyed once
+	go func() {
+		if n, err := urlShortenerService.BackfillURLKeys(workerCtx); err != nil {
+			log.Printf("warning: failed to backfill destination keys: %v", err)
+		} else if n > 0 {
+			log.Printf("backfilled destination keys of %d links", n)
+		}
+	}()
+	h.Metadata = metadataWorker
+	// Periodic destination health checks
+	healthInterval := time.Hour
+	if v := os.Getenv("HEALTH_CHECK_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Fatalf("invalid HEALTH_CHECK_INTERVAL %q", v)
+		}
+		healthInterval = d
+	}
+	healthDone := make(chan struct{})
+	if healthInterval > 0 {
+		go func() {
+			defer close(healthDone)
+			health.NewChecker(urlShortenerService).Run(workerCtx, healthInterval)
+		}()
+	} else {
+		close(healthDone)
+	}
 	r := router.NewRouter(h)
 	srv := &http.Server{
 		Addr:    ":" + port,
@@ -94,6 +223,9 @@ func main() {
 	if err := srv.Shutdown(ctxShutDown); err != nil {
 		log.Fatalf("server forced to shutdown: %v", err)
 	}
+	stopWorkers()
+	metadataWorker.Wait()
+	<-healthDone
 
 	// Clean up connections
 	if redisClient != nil {
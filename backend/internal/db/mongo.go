@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"errors"
 	"os"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -34,24 +35,135 @@ func Connect(ctx context.Context) (*mongo.Client, error) {
 	return client, nil
 }
 
-// EnsureIndexes creates a unique index on the slug field so that
-// duplicate slugs are rejected by MongoDB.  It should be called
-// once after connecting and obtaining the collection.
+// EnsureIndexes creates a unique compound index on (domain, slug) so
+// that duplicate slugs within the same short domain are rejected by
+// MongoDB while different domains may reuse a slug.  The legacy
+// global unique index on slug is dropped if present.  It should be
+// called once after connecting and obtaining the collection.
 func EnsureIndexes(ctx context.Context, coll *mongo.Collection) error {
-	// Unique index on slug
+	// Drop the pre-domain unique index on slug; ignore "not found"
+	if _, err := coll.Indexes().DropOne(ctx, "slug_1"); err != nil && !isIndexNotFound(err) {
+		return err
+	}
+	// Unique index on (domain, slug)
 	slugIdx := mongo.IndexModel{
-		Keys:    bson.D{{Key: "slug", Value: 1}},
+		Keys:    bson.D{{Key: "domain", Value: 1}, {Key: "slug", Value: 1}},
 		Options: options.Index().SetUnique(true),
 	}
 	// Index on expireAt for fast expiry lookups
 	expireIdx := mongo.IndexModel{
 		Keys: bson.D{{Key: "expireAt", Value: 1}},
 	}
-	// Create both indexes
+	// Indexes on the owner's tags and folders for filtered link lists
+	tagsIdx := mongo.IndexModel{
+		Keys: bson.D{{Key: "createdBy", Value: 1}, {Key: "tags", Value: 1}},
+	}
+	folderIdx := mongo.IndexModel{
+		Keys: bson.D{{Key: "createdBy", Value: 1}, {Key: "folderId", Value: 1}},
+	}
+	// Indexes backing the sort orders and filters of link lists
+	listIdxs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "createdBy", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "createdBy", Value: 1}, {Key: "redirectCount", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "createdBy", Value: 1}, {Key: "expireAt", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "createdBy", Value: 1}, {Key: "health.broken", Value: 1}}},
+		{Keys: bson.D{{Key: "createdBy", Value: 1}, {Key: "urlKey", Value: 1}, {Key: "createdAt", Value: -1}}},
+	}
+	// Full-text search over the owner's links.  Words are not stemmed
+	// so slugs and URL segments match as typed.
+	searchIdx := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "createdBy", Value: 1},
+			{Key: "slug", Value: "text"},
+			{Key: "url", Value: "text"},
+			{Key: "title", Value: "text"},
+			{Key: "notes", Value: "text"},
+		},
+		Options: options.Index().
+			SetName("search").
+			SetDefaultLanguage("none").
+			SetWeights(bson.D{{Key: "slug", Value: 10}, {Key: "title", Value: 5}, {Key: "url", Value: 2}, {Key: "notes", Value: 1}}),
+	}
 	_, err := coll.Indexes().CreateOne(ctx, slugIdx)
 	if err != nil {
 		return err
 	}
-	_, err = coll.Indexes().CreateOne(ctx, expireIdx)
+	_, err = coll.Indexes().CreateMany(ctx, append([]mongo.IndexModel{expireIdx, tagsIdx, folderIdx, searchIdx}, listIdxs...))
+	return err
+}
+
+// EnsureFolderIndexes creates a unique index on (owner, name) so a user
+// cannot have two folders with the same name.
+func EnsureFolderIndexes(ctx context.Context, coll *mongo.Collection) error {
+	nameIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "owner", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err := coll.Indexes().CreateOne(ctx, nameIdx)
+	return err
+}
+
+// EnsureUTMIndexes indexes UTM presets by owner and team, and makes
+// vocabularies unique per owner and team.
+func EnsureUTMIndexes(ctx context.Context, presets, vocabularies *mongo.Collection) error {
+	_, err := presets.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "owner", Value: 1}, {Key: "name", Value: 1}}},
+		{Keys: bson.D{{Key: "team", Value: 1}, {Key: "name", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = vocabularies.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "owner", Value: 1}, {Key: "team", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// EnsureDomainIndexes creates a unique index on the host field of the
+// domains collection so a host can only be claimed once.
+func EnsureDomainIndexes(ctx context.Context, coll *mongo.Collection) error {
+	hostIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "host", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err := coll.Indexes().CreateOne(ctx, hostIdx)
+	return err
+}
+
+// EnsureClickIndexes indexes the clicks collection by link and time so
+// per-link statistics can be aggregated without a collection scan.
+func EnsureClickIndexes(ctx context.Context, coll *mongo.Collection) error {
+	linkIdx := mongo.IndexModel{
+		Keys: bson.D{{Key: "domain", Value: 1}, {Key: "slug", Value: 1}, {Key: "at", Value: 1}},
+	}
+	_, err := coll.Indexes().CreateOne(ctx, linkIdx)
+	return err
+}
+
+// isIndexNotFound reports whether err is MongoDB's IndexNotFound (27)
+// or NamespaceNotFound (26) command error, both of which mean there
+// was nothing to drop.
+func isIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Code == 27 || cmdErr.Code == 26
+	}
+	return false
+}
+
+// EnsureModerationIndexes indexes abuse reports by status and link for
+// the moderation queue, and audit entries by link and time.
+func EnsureModerationIndexes(ctx context.Context, reports, audit *mongo.Collection) error {
+	_, err := reports.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "domain", Value: 1}, {Key: "slug", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = audit.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "domain", Value: 1}, {Key: "slug", Value: 1}, {Key: "at", Value: -1}},
+	})
 	return err
 }
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/pages"
+	"github.com/richmondwang/symph-url-shortener/internal/targeting"
+)
+
+// deepLinkDelayMillis is how long the deep-link page waits for the app
+// to open before sending the visitor to the web destination.
+const deepLinkDelayMillis = 1500
+
+var (
+	// appleAppIDPattern matches "<10-character team ID>.<bundle ID>".
+	appleAppIDPattern = regexp.MustCompile(`^[A-Z0-9]{10}\.[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*$`)
+	// androidPackagePattern matches Java-style package names.
+	androidPackagePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*(\.[A-Za-z][A-Za-z0-9_]*)+$`)
+	// fingerprintPattern matches a colon-separated SHA-256 fingerprint.
+	fingerprintPattern = regexp.MustCompile(`^([0-9A-F]{2}:){31}[0-9A-F]{2}$`)
+)
+
+// blockedDeepLinkSchemes can run code or read local files when opened
+// and are never accepted as app URIs.
+var blockedDeepLinkSchemes = []string{"javascript", "data", "vbscript", "file", "blob", "about"}
+
+// validateDeepLink checks the app URIs of a link.
+func validateDeepLink(d *models.DeepLinkConfig) (string, bool) {
+	if d == nil {
+		return "", true
+	}
+	for _, uri := range []string{d.IOS, d.Android} {
+		if uri == "" {
+			continue
+		}
+		u, err := url.Parse(uri)
+		if err != nil || u.Scheme == "" || len(uri) > 2048 {
+			return "Deep links must be absolute app URIs", false
+		}
+		if containsString(blockedDeepLinkSchemes, strings.ToLower(u.Scheme)) {
+			return "Deep link scheme is not allowed", false
+		}
+	}
+	return "", true
+}
+
+// deepLinkFor returns the app URI for the visitor's platform, or "".
+func deepLinkFor(r *http.Request, link *models.ShortURL) string {
+	if link.DeepLink.IsEmpty() {
+		return ""
+	}
+	switch os, _ := targeting.ParseUserAgent(r.UserAgent()); os {
+	case targeting.OSIOS:
+		return link.DeepLink.IOS
+	case targeting.OSAndroid:
+		return link.DeepLink.Android
+	}
+	return ""
+}
+
+// writeDeepLink serves the page that opens appURL in the installed app
+// and falls back to destination otherwise.
+func writeDeepLink(w http.ResponseWriter, appURL, destination string) {
+	w.Header().Set("Cache-Control", "no-store")
+	_ = pages.Render(w, http.StatusOK, pages.DeepLink, pages.DeepLinkData{
+		AppURL:      template.URL(appURL),
+		URL:         destination,
+		DelayMillis: deepLinkDelayMillis,
+	})
+}
+
+// appleAppSiteAssociation is the apple-app-site-association document.
+type appleAppSiteAssociation struct {
+	AppLinks appLinks `json:"applinks"`
+}
+
+type appLinks struct {
+	Apps    []string         `json:"apps"`
+	Details []appLinkDetails `json:"details"`
+}
+
+type appLinkDetails struct {
+	AppID string   `json:"appID"`
+	Paths []string `json:"paths"`
+}
+
+// assetLink is one statement in assetlinks.json.
+type assetLink struct {
+	Relation []string        `json:"relation"`
+	Target   assetLinkTarget `json:"target"`
+}
+
+type assetLinkTarget struct {
+	Namespace    string   `json:"namespace"`
+	PackageName  string   `json:"package_name"`
+	Fingerprints []string `json:"sha256_cert_fingerprints"`
+}
+
+// AppleAppSiteAssociation serves the iOS universal links file for the requested host
+// @Summary Apple app site association
+// @Description Returns the apple-app-site-association document listing the iOS apps configured for the requested short domain
+// @Tags deeplinks
+// @Produce json
+// @Success 200 {object} appleAppSiteAssociation
+// @Failure 404 {object} map[string]string "Not Found"
+// @Router /.well-known/apple-app-site-association [get]
+func (h *Handler) AppleAppSiteAssociation(w http.ResponseWriter, r *http.Request) {
+	apps, ok := h.requestApps(w, r)
+	if !ok || len(apps.AppleAppIDs) == 0 {
+		writeJSONError(w, http.StatusNotFound, "No iOS apps configured")
+		return
+	}
+	doc := appleAppSiteAssociation{AppLinks: appLinks{Apps: []string{}}}
+	for _, id := range apps.AppleAppIDs {
+		doc.AppLinks.Details = append(doc.AppLinks.Details, appLinkDetails{AppID: id, Paths: []string{"*"}})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(doc)
+}
+
+// AssetLinks serves the Android app links file for the requested host
+// @Summary Android asset links
+// @Description Returns the Digital Asset Links statements for the Android apps configured for the requested short domain
+// @Tags deeplinks
+// @Produce json
+// @Success 200 {array} assetLink
+// @Failure 404 {object} map[string]string "Not Found"
+// @Router /.well-known/assetlinks.json [get]
+func (h *Handler) AssetLinks(w http.ResponseWriter, r *http.Request) {
+	apps, ok := h.requestApps(w, r)
+	if !ok || len(apps.AndroidApps) == 0 {
+		writeJSONError(w, http.StatusNotFound, "No Android apps configured")
+		return
+	}
+	statements := []assetLink{}
+	for _, app := range apps.AndroidApps {
+		statements = append(statements, assetLink{
+			Relation: []string{"delegate_permission/common.handle_all_urls"},
+			Target: assetLinkTarget{
+				Namespace:    "android_app",
+				PackageName:  app.PackageName,
+				Fingerprints: app.Fingerprints,
+			},
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(statements)
+}
+
+// requestApps returns the app association of the requested host: the
+// custom domain's own apps, or DefaultApps for the default host.  ok
+// is false when nothing is configured or the lookup failed.
+func (h *Handler) requestApps(w http.ResponseWriter, r *http.Request) (models.AppAssociation, bool) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	_, domain, err := h.requestDomain(ctx, r)
+	if err != nil {
+		return models.AppAssociation{}, false
+	}
+	if domain != nil {
+		if domain.Apps == nil {
+			return models.AppAssociation{}, false
+		}
+		return *domain.Apps, true
+	}
+	if h.DefaultApps == nil {
+		return models.AppAssociation{}, false
+	}
+	return *h.DefaultApps, true
+}
+
+// UpdateDomainApps sets the mobile apps associated with a custom domain
+// @Summary Set domain app association
+// @Description Sets the iOS app IDs and Android apps served in the domain's apple-app-site-association and assetlinks.json files. Only the domain owner may change them; an empty body clears them.
+// @Tags domains
+// @Accept json
+// @Produce json
+// @Param host path string true "Domain host"
+// @Param request body models.AppAssociation true "App association"
+// @Success 200 {object} models.AppAssociation
+// @Failure 400 {object} map[string]string "Bad Request"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "Not Found"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/domains/{host}/apps [put]
+func (h *Handler) UpdateDomainApps(w http.ResponseWriter, r *http.Request) {
+	if h.Domains == nil {
+		writeJSONError(w, http.StatusNotFound, "Custom domains are not enabled")
+		return
+	}
+	var req models.AppAssociation
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	if msg, ok := normalizeApps(&req); !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	host := normalizeHost(chi.URLParam(r, "host"))
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	domain, err := h.Domains.GetByHost(ctx, host)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if domain == nil {
+		writeJSONError(w, http.StatusNotFound, "Domain not found")
+		return
+	}
+	if domain.Owner != username {
+		writeJSONError(w, http.StatusForbidden, "Only the domain owner can change its apps")
+		return
+	}
+	apps := &req
+	if len(req.AppleAppIDs) == 0 && len(req.AndroidApps) == 0 {
+		apps = nil
+	}
+	if err := h.Domains.UpdateApps(ctx, host, apps); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(req)
+}
+
+// normalizeApps trims and validates an app association in place.
+// Fingerprints are uppercased, as assetlinks.json expects.
+func normalizeApps(apps *models.AppAssociation) (string, bool) {
+	for i, id := range apps.AppleAppIDs {
+		id = strings.TrimSpace(id)
+		if !appleAppIDPattern.MatchString(id) {
+			return "Apple app IDs must look like TEAMID1234.com.example.app", false
+		}
+		apps.AppleAppIDs[i] = id
+	}
+	for i := range apps.AndroidApps {
+		app := &apps.AndroidApps[i]
+		app.PackageName = strings.TrimSpace(app.PackageName)
+		if !androidPackagePattern.MatchString(app.PackageName) {
+			return "Invalid Android package name", false
+		}
+		if len(app.Fingerprints) == 0 {
+			return "Android apps need at least one SHA-256 certificate fingerprint", false
+		}
+		for j, fp := range app.Fingerprints {
+			fp = strings.ToUpper(strings.TrimSpace(fp))
+			if !fingerprintPattern.MatchString(fp) {
+				return "Invalid SHA-256 certificate fingerprint", false
+			}
+			app.Fingerprints[j] = fp
+		}
+	}
+	return "", true
+}
+
+// ParseDefaultApps builds the app association served for the default
+// host from comma-separated configuration values: Apple app IDs, an
+// Android package name and its certificate fingerprints.  It returns
+// nil when nothing is configured.
+func ParseDefaultApps(appleIDs, androidPackage, androidFingerprints string) (*models.AppAssociation, error) {
+	apps := &models.AppAssociation{AppleAppIDs: splitList(appleIDs)}
+	if pkg := strings.TrimSpace(androidPackage); pkg != "" {
+		apps.AndroidApps = []models.AndroidApp{{
+			PackageName:  pkg,
+			Fingerprints: splitList(androidFingerprints),
+		}}
+	}
+	if len(apps.AppleAppIDs) == 0 && len(apps.AndroidApps) == 0 {
+		return nil, nil
+	}
+	if msg, ok := normalizeApps(apps); !ok {
+		return nil, errors.New(msg)
+	}
+	return apps, nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
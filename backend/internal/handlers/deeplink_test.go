@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+const testFingerprint = "14:6D:E9:83:C5:73:06:50:D8:EE:B9:95:2F:34:FC:64:16:A0:83:42:E6:1D:BE:A8:8A:04:96:B2:3F:CF:44:E5"
+
+func TestRedirectHandler_DeepLink(t *testing.T) {
+	link := models.ShortURL{
+		Slug:     "abc12345",
+		URL:      "https://example.com/p/1",
+		DeepLink: &models.DeepLinkConfig{IOS: "myapp://product/1"},
+	}
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return &link, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+
+	req := newRedirectRequest("abc12345")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)")
+	w := httptest.NewRecorder()
+	h.Redirect(w, req)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("expected deep-link page, got %d", w.Result().StatusCode)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "myapp://product/1") || !strings.Contains(body, "example.com/p/1") {
+		t.Errorf("expected app URI and web fallback in page: %s", body)
+	}
+
+	// Android has no app URI configured and desktop never gets the page
+	for _, ua := range []string{
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) Mobile",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64)",
+	} {
+		req := newRedirectRequest("abc12345")
+		req.Header.Set("User-Agent", ua)
+		w := httptest.NewRecorder()
+		h.Redirect(w, req)
+		if w.Result().StatusCode != http.StatusMovedPermanently {
+			t.Errorf("%s: expected plain redirect, got %d", ua, w.Result().StatusCode)
+		}
+	}
+}
+
+func TestValidateDeepLink(t *testing.T) {
+	valid := []*models.DeepLinkConfig{
+		nil,
+		{IOS: "myapp://product/1"},
+		{Android: "intent://product/1#Intent;scheme=myapp;package=com.example;end"},
+		{IOS: "https://example.com/p/1"},
+	}
+	for _, d := range valid {
+		if msg, ok := validateDeepLink(d); !ok {
+			t.Errorf("expected %+v to be valid: %s", d, msg)
+		}
+	}
+	invalid := []*models.DeepLinkConfig{
+		{IOS: "javascript:alert(1)"},
+		{Android: "DATA:text/html,hi"},
+		{IOS: "product/1"},
+	}
+	for _, d := range invalid {
+		if _, ok := validateDeepLink(d); ok {
+			t.Errorf("expected %+v to be rejected", d)
+		}
+	}
+}
+
+func TestAppAssociationFiles(t *testing.T) {
+	h := NewHandler(&mockURLShortener{}, &mockUserService{}, "https://sym.ph")
+	h.Domains = &mockDomainService{domains: map[string]*models.Domain{
+		"go.brand.com": {
+			Host:     "go.brand.com",
+			Verified: true,
+			Apps: &models.AppAssociation{
+				AndroidApps: []models.AndroidApp{{PackageName: "com.brand.app", Fingerprints: []string{testFingerprint}}},
+			},
+		},
+	}}
+	defaults, err := ParseDefaultApps("ABCDE12345.ph.sym.app", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.DefaultApps = defaults
+
+	get := func(handler http.HandlerFunc, host string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/.well-known/x", nil)
+		r.Host = host
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	w := get(h.AppleAppSiteAssociation, "sym.ph")
+	var aasa appleAppSiteAssociation
+	if err := json.NewDecoder(w.Body).Decode(&aasa); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(aasa.AppLinks.Details) != 1 || aasa.AppLinks.Details[0].AppID != "ABCDE12345.ph.sym.app" {
+		t.Errorf("unexpected default AASA: %+v", aasa)
+	}
+	if w := get(h.AssetLinks, "sym.ph"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without default Android apps, got %d", w.Code)
+	}
+
+	w = get(h.AssetLinks, "go.brand.com")
+	var links []assetLink
+	if err := json.NewDecoder(w.Body).Decode(&links); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(links) != 1 || links[0].Target.PackageName != "com.brand.app" || links[0].Target.Fingerprints[0] != testFingerprint {
+		t.Errorf("unexpected assetlinks: %+v", links)
+	}
+	if w := get(h.AppleAppSiteAssociation, "go.brand.com"); w.Code != http.StatusNotFound {
+		t.Errorf("expected custom domain not to inherit default iOS apps, got %d", w.Code)
+	}
+}
+
+func TestUpdateDomainApps(t *testing.T) {
+	domains := &mockDomainService{domains: map[string]*models.Domain{
+		"go.brand.com": {Host: "go.brand.com", Owner: "tester", Verified: true},
+	}}
+	h := NewHandler(&mockURLShortener{}, &mockUserService{}, "https://sym.ph")
+	h.Domains = domains
+	put := func(user, body string) int {
+		r := httptest.NewRequest("PUT", "/api/domains/go.brand.com/apps", bytes.NewBufferString(body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("host", "go.brand.com")
+		ctx := context.WithValue(r.Context(), chi.RouteCtxKey, rctx)
+		r = r.WithContext(context.WithValue(ctx, contextKey("username"), user))
+		w := httptest.NewRecorder()
+		h.UpdateDomainApps(w, r)
+		return w.Code
+	}
+	lower := strings.ToLower(testFingerprint)
+	body := `{"appleAppIds":["ABCDE12345.com.brand.app"],"androidApps":[{"packageName":"com.brand.app","fingerprints":["` + lower + `"]}]}`
+	if code := put("intruder", body); code != http.StatusForbidden {
+		t.Errorf("expected 403 for non-owner, got %d", code)
+	}
+	if code := put("tester", body); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	apps := domains.domains["go.brand.com"].Apps
+	if apps == nil || apps.AndroidApps[0].Fingerprints[0] != testFingerprint {
+		t.Errorf("expected stored apps with uppercased fingerprint, got %+v", apps)
+	}
+	for _, bad := range []string{
+		`{"appleAppIds":["com.brand.app"]}`,
+		`{"androidApps":[{"packageName":"brand","fingerprints":["` + testFingerprint + `"]}]}`,
+		`{"androidApps":[{"packageName":"com.brand.app","fingerprints":["AB:CD"]}]}`,
+	} {
+		if code := put("tester", bad); code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", bad, code)
+		}
+	}
+	if code := put("tester", `{}`); code != http.StatusOK || domains.domains["go.brand.com"].Apps != nil {
+		t.Errorf("expected empty body to clear apps, got %d", code)
+	}
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/services"
+	"github.com/richmondwang/symph-url-shortener/internal/utils"
+)
+
+// createDomainRequest defines the JSON payload for POST /api/domains.
+// Team optionally shares the domain with members of that team.
+type createDomainRequest struct {
+	Host string `json:"host"`
+	Team string `json:"team,omitempty"`
+}
+
+// domainResponse describes a custom domain together with the DNS TXT
+// record its owner must publish to verify it.
+type domainResponse struct {
+	Host        string     `json:"host"`
+	Owner       string     `json:"owner"`
+	Team        string     `json:"team,omitempty"`
+	Verified    bool       `json:"verified"`
+	VerifiedAt  *time.Time `json:"verifiedAt,omitempty"`
+	RecordName  string     `json:"txtRecordName"`
+	RecordValue string     `json:"txtRecordValue"`
+}
+
+type domainsResponse struct {
+	Domains []domainResponse `json:"domains"`
+}
+
+func newDomainResponse(d models.Domain) domainResponse {
+	name, value := services.VerificationRecord(d.Host, d.VerificationToken)
+	return domainResponse{
+		Host:        d.Host,
+		Owner:       d.Owner,
+		Team:        d.Team,
+		Verified:    d.Verified,
+		VerifiedAt:  d.VerifiedAt,
+		RecordName:  name,
+		RecordValue: value,
+	}
+}
+
+// CreateDomain registers a custom short domain for the authenticated user
+// @Summary Add a custom domain
+// @Description Registers a custom short domain owned by the caller (optionally shared with a team). Returns the DNS TXT record that must be published to verify ownership.
+// @Tags domains
+// @Accept json
+// @Produce json
+// @Param request body createDomainRequest true "Domain payload"
+// @Success 201 {object} domainResponse
+// @Failure 400 {object} map[string]string "Bad Request"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 409 {object} map[string]string "Conflict"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/domains [post]
+func (h *Handler) CreateDomain(w http.ResponseWriter, r *http.Request) {
+	if h.Domains == nil {
+		writeJSONError(w, http.StatusNotFound, "Custom domains are not enabled")
+		return
+	}
+	var req createDomainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	host := normalizeHost(req.Host)
+	if msg, ok := validateHost(host); !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if host == h.baseHost() {
+		writeJSONError(w, http.StatusBadRequest, "Domain is already the default short domain")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	team := strings.TrimSpace(req.Team)
+	if team != "" {
+		user, err := h.UserService.GetByUsername(ctx, username)
+		if err != nil || user == nil || !containsString(user.Teams, team) {
+			writeJSONError(w, http.StatusForbidden, "You are not a member of this team")
+			return
+		}
+	}
+	domain := models.Domain{
+		Host:              host,
+		Owner:             username,
+		Team:              team,
+		VerificationToken: utils.GenerateSlug(32),
+		CreatedAt:         time.Now().UTC(),
+	}
+	created, err := h.Domains.Create(ctx, domain)
+	if err != nil {
+		if errors.Is(err, services.ErrDomainExists) {
+			writeJSONError(w, http.StatusConflict, "Domain is already registered")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(newDomainResponse(created))
+}
+
+// ListDomains returns the custom domains the authenticated user can use
+// @Summary List custom domains
+// @Description Returns custom domains owned by the caller or shared with one of the caller's teams
+// @Tags domains
+// @Produce json
+// @Success 200 {object} domainsResponse
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/domains [get]
+func (h *Handler) ListDomains(w http.ResponseWriter, r *http.Request) {
+	if h.Domains == nil {
+		writeJSONError(w, http.StatusNotFound, "Custom domains are not enabled")
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	var teams []string
+	if user, err := h.UserService.GetByUsername(ctx, username); err == nil && user != nil {
+		teams = user.Teams
+	}
+	domains, err := h.Domains.ListByOwner(ctx, username, teams)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	resp := domainsResponse{Domains: []domainResponse{}}
+	for _, d := range domains {
+		resp.Domains = append(resp.Domains, newDomainResponse(d))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// VerifyDomain checks the DNS TXT record for a custom domain
+// @Summary Verify a custom domain
+// @Description Looks up the domain's verification TXT record and marks the domain as verified when the expected token is present
+// @Tags domains
+// @Produce json
+// @Param host path string true "Domain host"
+// @Success 200 {object} domainResponse
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "Not Found"
+// @Failure 422 {object} map[string]string "Verification record not found"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/domains/{host}/verify [post]
+func (h *Handler) VerifyDomain(w http.ResponseWriter, r *http.Request) {
+	if h.Domains == nil {
+		writeJSONError(w, http.StatusNotFound, "Custom domains are not enabled")
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	host := normalizeHost(chi.URLParam(r, "host"))
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	domain, err := h.Domains.GetByHost(ctx, host)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if domain == nil {
+		writeJSONError(w, http.StatusNotFound, "Domain not found")
+		return
+	}
+	if domain.Owner != username {
+		writeJSONError(w, http.StatusForbidden, "Only the domain owner can verify it")
+		return
+	}
+	verified, err := h.Domains.Verify(ctx, host)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDomainVerification):
+			writeJSONError(w, http.StatusUnprocessableEntity, "Verification TXT record not found")
+		case errors.Is(err, services.ErrDomainNotFound):
+			writeJSONError(w, http.StatusNotFound, "Domain not found")
+		default:
+			writeJSONError(w, http.StatusBadGateway, "DNS lookup failed")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(newDomainResponse(*verified))
+}
+
+// domainForUser resolves the domain requested when creating a link.
+// An empty host selects the default domain.  A non-zero status is
+// returned together with an error message when the caller may not use
+// the requested domain.
+func (h *Handler) domainForUser(ctx context.Context, username, requested string) (string, int, string) {
+	host := normalizeHost(requested)
+	if host == "" || host == h.baseHost() {
+		return "", 0, ""
+	}
+	if h.Domains == nil {
+		return "", http.StatusBadRequest, "Custom domains are not enabled"
+	}
+	domain, err := h.Domains.GetByHost(ctx, host)
+	if err != nil {
+		return "", http.StatusInternalServerError, "Database error"
+	}
+	if domain == nil {
+		return "", http.StatusBadRequest, "Unknown domain"
+	}
+	if !h.userCanUseDomain(ctx, username, domain) {
+		return "", http.StatusForbidden, "You do not have access to this domain"
+	}
+	if !domain.Verified {
+		return "", http.StatusBadRequest, "Domain has not been verified"
+	}
+	return domain.Host, 0, ""
+}
+
+// userCanUseDomain reports whether username owns the domain or is a
+// member of the team it is shared with.
+func (h *Handler) userCanUseDomain(ctx context.Context, username string, domain *models.Domain) bool {
+	if domain.Owner == username {
+		return true
+	}
+	if domain.Team == "" {
+		return false
+	}
+	user, err := h.UserService.GetByUsername(ctx, username)
+	if err != nil || user == nil {
+		return false
+	}
+	return containsString(user.Teams, domain.Team)
+}
+
+// requestDomain maps the Host header of an incoming redirect request
+// to a slug namespace and returns the matching domain record.
+// Requests for the default host, or for hosts that are not verified
+// custom domains, use the default namespace and a nil record.
+func (h *Handler) requestDomain(ctx context.Context, r *http.Request) (string, *models.Domain, error) {
+	if h.Domains == nil {
+		return "", nil, nil
+	}
+	host := normalizeHost(r.Host)
+	if host == "" || host == h.baseHost() {
+		return "", nil, nil
+	}
+	domain, err := h.Domains.GetByHost(ctx, host)
+	if err != nil {
+		return "", nil, err
+	}
+	if domain == nil || !domain.Verified {
+		return "", nil, nil
+	}
+	return domain.Host, domain, nil
+}
+
+// shortLink builds the public short link for a slug.  Links on a
+// custom domain reuse the scheme of the configured base URL.
+func (h *Handler) shortLink(domain, slug string) string {
+	base := strings.TrimRight(h.BaseURL, "/")
+	if domain == "" {
+		return base + "/" + slug
+	}
+	scheme := "https"
+	if u, err := url.Parse(base); err == nil && u.Scheme != "" {
+		scheme = u.Scheme
+	}
+	return scheme + "://" + domain + "/" + slug
+}
+
+// baseHost returns the normalised host of the configured base URL.
+func (h *Handler) baseHost() string {
+	u, err := url.Parse(h.BaseURL)
+	if err != nil {
+		return ""
+	}
+	return normalizeHost(u.Host)
+}
+
+// normalizeHost lowercases a host and strips any port and trailing dot.
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+// validateHost checks that host looks like a fully qualified domain name.
+func validateHost(host string) (string, bool) {
+	if host == "" {
+		return "Missing host field", false
+	}
+	if len(host) > 253 || !strings.Contains(host, ".") {
+		return "Host must be a fully qualified domain name", false
+	}
+	if net.ParseIP(host) != nil {
+		return "Host must be a domain name, not an IP address", false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return "Host must be a fully qualified domain name", false
+		}
+		for _, c := range label {
+			if !(('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-') {
+				return "Host must be a fully qualified domain name", false
+			}
+		}
+	}
+	return "", true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+type mockDomainService struct {
+	domains map[string]*models.Domain
+}
+
+func (m *mockDomainService) Create(ctx context.Context, d models.Domain) (models.Domain, error) {
+	m.domains[d.Host] = &d
+	return d, nil
+}
+func (m *mockDomainService) GetByHost(ctx context.Context, host string) (*models.Domain, error) {
+	return m.domains[host], nil
+}
+func (m *mockDomainService) ListByOwner(ctx context.Context, username string, teams []string) ([]models.Domain, error) {
+	var out []models.Domain
+	for _, d := range m.domains {
+		if d.Owner == username || containsString(teams, d.Team) {
+			out = append(out, *d)
+		}
+	}
+	return out, nil
+}
+func (m *mockDomainService) UpdateFallbacks(ctx context.Context, host, expiredURL, notFoundURL string) error {
+	d := m.domains[host]
+	d.ExpiredRedirectURL = expiredURL
+	d.NotFoundRedirectURL = notFoundURL
+	return nil
+}
+func (m *mockDomainService) UpdateApps(ctx context.Context, host string, apps *models.AppAssociation) error {
+	m.domains[host].Apps = apps
+	return nil
+}
+func (m *mockDomainService) Verify(ctx context.Context, host string) (*models.Domain, error) {
+	d := m.domains[host]
+	d.Verified = true
+	return d, nil
+}
+
+func TestShortenHandler_CustomDomain(t *testing.T) {
+	var stored models.ShortURL
+	h := NewHandler(&mockURLShortener{
+		ShortenFunc: func(ctx context.Context, req models.ShortURL) (models.ShortURL, error) {
+			stored = req
+			return req, nil
+		},
+	}, &mockUserService{
+		GetByUsernameFn: func(ctx context.Context, username string) (*models.User, error) {
+			return &models.User{Username: username, Teams: []string{"brand"}}, nil
+		},
+	}, "https://sym.ph")
+	h.Domains = &mockDomainService{domains: map[string]*models.Domain{
+		"go.brand.com":  {Host: "go.brand.com", Owner: "someone", Team: "brand", Verified: true},
+		"new.brand.com": {Host: "new.brand.com", Owner: "tester"},
+	}}
+
+	body := `{"url":"https://example.com","slug":"launch2024","domain":"Go.Brand.com"}`
+	req := httptest.NewRequest("POST", "/api/shorten", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+	w := httptest.NewRecorder()
+	h.Shorten(w, req)
+	if w.Result().StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Result().StatusCode)
+	}
+	var out map[string]interface{}
+	_ = json.NewDecoder(w.Body).Decode(&out)
+	if out["shortLink"] != "https://go.brand.com/launch2024" {
+		t.Errorf("unexpected short link %v", out["shortLink"])
+	}
+	if stored.Domain != "go.brand.com" {
+		t.Errorf("expected domain to be stored, got %q", stored.Domain)
+	}
+
+	// Unverified domains are rejected
+	body = `{"url":"https://example.com","domain":"new.brand.com"}`
+	req = httptest.NewRequest("POST", "/api/shorten", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+	w = httptest.NewRecorder()
+	h.Shorten(w, req)
+	if w.Result().StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for unverified domain, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestRedirectHandler_ResolvesByHost(t *testing.T) {
+	var gotDomain string
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			gotDomain = domain
+			return &models.ShortURL{Domain: domain, Slug: slug, URL: "https://example.com"}, nil
+		},
+	}, &mockUserService{}, "https://sym.ph")
+	h.Domains = &mockDomainService{domains: map[string]*models.Domain{
+		"go.brand.com": {Host: "go.brand.com", Owner: "tester", Verified: true},
+	}}
+	for host, want := range map[string]string{
+		"go.brand.com:443": "go.brand.com",
+		"sym.ph":           "",
+		"unknown.com":      "",
+	} {
+		r := httptest.NewRequest("GET", "/abc12345", nil)
+		r.Host = host
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("slug", "abc12345")
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		h.Redirect(w, r)
+		if w.Result().StatusCode != http.StatusMovedPermanently {
+			t.Errorf("%s: expected 301, got %d", host, w.Result().StatusCode)
+		}
+		if gotDomain != want {
+			t.Errorf("%s: expected domain %q, got %q", host, want, gotDomain)
+		}
+	}
+}
+
+func TestValidateHost(t *testing.T) {
+	for host, valid := range map[string]bool{
+		"go.brand.com":   true,
+		"localhost":      false,
+		"127.0.0.1":      false,
+		"-bad.com":       false,
+		"under_score.io": false,
+		"":               false,
+	} {
+		if _, ok := validateHost(host); ok != valid {
+			t.Errorf("validateHost(%q) = %v, want %v", host, ok, valid)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/pages"
+)
+
+// fallbacksRequest defines the JSON payload for updating the fallback
+// destinations of a user or domain.  Empty values clear a fallback.
+type fallbacksRequest struct {
+	ExpiredRedirectURL  string `json:"expiredRedirectUrl"`
+	NotFoundRedirectURL string `json:"notFoundRedirectUrl"`
+}
+
+// UpdateUserFallbacks sets the caller's default fallback destinations
+// @Summary Set default fallback destinations
+// @Description Sets where visitors are sent when one of the caller's links has expired, or when a slug is not found on a domain the caller owns. Empty values restore the branded default pages.
+// @Tags settings
+// @Accept json
+// @Produce json
+// @Param request body fallbacksRequest true "Fallback URLs"
+// @Success 200 {object} fallbacksRequest
+// @Failure 400 {object} map[string]string "Bad Request"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/settings/fallbacks [put]
+func (h *Handler) UpdateUserFallbacks(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeFallbacks(w, r)
+	if !ok {
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if err := h.UserService.UpdateFallbacks(ctx, username, req.ExpiredRedirectURL, req.NotFoundRedirectURL); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(req)
+}
+
+// UpdateDomainFallbacks sets the fallback destinations of a custom domain
+// @Summary Set domain fallback destinations
+// @Description Sets where visitors are sent when a link on the domain has expired or a slug is not found. Only the domain owner may change them.
+// @Tags domains
+// @Accept json
+// @Produce json
+// @Param host path string true "Domain host"
+// @Param request body fallbacksRequest true "Fallback URLs"
+// @Success 200 {object} fallbacksRequest
+// @Failure 400 {object} map[string]string "Bad Request"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "Not Found"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/domains/{host}/fallbacks [put]
+func (h *Handler) UpdateDomainFallbacks(w http.ResponseWriter, r *http.Request) {
+	if h.Domains == nil {
+		writeJSONError(w, http.StatusNotFound, "Custom domains are not enabled")
+		return
+	}
+	req, ok := decodeFallbacks(w, r)
+	if !ok {
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	host := normalizeHost(chi.URLParam(r, "host"))
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	domain, err := h.Domains.GetByHost(ctx, host)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if domain == nil {
+		writeJSONError(w, http.StatusNotFound, "Domain not found")
+		return
+	}
+	if domain.Owner != username {
+		writeJSONError(w, http.StatusForbidden, "Only the domain owner can change its fallbacks")
+		return
+	}
+	if err := h.Domains.UpdateFallbacks(ctx, host, req.ExpiredRedirectURL, req.NotFoundRedirectURL); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(req)
+}
+
+// decodeFallbacks reads and validates a fallbacksRequest, writing a
+// 400 response and returning false when it is invalid.
+func decodeFallbacks(w http.ResponseWriter, r *http.Request) (fallbacksRequest, bool) {
+	var req fallbacksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return req, false
+	}
+	req.ExpiredRedirectURL = strings.TrimSpace(req.ExpiredRedirectURL)
+	req.NotFoundRedirectURL = strings.TrimSpace(req.NotFoundRedirectURL)
+	for _, u := range []string{req.ExpiredRedirectURL, req.NotFoundRedirectURL} {
+		if u == "" {
+			continue
+		}
+		if msg, ok := validateStaticURL(u); !ok {
+			writeJSONError(w, http.StatusBadRequest, msg)
+			return req, false
+		}
+	}
+	return req, true
+}
+
+// linkExpired answers a request for a link that has expired or run out
+// of clicks.  The visitor is sent to the first fallback configured on
+// the link, its domain or its owner; otherwise a 410 response is
+// rendered as JSON or as the branded HTML page.
+func (h *Handler) linkExpired(ctx context.Context, w http.ResponseWriter, r *http.Request, link *models.ShortURL, domain *models.Domain, msg string) {
+	fallback := link.ExpiredRedirectURL
+	if fallback == "" && domain != nil {
+		fallback = domain.ExpiredRedirectURL
+	}
+	if fallback == "" && link.CreatedBy != "" {
+		if user, err := h.UserService.GetByUsername(ctx, link.CreatedBy); err == nil && user != nil {
+			fallback = user.ExpiredRedirectURL
+		}
+	}
+	if fallback != "" {
+		http.Redirect(w, r, fallback, http.StatusFound)
+		return
+	}
+	writeStatusPage(w, r, http.StatusGone, pages.Expired, msg)
+}
+
+// linkNotFound answers a request for an unknown slug.  On a custom
+// domain the visitor is sent to the domain's fallback, or its owner's;
+// otherwise a 404 response is rendered as JSON or as the branded HTML
+// page.
+func (h *Handler) linkNotFound(ctx context.Context, w http.ResponseWriter, r *http.Request, domain *models.Domain) {
+	if domain != nil {
+		fallback := domain.NotFoundRedirectURL
+		if fallback == "" {
+			if user, err := h.UserService.GetByUsername(ctx, domain.Owner); err == nil && user != nil {
+				fallback = user.NotFoundRedirectURL
+			}
+		}
+		if fallback != "" {
+			http.Redirect(w, r, fallback, http.StatusFound)
+			return
+		}
+	}
+	writeStatusPage(w, r, http.StatusNotFound, pages.NotFound, "The link you followed does not exist.")
+}
+
+// writeStatusPage writes an error for a visitor-facing endpoint,
+// choosing JSON for API clients and the branded page otherwise.
+func writeStatusPage(w http.ResponseWriter, r *http.Request, status int, page, msg string) {
+	if wantsJSON(r) {
+		writeJSONError(w, status, msg)
+		return
+	}
+	data := pages.StatusData{Host: normalizeHost(r.Host), Message: msg}
+	if err := pages.Render(w, status, page, data); err != nil {
+		http.Error(w, msg, status)
+	}
+}
+
+// wantsJSON reports whether the client prefers a JSON response, i.e.
+// it accepts application/json but not text/html.
+func wantsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
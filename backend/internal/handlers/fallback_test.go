@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+func TestRedirectHandler_ExpiredFallbacks(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	link := models.ShortURL{Slug: "expired1", URL: "https://example.com", ExpireAt: &past, CreatedBy: "owner"}
+	owner := &models.User{Username: "owner"}
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return &link, nil
+		},
+	}, &mockUserService{
+		GetByUsernameFn: func(ctx context.Context, username string) (*models.User, error) {
+			return owner, nil
+		},
+	}, "http://localhost")
+
+	// No fallback: branded HTML page
+	w := httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("expired1"))
+	if w.Result().StatusCode != http.StatusGone || !strings.Contains(w.Body.String(), "Link expired") {
+		t.Fatalf("expected branded 410 page, got %d", w.Result().StatusCode)
+	}
+
+	// API clients get JSON
+	r := newRedirectRequest("expired1")
+	r.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	h.Redirect(w, r)
+	if w.Result().StatusCode != http.StatusGone || w.Result().Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("expected JSON 410, got %d %s", w.Result().StatusCode, w.Result().Header.Get("Content-Type"))
+	}
+
+	// Owner default applies, and the link's own fallback wins over it
+	owner.ExpiredRedirectURL = "https://example.com/owner-expired"
+	w = httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("expired1"))
+	if loc := w.Result().Header.Get("Location"); loc != owner.ExpiredRedirectURL {
+		t.Fatalf("expected owner fallback, got %q", loc)
+	}
+	link.ExpiredRedirectURL = "https://example.com/link-expired"
+	w = httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("expired1"))
+	if w.Result().StatusCode != http.StatusFound || w.Result().Header.Get("Location") != link.ExpiredRedirectURL {
+		t.Fatalf("expected link fallback, got %d %q", w.Result().StatusCode, w.Result().Header.Get("Location"))
+	}
+}
+
+func TestRedirectHandler_NotFoundDomainFallback(t *testing.T) {
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return nil, nil
+		},
+	}, &mockUserService{}, "https://sym.ph")
+	h.Domains = &mockDomainService{domains: map[string]*models.Domain{
+		"go.brand.com": {Host: "go.brand.com", Owner: "tester", Verified: true, NotFoundRedirectURL: "https://brand.com/"},
+	}}
+	r := newRedirectRequest("missing1")
+	r.Host = "go.brand.com"
+	w := httptest.NewRecorder()
+	h.Redirect(w, r)
+	if w.Result().StatusCode != http.StatusFound || w.Result().Header.Get("Location") != "https://brand.com/" {
+		t.Fatalf("expected domain fallback, got %d %q", w.Result().StatusCode, w.Result().Header.Get("Location"))
+	}
+
+	// The default domain has no fallback and shows the branded page
+	r = newRedirectRequest("missing1")
+	r.Host = "sym.ph"
+	w = httptest.NewRecorder()
+	h.Redirect(w, r)
+	if w.Result().StatusCode != http.StatusNotFound || !strings.Contains(w.Body.String(), "Link not found") {
+		t.Fatalf("expected branded 404 page, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestUpdateUserFallbacks(t *testing.T) {
+	var gotExpired string
+	h := NewHandler(&mockURLShortener{}, &mockUserService{
+		UpdateFallbackFn: func(ctx context.Context, username, expiredURL, notFoundURL string) error {
+			gotExpired = expiredURL
+			return nil
+		},
+	}, "http://localhost")
+	body := `{"expiredRedirectUrl":" https://example.com/expired ","notFoundRedirectUrl":""}`
+	req := httptest.NewRequest("PUT", "/api/settings/fallbacks", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+	w := httptest.NewRecorder()
+	h.UpdateUserFallbacks(w, req)
+	if w.Result().StatusCode != http.StatusOK || gotExpired != "https://example.com/expired" {
+		t.Fatalf("expected fallbacks to be saved, got %d %q", w.Result().StatusCode, gotExpired)
+	}
+
+	body = `{"expiredRedirectUrl":"javascript:alert(1)"}`
+	req = httptest.NewRequest("PUT", "/api/settings/fallbacks", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+	w = httptest.NewRecorder()
+	h.UpdateUserFallbacks(w, req)
+	if w.Result().StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid fallback URL, got %d", w.Result().StatusCode)
+	}
+}
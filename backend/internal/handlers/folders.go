@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/services"
+)
+
+// Limits on the organising fields of a link, in characters.
+const (
+	maxTitle      = 200
+	maxNotes      = 2000
+	maxTag        = 50
+	maxTags       = 20
+	maxFolderName = 100
+)
+
+// folderRequest is the payload for creating or renaming a folder.
+type folderRequest struct {
+	Name string `json:"name"`
+}
+
+// tagRenameRequest is the payload of POST /api/tags/{tag}/rename.
+type tagRenameRequest struct {
+	Name string `json:"name"`
+}
+
+// tagMergeRequest is the payload of POST /api/tags/merge: every tag in
+// Tags is replaced by Into on the caller's links.
+type tagMergeRequest struct {
+	Tags []string `json:"tags"`
+	Into string   `json:"into"`
+}
+
+// tagsChangedResponse reports how many links a tag operation changed.
+type tagsChangedResponse struct {
+	Tag   string `json:"tag"`
+	Links int64  `json:"links"`
+}
+
+// CreateFolder creates a folder for the caller's links
+// @Summary Create a folder
+// @Description Creates a named folder links can be filed in with the folderId field when shortening. Folder names are unique per user.
+// @Tags folders
+// @Accept json
+// @Produce json
+// @Param request body folderRequest true "Folder name"
+// @Success 201 {object} models.Folder
+// @Failure 400 {object} map[string]string "Bad Request"
+// @Failure 409 {object} map[string]string "Conflict"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/folders [post]
+func (h *Handler) CreateFolder(w http.ResponseWriter, r *http.Request) {
+	if h.Folders == nil {
+		writeJSONError(w, http.StatusNotFound, "Folders are not enabled")
+		return
+	}
+	name, ok := decodeFolderName(w, r)
+	if !ok {
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	folder, err := h.Folders.Create(ctx, models.Folder{Owner: username, Name: name, CreatedAt: h.now()})
+	if err != nil {
+		writeFolderError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(folder)
+}
+
+// ListFolders lists the caller's folders
+// @Summary List folders
+// @Description Lists the caller's folders sorted by name.
+// @Tags folders
+// @Produce json
+// @Success 200 {array} models.Folder
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/folders [get]
+func (h *Handler) ListFolders(w http.ResponseWriter, r *http.Request) {
+	if h.Folders == nil {
+		writeJSONError(w, http.StatusNotFound, "Folders are not enabled")
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	folders, err := h.Folders.List(ctx, username)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(folders)
+}
+
+// RenameFolder renames one of the caller's folders
+// @Summary Rename a folder
+// @Tags folders
+// @Accept json
+// @Produce json
+// @Param id path string true "Folder ID"
+// @Param request body folderRequest true "New name"
+// @Success 200 {object} models.Folder
+// @Failure 400 {object} map[string]string "Bad Request"
+// @Failure 404 {object} map[string]string "Not Found"
+// @Failure 409 {object} map[string]string "Conflict"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/folders/{id} [put]
+func (h *Handler) RenameFolder(w http.ResponseWriter, r *http.Request) {
+	if h.Folders == nil {
+		writeJSONError(w, http.StatusNotFound, "Folders are not enabled")
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Folder not found")
+		return
+	}
+	name, ok := decodeFolderName(w, r)
+	if !ok {
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	folder, err := h.Folders.Rename(ctx, username, id, name)
+	if err != nil {
+		writeFolderError(w, err)
+		return
+	}
+	if folder == nil {
+		writeJSONError(w, http.StatusNotFound, "Folder not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(folder)
+}
+
+// DeleteFolder deletes one of the caller's folders
+// @Summary Delete a folder
+// @Description Deletes the folder.  Its links are kept and no longer belong to a folder.
+// @Tags folders
+// @Param id path string true "Folder ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} map[string]string "Not Found"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/folders/{id} [delete]
+func (h *Handler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
+	if h.Folders == nil {
+		writeJSONError(w, http.StatusNotFound, "Folders are not enabled")
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Folder not found")
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	deleted, err := h.Folders.Delete(ctx, username, id)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !deleted {
+		writeJSONError(w, http.StatusNotFound, "Folder not found")
+		return
+	}
+	if _, err := h.URLShortener.ClearFolder(ctx, username, id); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListTags lists the caller's tags
+// @Summary List tags
+// @Description Lists the tags on the caller's links with the number of links carrying each, most used first.
+// @Tags tags
+// @Produce json
+// @Success 200 {array} models.TagCount
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/tags [get]
+func (h *Handler) ListTags(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	tags, err := h.URLShortener.ListTags(ctx, username)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(tags)
+}
+
+// RenameTag renames a tag on all of the caller's links
+// @Summary Rename a tag
+// @Description Renames the tag on all of the caller's links.  Renaming to a tag already in use merges the two.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param tag path string true "Tag"
+// @Param request body tagRenameRequest true "New name"
+// @Success 200 {object} tagsChangedResponse
+// @Failure 400 {object} map[string]string "Bad Request"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/tags/{tag}/rename [post]
+func (h *Handler) RenameTag(w http.ResponseWriter, r *http.Request) {
+	var req tagRenameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	h.replaceTags(w, r, []string{chi.URLParam(r, "tag")}, req.Name)
+}
+
+// MergeTags merges several tags into one on all of the caller's links
+// @Summary Merge tags
+// @Description Replaces each of the listed tags with the into tag on all of the caller's links.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param request body tagMergeRequest true "Tags to merge"
+// @Success 200 {object} tagsChangedResponse
+// @Failure 400 {object} map[string]string "Bad Request"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/tags/merge [post]
+func (h *Handler) MergeTags(w http.ResponseWriter, r *http.Request) {
+	var req tagMergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	if len(req.Tags) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "tags must list at least one tag")
+		return
+	}
+	h.replaceTags(w, r, req.Tags, req.Into)
+}
+
+// replaceTags validates and applies a tag rename or merge.
+func (h *Handler) replaceTags(w http.ResponseWriter, r *http.Request, from []string, to string) {
+	from, msg, ok := normalizeTags(from, len(from))
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	target, msg, ok := normalizeTags([]string{to}, 1)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if len(target) == 0 || len(from) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "Tag names must not be empty")
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	n, err := h.URLShortener.ReplaceTags(ctx, username, from, target[0])
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(tagsChangedResponse{Tag: target[0], Links: n})
+}
+
+// decodeFolderName reads and validates a folderRequest, writing a 400
+// response and returning false when it is invalid.
+func decodeFolderName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req folderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return "", false
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		writeJSONError(w, http.StatusBadRequest, "Folder name is required")
+		return "", false
+	}
+	if utf8.RuneCountInString(name) > maxFolderName {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Folder name must be at most %d characters", maxFolderName))
+		return "", false
+	}
+	return name, true
+}
+
+// writeFolderError maps a FolderService error to a JSON response.
+func writeFolderError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrFolderExists) {
+		writeJSONError(w, http.StatusConflict, "A folder with this name already exists")
+		return
+	}
+	writeJSONError(w, http.StatusInternalServerError, "Database error")
+}
+
+// normalizeTags trims and lower-cases tags, dropping empty and duplicate
+// ones.  At most limit tags are accepted, and tags may not contain
+// commas so they can be listed in query parameters.
+func normalizeTags(tags []string, limit int) ([]string, string, bool) {
+	var out []string
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxTag {
+			return nil, fmt.Sprintf("Tags must be at most %d characters", maxTag), false
+		}
+		if strings.Contains(tag, ",") {
+			return nil, "Tags must not contain commas", false
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	if len(out) > limit {
+		return nil, fmt.Sprintf("A link can have at most %d tags", limit), false
+	}
+	return out, "", true
+}
+
+// validateNotes checks the title and notes of a link.
+func validateNotes(title, notes string) (string, bool) {
+	if utf8.RuneCountInString(title) > maxTitle {
+		return fmt.Sprintf("title must be at most %d characters", maxTitle), false
+	}
+	if utf8.RuneCountInString(notes) > maxNotes {
+		return fmt.Sprintf("notes must be at most %d characters", maxNotes), false
+	}
+	return "", true
+}
+
+// folderForUser resolves the folderId of a shorten request to one of
+// the user's folders.  It returns a non-zero status and message when
+// the folder cannot be used.
+func (h *Handler) folderForUser(ctx context.Context, username, requested string) (*primitive.ObjectID, int, string) {
+	requested = strings.TrimSpace(requested)
+	if requested == "" {
+		return nil, 0, ""
+	}
+	if h.Folders == nil {
+		return nil, http.StatusBadRequest, "Folders are not enabled"
+	}
+	id, err := primitive.ObjectIDFromHex(requested)
+	if err != nil {
+		return nil, http.StatusBadRequest, "Unknown folder"
+	}
+	folder, err := h.Folders.Get(ctx, username, id)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Database error"
+	}
+	if folder == nil {
+		return nil, http.StatusBadRequest, "Unknown folder"
+	}
+	return &folder.ID, 0, ""
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/services"
+)
+
+type mockFolderService struct {
+	folders []models.Folder
+}
+
+func (m *mockFolderService) Create(ctx context.Context, folder models.Folder) (models.Folder, error) {
+	for _, f := range m.folders {
+		if f.Owner == folder.Owner && f.Name == folder.Name {
+			return folder, services.ErrFolderExists
+		}
+	}
+	folder.ID = primitive.NewObjectID()
+	m.folders = append(m.folders, folder)
+	return folder, nil
+}
+
+func (m *mockFolderService) Get(ctx context.Context, owner string, id primitive.ObjectID) (*models.Folder, error) {
+	for i, f := range m.folders {
+		if f.Owner == owner && f.ID == id {
+			return &m.folders[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockFolderService) List(ctx context.Context, owner string) ([]models.Folder, error) {
+	var out []models.Folder
+	for _, f := range m.folders {
+		if f.Owner == owner {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockFolderService) Rename(ctx context.Context, owner string, id primitive.ObjectID, name string) (*models.Folder, error) {
+	f, _ := m.Get(ctx, owner, id)
+	if f != nil {
+		f.Name = name
+	}
+	return f, nil
+}
+
+func (m *mockFolderService) Delete(ctx context.Context, owner string, id primitive.ObjectID) (bool, error) {
+	for i, f := range m.folders {
+		if f.Owner == owner && f.ID == id {
+			m.folders = append(m.folders[:i], m.folders[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestShortenHandler_Organisation(t *testing.T) {
+	folders := &mockFolderService{}
+	folder, _ := folders.Create(context.Background(), models.Folder{Owner: "tester", Name: "Campaigns"})
+	other, _ := folders.Create(context.Background(), models.Folder{Owner: "someone", Name: "Theirs"})
+	var saved models.ShortURL
+	h := NewHandler(&mockURLShortener{
+		ShortenFunc: func(ctx context.Context, req models.ShortURL) (models.ShortURL, error) {
+			saved = req
+			return req, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	h.Folders = folders
+
+	shorten := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/shorten", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+		w := httptest.NewRecorder()
+		h.Shorten(w, req)
+		return w
+	}
+	w := shorten(`{"url":"https://example.com","title":" Spring sale ","notes":"for the newsletter","tags":["Promo"," promo ","email",""],"folderId":"` + folder.ID.Hex() + `"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if saved.Title != "Spring sale" || saved.Notes != "for the newsletter" {
+		t.Errorf("unexpected title/notes: %q %q", saved.Title, saved.Notes)
+	}
+	if !reflect.DeepEqual(saved.Tags, []string{"promo", "email"}) {
+		t.Errorf("expected normalised tags, got %v", saved.Tags)
+	}
+	if saved.FolderID == nil || *saved.FolderID != folder.ID {
+		t.Errorf("expected folder %s, got %v", folder.ID.Hex(), saved.FolderID)
+	}
+	if info := h.slugInfo(saved); info.Title != "Spring sale" || len(info.Tags) != 2 || info.FolderID == nil {
+		t.Errorf("expected organisation fields in slug info, got %+v", info)
+	}
+
+	for _, body := range []string{
+		`{"url":"https://example.com","folderId":"` + other.ID.Hex() + `"}`,
+		`{"url":"https://example.com","folderId":"nope"}`,
+		`{"url":"https://example.com","tags":["a,b"]}`,
+	} {
+		if w := shorten(body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestFolderHandlers(t *testing.T) {
+	var cleared primitive.ObjectID
+	h := NewHandler(&mockURLShortener{
+		ClearFolderFunc: func(ctx context.Context, username string, folderID primitive.ObjectID) (int64, error) {
+			cleared = folderID
+			return 3, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	folders := &mockFolderService{}
+	h.Folders = folders
+
+	req := withURLParams(httptest.NewRequest("POST", "/api/folders", bytes.NewBufferString(`{"name":"Launch"}`)), "owner", nil)
+	w := httptest.NewRecorder()
+	h.CreateFolder(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created models.Folder
+	_ = json.NewDecoder(w.Body).Decode(&created)
+
+	req = withURLParams(httptest.NewRequest("POST", "/api/folders", bytes.NewBufferString(`{"name":"Launch"}`)), "owner", nil)
+	w = httptest.NewRecorder()
+	h.CreateFolder(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for duplicate name, got %d", w.Code)
+	}
+
+	params := map[string]string{"id": created.ID.Hex()}
+	req = withURLParams(httptest.NewRequest("PUT", "/api/folders/x", bytes.NewBufferString(`{"name":"Launch 2"}`)), "intruder", params)
+	w = httptest.NewRecorder()
+	h.RenameFolder(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 renaming another user's folder, got %d", w.Code)
+	}
+	req = withURLParams(httptest.NewRequest("PUT", "/api/folders/x", bytes.NewBufferString(`{"name":"Launch 2"}`)), "owner", params)
+	w = httptest.NewRecorder()
+	h.RenameFolder(w, req)
+	if w.Code != http.StatusOK || folders.folders[0].Name != "Launch 2" {
+		t.Errorf("expected rename, got %d %+v", w.Code, folders.folders)
+	}
+
+	req = withURLParams(httptest.NewRequest("DELETE", "/api/folders/x", nil), "owner", params)
+	w = httptest.NewRecorder()
+	h.DeleteFolder(w, req)
+	if w.Code != http.StatusNoContent || len(folders.folders) != 0 || cleared != created.ID {
+		t.Errorf("expected folder deleted and links cleared, got %d", w.Code)
+	}
+}
+
+func TestTagHandlers(t *testing.T) {
+	var gotFrom []string
+	var gotTo string
+	h := NewHandler(&mockURLShortener{
+		ReplaceTagsFunc: func(ctx context.Context, username string, from []string, to string) (int64, error) {
+			gotFrom, gotTo = from, to
+			return 4, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+
+	req := withURLParams(httptest.NewRequest("POST", "/api/tags/promo/rename", bytes.NewBufferString(`{"name":"Promotions"}`)), "owner", map[string]string{"tag": "promo"})
+	w := httptest.NewRecorder()
+	h.RenameTag(w, req)
+	if w.Code != http.StatusOK || !reflect.DeepEqual(gotFrom, []string{"promo"}) || gotTo != "promotions" {
+		t.Fatalf("unexpected rename: %d %v -> %q", w.Code, gotFrom, gotTo)
+	}
+	var resp tagsChangedResponse
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Links != 4 {
+		t.Errorf("expected 4 links changed, got %d", resp.Links)
+	}
+
+	req = withURLParams(httptest.NewRequest("POST", "/api/tags/merge", bytes.NewBufferString(`{"tags":["ads","Ads","social"],"into":"marketing"}`)), "owner", nil)
+	w = httptest.NewRecorder()
+	h.MergeTags(w, req)
+	if w.Code != http.StatusOK || !reflect.DeepEqual(gotFrom, []string{"ads", "social"}) || gotTo != "marketing" {
+		t.Errorf("unexpected merge: %d %v -> %q", w.Code, gotFrom, gotTo)
+	}
+
+	req = withURLParams(httptest.NewRequest("POST", "/api/tags/merge", bytes.NewBufferString(`{"tags":["ads"],"into":" "}`)), "owner", nil)
+	w = httptest.NewRecorder()
+	h.MergeTags(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for empty target, got %d", w.Code)
+	}
+}
@@ -3,18 +3,28 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
+	"github.com/richmondwang/symph-url-shortener/internal/metadata"
 	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/pages"
+	"github.com/richmondwang/symph-url-shortener/internal/ratelimit"
+	"github.com/richmondwang/symph-url-shortener/internal/safety"
 	"github.com/richmondwang/symph-url-shortener/internal/services"
+	"github.com/richmondwang/symph-url-shortener/internal/targeting"
+	"github.com/richmondwang/symph-url-shortener/internal/urltemplate"
 	"github.com/richmondwang/symph-url-shortener/internal/utils"
 )
 
@@ -28,39 +38,151 @@ func writeJSONError(w http.ResponseWriter, status int, msg string) {
 // contextKey is a custom type for context keys to avoid collisions
 type contextKey string
 
-// Handler uses service interfaces for business logic
+// Handler uses service interfaces for business logic.  Domains is
+// optional; when nil, custom domains are disabled and every link is
+// served from BaseURL.  Clicks is optional; when set, clicks on
+// tracked links are recorded for the stats API.  Geo, when set,
+// resolves client countries for targeting rules.  DefaultApps lists
+// the mobile apps associated with the default host.  Metadata, when
+// set, queues new links for background destination metadata fetching.
+// Now supplies the current time for expiry and
+// activation checks; it defaults to time.Now and may be replaced in
+// tests.
 type Handler struct {
 	URLShortener services.URLShortenerService
 	UserService  services.UserService
+	Domains      services.DomainService
+	Clicks       services.ClickService
+	Geo          *targeting.GeoIP
+	DefaultApps  *models.AppAssociation
+	Metadata     metadata.Enqueuer
 	BaseURL      string
+	Now          func() time.Time
+
+	// Safety vets destinations when links are created; RedirectSafety,
+	// when set, re-checks them on every redirect.
+	Safety         *safety.Scanner
+	RedirectSafety *safety.Scanner
+	// Moderation stores abuse reports and the audit trail; reporting
+	// and the admin API are disabled when it is nil.
+	Moderation services.ModerationService
+	// Folders stores the folders users file links in; folders are
+	// disabled when it is nil.
+	Folders services.FolderService
+	// UTM stores UTM presets and vocabularies; presets and vocabulary
+	// enforcement are disabled when it is nil.
+	UTM services.UTMService
+	// QRLogo is drawn in the centre of QR codes requested with logo=true.
+	QRLogo image.Image
+
+	unlockLimiter *ratelimit.Limiter
+	reportLimiter *ratelimit.Limiter
 }
 
 // NewHandler constructs a new Handler with injected services and base URL
 func NewHandler(urlShortener services.URLShortenerService, userService services.UserService, baseURL string) *Handler {
-	return &Handler{URLShortener: urlShortener, UserService: userService, BaseURL: baseURL}
+	return &Handler{
+		URLShortener:  urlShortener,
+		UserService:   userService,
+		BaseURL:       baseURL,
+		unlockLimiter: ratelimit.New(maxUnlockFailures, unlockFailureWindow),
+		reportLimiter: ratelimit.New(maxReports, reportWindow),
+	}
+}
+
+// now returns the current UTC time from the injected clock.
+func (h *Handler) now() time.Time {
+	if h.Now != nil {
+		return h.Now().UTC()
+	}
+	return time.Now().UTC()
 }
 
 // shortenRequest defines the expected JSON payload for the POST
 // /api/shorten endpoint.  The URL field is mandatory; slug and
 // expiration are optional.  UTM parameters are accepted as a map of
-// strings.  All fields use json tags for proper decoding.
+// strings; PresetID fills in the values of a saved UTM preset and
+// Params adds custom, non-UTM tracking parameters.  Domain selects a
+// verified custom domain owned by the caller; empty means the default
+// BASE_URL host.  ForwardPath, ForwardQuery and QueryPrecedence control
+// how trailing paths and incoming query parameters are passed on to the
+// destination.
+// RedirectType picks the redirect status or page (301, 302, 307, 308,
+// meta or js) and Pixels attaches retargeting pixels.  Password, when
+// set, must be entered by visitors before they are redirected.
+// MaxClicks limits the number of redirects (1 for a single-use link).
+// ActivateAt delays the link until the given time, optionally showing
+// a holding page, and Schedule lists future destination changes.
+// ExpiredRedirectURL receives visitors once the link has expired.
+// Destinations turns the link into a weighted A/B split; such links
+// always track clicks so variants can be compared.  Rules routes
+// visitors by OS, device, country, language or time of day.  DeepLink
+// opens the mobile app on iOS and Android when installed.  Preview
+// sets the title, description and image shown to social crawlers.
+// PrivateInfo hides the public "/{slug}+" info page, and ShowOwner
+// names the owner on it.  All fields use json tags for proper decoding.
 type shortenRequest struct {
 	URL         string            `json:"url"`
 	Slug        string            `json:"slug,omitempty"`
+	Domain      string            `json:"domain,omitempty"`
 	Expiration  string            `json:"expiration,omitempty"`
 	UTMs        map[string]string `json:"utms,omitempty"`
+	UTMPolicy   string            `json:"utmPolicy,omitempty"`
+	PresetID    string            `json:"presetId,omitempty"`
+	Params      map[string]string `json:"params,omitempty"`
 	TrackClicks bool              `json:"trackClicks,omitempty"`
+
+	ForwardPath     bool   `json:"forwardPath,omitempty"`
+	ForwardQuery    bool   `json:"forwardQuery,omitempty"`
+	QueryPrecedence string `json:"queryPrecedence,omitempty"`
+	RedirectType    string `json:"redirectType,omitempty"`
+
+	Pixels    *models.PixelConfig `json:"pixels,omitempty"`
+	Password  string              `json:"password,omitempty"`
+	MaxClicks int                 `json:"maxClicks,omitempty"`
+
+	ActivateAt  string            `json:"activateAt,omitempty"`
+	HoldingPage bool              `json:"holdingPage,omitempty"`
+	Schedule    []scheduleRequest `json:"schedule,omitempty"`
+
+	ExpiredRedirectURL string `json:"expiredRedirectUrl,omitempty"`
+
+	Destinations []destinationRequest   `json:"destinations,omitempty"`
+	Rules        []models.TargetingRule `json:"rules,omitempty"`
+
+	DeepLink *models.DeepLinkConfig `json:"deepLink,omitempty"`
+	Preview  *models.PreviewMeta    `json:"preview,omitempty"`
+
+	PrivateInfo bool `json:"privateInfo,omitempty"`
+	ShowOwner   bool `json:"showOwner,omitempty"`
+
+	Title    string   `json:"title,omitempty"`
+	Notes    string   `json:"notes,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	FolderID string   `json:"folderId,omitempty"`
+
+	ReuseExisting bool `json:"reuseExisting,omitempty"`
+}
+
+// scheduleRequest describes a future destination change: from At
+// (ISO-8601 / RFC3339) onwards the link redirects to URL.  UTM
+// parameters from the request are applied to URL as well.
+type scheduleRequest struct {
+	At  string `json:"at"`
+	URL string `json:"url"`
 }
 
 // shortenResponse defines the JSON structure returned by the
 // /api/shorten endpoint.  It exposes the slug, the complete short
-// link (including the configured base URL), the destination URL and
-// optional expiration.
+// link (built from the link's domain, or the configured base URL),
+// the destination URL and optional expiration.  Reused is set when an
+// existing link was returned instead of a new one.
 type shortenResponse struct {
 	Slug      string     `json:"slug"`
 	ShortLink string     `json:"shortLink"`
 	URL       string     `json:"destination"`
 	ExpireAt  *time.Time `json:"expiration,omitempty"`
+	Reused    bool       `json:"reused,omitempty"`
 }
 
 // registerRequest defines the expected JSON payload for registration
@@ -81,23 +203,120 @@ type loginResponse struct {
 }
 
 type SlugInfo struct {
-	Slug          string            `json:"slug"`
-	ShortLink     string            `json:"shortLink"`
-	Destination   string            `json:"destination"`
-	ExpireAt      *time.Time        `json:"expiration,omitempty"`
-	RedirectCount int64             `json:"redirectCount,omitempty"`
-	UTMs          map[string]string `json:"utms,omitempty"`
-	TrackClicks   bool              `json:"trackClicks,omitempty"`
+	Slug          string              `json:"slug"`
+	Domain        string              `json:"domain,omitempty"`
+	ShortLink     string              `json:"shortLink"`
+	Destination   string              `json:"destination"`
+	BaseURL       string              `json:"baseUrl,omitempty"`
+	ExpireAt      *time.Time          `json:"expiration,omitempty"`
+	RedirectCount int64               `json:"redirectCount,omitempty"`
+	UTMs          map[string]string   `json:"utms,omitempty"`
+	UTMPolicy     string              `json:"utmPolicy,omitempty"`
+	Params        map[string]string   `json:"params,omitempty"`
+	UTMPresetID   *primitive.ObjectID `json:"utmPresetId,omitempty"`
+	TrackClicks   bool                `json:"trackClicks,omitempty"`
+
+	ForwardPath     bool   `json:"forwardPath,omitempty"`
+	ForwardQuery    bool   `json:"forwardQuery,omitempty"`
+	QueryPrecedence string `json:"queryPrecedence,omitempty"`
+	RedirectType    string `json:"redirectType,omitempty"`
+
+	Pixels            *models.PixelConfig `json:"pixels,omitempty"`
+	PasswordProtected bool                `json:"passwordProtected,omitempty"`
+	MaxClicks         int                 `json:"maxClicks,omitempty"`
+
+	ActivateAt  *time.Time                    `json:"activateAt,omitempty"`
+	HoldingPage bool                          `json:"holdingPage,omitempty"`
+	Schedule    []models.ScheduledDestination `json:"schedule,omitempty"`
+
+	ExpiredRedirectURL string `json:"expiredRedirectUrl,omitempty"`
+
+	Destinations []models.Destination   `json:"destinations,omitempty"`
+	Rules        []models.TargetingRule `json:"rules,omitempty"`
+
+	DeepLink *models.DeepLinkConfig `json:"deepLink,omitempty"`
+	Preview  *models.PreviewMeta    `json:"preview,omitempty"`
+
+	Metadata *models.LinkMetadata `json:"metadata,omitempty"`
+	Health   *models.LinkHealth   `json:"health,omitempty"`
+
+	Flag     *models.SafetyFlag `json:"flag,omitempty"`
+	Disabled bool               `json:"disabled,omitempty"`
+
+	PrivateInfo bool `json:"privateInfo,omitempty"`
+	ShowOwner   bool `json:"showOwner,omitempty"`
+
+	Title    string              `json:"title,omitempty"`
+	Notes    string              `json:"notes,omitempty"`
+	Tags     []string            `json:"tags,omitempty"`
+	FolderID *primitive.ObjectID `json:"folderId,omitempty"`
 }
 
-// SlugsResponse for frontend
+// SlugsResponse for frontend.  NextCursor continues the listing when
+// more links follow, and Total is only set when requested.
 type slugsResponse struct {
-	Slugs []SlugInfo `json:"slugs"`
+	Slugs      []SlugInfo `json:"slugs"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+	Total      *int64     `json:"total,omitempty"`
+}
+
+// slugInfo converts a stored link to its API representation.
+func (h *Handler) slugInfo(s models.ShortURL) SlugInfo {
+	return SlugInfo{
+		Slug:          s.Slug,
+		Domain:        s.Domain,
+		ShortLink:     h.shortLink(s.Domain, s.Slug),
+		Destination:   s.URL,
+		BaseURL:       s.BaseURL,
+		ExpireAt:      s.ExpireAt,
+		UTMs:          s.UTMs,
+		UTMPolicy:     s.UTMPolicy,
+		Params:        s.Params,
+		UTMPresetID:   s.UTMPresetID,
+		RedirectCount: int64(s.RedirectCount),
+		TrackClicks:   s.TrackClicks,
+
+		ForwardPath:     s.ForwardPath,
+		ForwardQuery:    s.ForwardQuery,
+		QueryPrecedence: s.QueryPrecedence,
+		RedirectType:    s.RedirectType,
+
+		Pixels:            s.Pixels,
+		PasswordProtected: s.PasswordHash != "",
+		MaxClicks:         s.MaxClicks,
+
+		ActivateAt:  s.ActivateAt,
+		HoldingPage: s.HoldingPage,
+		Schedule:    s.Schedule,
+
+		ExpiredRedirectURL: s.ExpiredRedirectURL,
+
+		Destinations: s.Destinations,
+		Rules:        s.Rules,
+
+		DeepLink: s.DeepLink,
+		Preview:  s.Preview,
+
+		Metadata: s.Metadata,
+		Health:   s.Health,
+
+		Flag:     s.Flag,
+		Disabled: s.Disabled,
+
+		PrivateInfo: s.PrivateInfo,
+		ShowOwner:   s.ShowOwner,
+
+		Title:    s.Title,
+		Notes:    s.Notes,
+		Tags:     s.Tags,
+		FolderID: s.FolderID,
+	}
 }
 
 // CheckSlugRequest and CheckSlugResponse for slug availability
 type checkSlugRequest struct {
-	Slug string `json:"slug"`
+	Slug   string `json:"slug"`
+	Domain string `json:"domain,omitempty"`
 }
 
 type checkSlugResponse struct {
@@ -135,13 +354,15 @@ func JWTAuthMiddleware(next http.Handler) http.Handler {
 
 // Shorten accepts a JSON body describing the URL to be shortened.
 // @Summary Shorten a URL
-// @Description Create a shortened URL with optional custom slug, expiration and UTM parameters. Returns the generated slug, the full short link and the destination URL with UTM parameters appended.
+// @Description Create a shortened URL with optional custom slug, expiration and UTM parameters. Returns the generated slug, the full short link and the destination URL with UTM parameters appended. With reuseExisting, the caller's newest live link to the same destination on the same domain is returned (200, reused=true) instead of creating a new one; 409 when that link lacks the requested slug, password, maxClicks, expiration, rules or destinations.
 // @Tags shorten
 // @Accept json
 // @Produce json
 // @Param request body shortenRequest true "URL payload"
 // @Success 201 {object} shortenResponse
+// @Success 200 {object} shortenResponse "Existing link reused"
 // @Failure 400 {object} map[string]string "Bad Request"
+// @Failure 409 {object} map[string]string "Existing link has different settings"
 // @Failure 500 {object} map[string]string "Internal Server Error"
 // @Router /api/shorten [post]
 func (h *Handler) Shorten(w http.ResponseWriter, r *http.Request) {
@@ -156,7 +377,123 @@ func (h *Handler) Shorten(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, http.StatusBadRequest, msg)
 		return
 	}
-	destination := utils.ComposeDestination(urlStr, req.UTMs)
+	precedence := strings.TrimSpace(req.QueryPrecedence)
+	if msg, ok := validateQueryPrecedence(precedence); !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	utmPolicy := strings.TrimSpace(req.UTMPolicy)
+	if msg, ok := validateUTMPolicy(utmPolicy); !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	redirectType := strings.TrimSpace(req.RedirectType)
+	if msg, ok := validateRedirectType(redirectType); !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if msg, ok := validatePixels(req.Pixels); !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	pixels := req.Pixels
+	if pixels.IsEmpty() {
+		pixels = nil
+	}
+	if msg, ok := validateDeepLink(req.DeepLink); !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	deepLink := req.DeepLink
+	if deepLink.IsEmpty() {
+		deepLink = nil
+	}
+	if msg, ok := validatePreview(req.Preview); !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	preview := req.Preview
+	if preview.IsEmpty() {
+		preview = nil
+	}
+	if req.MaxClicks < 0 {
+		writeJSONError(w, http.StatusBadRequest, "maxClicks must not be negative")
+		return
+	}
+	var activateAt *time.Time
+	if s := strings.TrimSpace(req.ActivateAt); s != "" {
+		activateAt = utils.ParseExpiration(s)
+		if activateAt == nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid activateAt timestamp")
+			return
+		}
+		utc := activateAt.UTC()
+		activateAt = &utc
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	t, status, msg := h.resolveTracking(ctx, username, req.PresetID, req.UTMs, req.Params)
+	if status != 0 {
+		writeJSONError(w, status, msg)
+		return
+	}
+	t.Keep = utmPolicy == models.UTMPolicyKeep
+	schedule, msg, ok := parseSchedule(req.Schedule, t)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	destinations, msg, ok := parseDestinations(req.Destinations, t)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if len(destinations) > 0 && len(schedule) > 0 {
+		writeJSONError(w, http.StatusBadRequest, "schedule cannot be combined with destinations")
+		return
+	}
+	rules, msg, ok := parseRules(req.Rules, t)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	title, notes := strings.TrimSpace(req.Title), strings.TrimSpace(req.Notes)
+	if msg, ok := validateNotes(title, notes); !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	tags, msg, ok := normalizeTags(req.Tags, maxTags)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	expiredRedirectURL := strings.TrimSpace(req.ExpiredRedirectURL)
+	if expiredRedirectURL != "" {
+		if msg, ok := validateStaticURL(expiredRedirectURL); !ok {
+			writeJSONError(w, http.StatusBadRequest, "Invalid expiredRedirectUrl: "+msg)
+			return
+		}
+	}
+	var passwordHash string
+	if req.Password != "" {
+		hash, err := services.HashLinkPassword(req.Password)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to hash password")
+			return
+		}
+		passwordHash = hash
+	}
+	domain, status, msg := h.domainForUser(ctx, username, req.Domain)
+	if status != 0 {
+		writeJSONError(w, status, msg)
+		return
+	}
+	folderID, status, msg := h.folderForUser(ctx, username, req.FolderID)
+	if status != 0 {
+		writeJSONError(w, status, msg)
+		return
+	}
+	destination := t.compose(urlStr)
 	slug := strings.TrimSpace(req.Slug)
 	if slug != "" {
 		if msg, ok := validateSlug(slug); !ok {
@@ -171,18 +508,95 @@ func (h *Handler) Shorten(w http.ResponseWriter, r *http.Request) {
 		utc := expire.UTC()
 		expire = &utc
 	}
-	now := time.Now().UTC()
+	now := h.now()
+	urlKey := services.URLKey(destination)
+	// With reuseExisting the caller's live link to the same destination
+	// is returned as is.  Other options are not applied to it, so one
+	// lacking the requested access settings is a conflict rather than a
+	// link that is more open than asked for
+	if req.ReuseExisting {
+		existing, err := h.reusableLink(ctx, username, domain, urlKey, now)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		want := models.ShortURL{
+			Slug:         strings.TrimSpace(req.Slug),
+			MaxClicks:    req.MaxClicks,
+			ExpireAt:     expire,
+			Rules:        rules,
+			Destinations: destinations,
+		}
+		if existing != nil && !reuseMatches(existing, want, req.Password) {
+			writeJSONError(w, http.StatusConflict, "An existing link to this destination has different settings; omit reuseExisting to create a new one")
+			return
+		}
+		if existing != nil {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(shortenResponse{
+				Slug:      existing.Slug,
+				ShortLink: h.shortLink(existing.Domain, existing.Slug),
+				URL:       existing.URL,
+				ExpireAt:  existing.ExpireAt,
+				Reused:    true,
+			})
+			return
+		}
+	}
 	record := models.ShortURL{
+		Domain:      domain,
 		Slug:        slug,
 		URL:         destination,
 		ExpireAt:    expire,
-		UTMs:        req.UTMs,
+		UTMs:        t.UTMs,
+		BaseURL:     urlStr,
+		UTMPolicy:   utmPolicy,
+		Params:      t.Params,
+		UTMPresetID: t.PresetID,
 		CreatedAt:   now,
 		CreatedBy:   username,
-		TrackClicks: req.TrackClicks,
+		TrackClicks: req.TrackClicks || len(destinations) > 0,
+
+		ForwardPath:     req.ForwardPath,
+		ForwardQuery:    req.ForwardQuery,
+		QueryPrecedence: precedence,
+		RedirectType:    redirectType,
+
+		Pixels:       pixels,
+		PasswordHash: passwordHash,
+		MaxClicks:    req.MaxClicks,
+
+		ActivateAt:  activateAt,
+		HoldingPage: req.HoldingPage,
+		Schedule:    schedule,
+
+		ExpiredRedirectURL: expiredRedirectURL,
+
+		Destinations: destinations,
+		Rules:        rules,
+
+		DeepLink: deepLink,
+		Preview:  preview,
+
+		PrivateInfo: req.PrivateInfo,
+		ShowOwner:   req.ShowOwner,
+
+		Title:    title,
+		Notes:    notes,
+		Tags:     tags,
+		FolderID: folderID,
+
+		URLKey: urlKey,
+	}
+	msg, ok, err := h.checkDestinations(r.Context(), linkDestinations(record))
+	if err != nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "Destination safety check failed")
+		return
+	}
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
 	}
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
 	inserted, err := h.URLShortener.Shorten(ctx, record)
 	if err != nil {
 		// Check for MongoDB duplicate key error
@@ -193,10 +607,13 @@ func (h *Handler) Shorten(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Error shortening URL: %v", err))
 		return
 	}
-	base := strings.TrimRight(h.BaseURL, "/")
+	// Destination metadata is fetched in the background, never inline
+	if h.Metadata != nil && !urltemplate.IsTemplate(destination) {
+		h.Metadata.Enqueue(inserted.Domain, inserted.Slug, destination)
+	}
 	resp := shortenResponse{
 		Slug:      inserted.Slug,
-		ShortLink: fmt.Sprintf("%s/%s", base, inserted.Slug),
+		ShortLink: h.shortLink(inserted.Domain, inserted.Slug),
 		URL:       destination,
 		ExpireAt:  expire,
 	}
@@ -205,15 +622,30 @@ func (h *Handler) Shorten(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
-// Redirect handles GET requests for a particular slug.
+// Redirect handles GET requests for a particular slug.  Disabled,
+// flagged, inactive and expired links are answered first (see
+// linkBlocked and linkExpired).  Social crawlers then get the preview
+// page and password-protected links the prompt; other visitors are
+// sent to the destination picked by targeting rules, A/B split or
+// schedule, with template placeholders and any forwarded path and
+// query filled in (see chooseDestination and resolveDestination), in
+// the way writeRedirect describes.
 // @Summary Redirect to destination
-// @Description Redirects to the original URL associated with the slug. Returns 301 Moved Permanently when the slug exists and has not expired.
+// @Description Redirects to the destination of the slug on the requested host: 301 by default, an uncached 302 for links that expire or whose destination depends on the visitor or time, or the link's redirectType. Depending on the link's settings an interstitial, password, holding, deep-link, social preview or warning page may be served instead.
 // @Tags redirect
-// @Produce plain
+// @Produce plain,html
 // @Param slug path string true "Slug"
+// @Param path path string false "Trailing path forwarded to the destination"
+// @Success 200 {string} string "Meta-refresh, JavaScript, deep-link or social preview page"
+// @Failure 401 {string} string "Password prompt"
 // @Success 301 {string} string "Moved Permanently"
+// @Success 302 {string} string "Found"
+// @Success 307 {string} string "Temporary Redirect"
+// @Success 308 {string} string "Permanent Redirect"
+// @Success 302 {string} string "Found (fallback destination)"
+// @Failure 403 {string} string "Blocked (destination flagged as unsafe or link disabled)"
 // @Failure 404 {string} string "Not Found"
-// @Failure 410 {string} string "Gone"
+// @Failure 410 {string} string "Gone (expired or click limit reached)"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /{slug} [get]
 func (h *Handler) Redirect(w http.ResponseWriter, r *http.Request) {
@@ -223,35 +655,111 @@ func (h *Handler) Redirect(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing slug", http.StatusBadRequest)
 		return
 	}
+	r, source := takeClickSource(r)
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
-	result, err := h.URLShortener.GetBySlug(ctx, slug)
+	domain, domainRecord, err := h.requestDomain(ctx, r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	result, err := h.URLShortener.GetBySlug(ctx, domain, slug)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
 		return
 	}
 	if result == nil {
-		http.NotFound(w, r)
+		h.linkNotFound(ctx, w, r, domainRecord)
 		return
 	}
-	// Use 302 for temporary links (with expiration), 301 for permanent
-	status := http.StatusMovedPermanently
-	if result.ExpireAt != nil {
-		status = http.StatusFound // 302
-		if time.Now().UTC().After(*result.ExpireAt) {
-			http.Error(w, "This link has expired", http.StatusGone)
+	if result.Disabled {
+		linkBlocked(w, r, disabledMessage)
+		return
+	}
+	if result.Flag != nil {
+		linkBlocked(w, r, blockedMessage)
+		return
+	}
+	now := h.now()
+	if !result.IsActive(now) {
+		if result.HoldingPage {
+			_ = pages.Render(w, http.StatusOK, pages.Holding, pages.HoldingData{ActivateAt: result.ActivateAt})
+			return
+		}
+		h.linkNotFound(ctx, w, r, domainRecord)
+		return
+	}
+	if result.ExpireAt != nil && now.After(*result.ExpireAt) {
+		h.linkExpired(ctx, w, r, result, domainRecord, "This link has expired.")
+		return
+	}
+	// Social crawlers unfurling the link get its preview metadata
+	// instead of a redirect, and are not counted as clicks.  Pasting a
+	// click-limited link into a chat must not use up its redirects, so
+	// those always get the preview page, even without metadata
+	if (!result.Preview.IsEmpty() || result.MaxClicks > 0) && isSocialCrawler(r.UserAgent()) {
+		h.writeSocialPreview(w, result)
+		return
+	}
+	if !passwordSatisfied(r, result) {
+		_ = pages.Render(w, http.StatusUnauthorized, pages.Password, pages.PasswordData{})
+		return
+	}
+	base, variant := h.chooseDestination(w, r, result, now)
+	destination, ok, err := h.resolveDestination(r, result, base, now)
+	if err != nil {
+		http.Error(w, "Invalid destination URL", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		h.linkNotFound(ctx, w, r, domainRecord)
+		return
+	}
+	if h.flagIfUnsafe(ctx, result, destination) {
+		linkBlocked(w, r, blockedMessage)
+		return
+	}
+	// Click-limited links are always counted; the service refuses the
+	// increment once the limit is reached
+	if result.MaxClicks > 0 {
+		if result.RedirectCount >= result.MaxClicks {
+			h.linkExpired(ctx, w, r, result, domainRecord, "This link has reached its click limit.")
+			return
+		}
+		err := h.URLShortener.IncrementRedirectCount(ctx, domain, slug)
+		if errors.Is(err, services.ErrClickLimitReached) {
+			h.linkExpired(ctx, w, r, result, domainRecord, "This link has reached its click limit.")
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
 			return
 		}
 	}
 	// Only track clicks if enabled for this slug (persisted in DB)
+	if result.TrackClicks && result.MaxClicks == 0 {
+		_ = h.URLShortener.IncrementRedirectCount(ctx, domain, slug)
+	}
 	if result.TrackClicks {
-		_ = h.URLShortener.IncrementRedirectCount(ctx, slug)
+		h.recordClick(ctx, r, result, variant, source)
+	}
+	if result.TrackClicks || result.MaxClicks > 0 {
 		// Prevent browser disk caching for analytics accuracy
 		w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, proxy-revalidate")
 		w.Header().Set("Pragma", "no-cache")
 		w.Header().Set("Expires", "0")
 	}
-	http.Redirect(w, r, result.URL, status)
+	private := privateRedirect(result, base)
+	if private {
+		// Neither the browser nor a shared cache may replay this
+		// redirect to later visits
+		w.Header().Set("Cache-Control", "private, no-store")
+	}
+	if appURL := deepLinkFor(r, result); appURL != "" {
+		writeDeepLink(w, appURL, destination)
+		return
+	}
+	writeRedirect(w, r, result, destination, private)
 }
 
 // Register creates a new user with username and password
@@ -347,52 +855,94 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 
 // Slugs returns all shortened URLs for the authenticated user
 // @Summary List user's shortened URLs
-// @Description Returns all shortened URLs for the authenticated user
+// @Description Returns the authenticated user's shortened URLs, newest first unless sort says otherwise. q searches words in the slug, destination, title and notes; the other parameters filter the list. Dates are RFC3339 timestamps or YYYY-MM-DD; ranges include the from bound and exclude the to bound. When more links follow, listings sorted by creation time return a nextCursor to pass as cursor, which stays consistent as links are added; the Link header points at the first, next and previous pages.
 // @Tags slugs
 // @Produce json
 // @Param page query int false "Page number"
 // @Param size query int false "Page size"
 // @Param includeExpired query bool false "Include expired URLs"
+// @Param q query string false "Full-text search"
+// @Param tag query []string false "Only links carrying every listed tag (repeat or comma-separate)"
+// @Param domain query string false "Only links on this domain"
+// @Param folder query string false "Only links in this folder"
+// @Param createdFrom query string false "Created at or after"
+// @Param createdTo query string false "Created before"
+// @Param expiresFrom query string false "Expiring at or after"
+// @Param expiresTo query string false "Expiring before"
+// @Param hasExpiration query bool false "Only links with (true) or without (false) an expiration"
+// @Param tracked query bool false "Only links that do (true) or do not (false) track clicks"
+// @Param broken query bool false "Only links whose destination is (true) or is not (false) broken"
+// @Param sort query string false "created, clicks or expiry; prefix with - for descending (default -created)"
+// @Param cursor query string false "nextCursor of the previous page, when sorting by created"
+// @Param total query bool false "Include the total number of matching links"
 // @Success 200 {object} slugsResponse
+// @Failure 400 {object} map[string]string "Bad Request"
 // @Failure 500 {object} map[string]string "Internal Server Error"
 // @Router /api/slugs [get]
 func (h *Handler) Slugs(w http.ResponseWriter, r *http.Request) {
 	username, _ := r.Context().Value(contextKey("username")).(string)
-	page := 1
-	size := 100
-	if p := r.URL.Query().Get("page"); p != "" {
-		if n, err := strconv.Atoi(p); err == nil && n > 0 {
-			page = n
-		}
+	opts, msg, ok := h.listOptions(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
 	}
-	if s := r.URL.Query().Get("size"); s != "" {
-		if n, err := strconv.Atoi(s); err == nil && n > 0 {
-			size = n
-		}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	// Fetching one link more than the page holds tells whether another
+	// page follows
+	size := opts.Size
+	opts.Size++
+	results, err := h.URLShortener.ListByUser(ctx, username, opts)
+	opts.Size = size
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	more := len(results) > size
+	if more {
+		results = results[:size]
+	}
+	var resp slugsResponse
+	for _, s := range results {
+		resp.Slugs = append(resp.Slugs, h.slugInfo(s))
 	}
-	includeExpired := false
-	if e := r.URL.Query().Get("includeExpired"); e == "true" {
-		includeExpired = true
+	if more && services.CursorSort(opts.Sort) {
+		resp.NextCursor = encodeCursor(results[len(results)-1])
+	}
+	if total, _ := strconv.ParseBool(r.URL.Query().Get("total")); total {
+		n, err := h.URLShortener.CountByUser(ctx, username, opts)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		resp.Total = &n
 	}
+	w.Header().Set("Link", listLinks(r, opts, resp.NextCursor, more))
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// BrokenSlugs lists the authenticated user's links whose destinations
+// failed repeated health checks
+// @Summary List broken links
+// @Description Returns the user's links flagged as broken by the periodic destination health check, most recently checked first
+// @Tags slugs
+// @Produce json
+// @Success 200 {object} slugsResponse
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/slugs/broken [get]
+func (h *Handler) BrokenSlugs(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(contextKey("username")).(string)
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
-	results, err := h.URLShortener.ListByUser(ctx, username, page, size, includeExpired)
+	results, err := h.URLShortener.ListBroken(ctx, username)
 	if err != nil {
 		writeJSONError(w, http.StatusInternalServerError, "Database error")
 		return
 	}
-	base := strings.TrimRight(h.BaseURL, "/")
-	var slugs []SlugInfo
+	slugs := []SlugInfo{}
 	for _, s := range results {
-		slugs = append(slugs, SlugInfo{
-			Slug:          s.Slug,
-			ShortLink:     base + "/" + s.Slug,
-			Destination:   s.URL,
-			ExpireAt:      s.ExpireAt,
-			UTMs:          s.UTMs,
-			RedirectCount: int64(s.RedirectCount),
-			TrackClicks:   s.TrackClicks,
-		})
+		slugs = append(slugs, h.slugInfo(s))
 	}
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(slugsResponse{Slugs: slugs})
@@ -422,7 +972,13 @@ func (h *Handler) CheckSlug(w http.ResponseWriter, r *http.Request) {
 	}
 	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 	defer cancel()
-	available, err := h.URLShortener.IsSlugAvailable(ctx, slug)
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	domain, status, msg := h.domainForUser(ctx, username, req.Domain)
+	if status != 0 {
+		writeJSONError(w, status, msg)
+		return
+	}
+	available, err := h.URLShortener.IsSlugAvailable(ctx, domain, slug)
 	if err != nil {
 		writeJSONError(w, http.StatusInternalServerError, "Database error")
 		return
@@ -455,12 +1011,67 @@ func validateSlug(slug string) (string, bool) {
 	return "", true
 }
 
+// parseSchedule validates scheduled destination changes, applies the
+// request's tracking parameters to each URL and returns them ordered by
+// time.
+func parseSchedule(entries []scheduleRequest, t tracking) ([]models.ScheduledDestination, string, bool) {
+	if len(entries) == 0 {
+		return nil, "", true
+	}
+	schedule := make([]models.ScheduledDestination, 0, len(entries))
+	for _, e := range entries {
+		at := utils.ParseExpiration(strings.TrimSpace(e.At))
+		if at == nil {
+			return nil, "Invalid schedule timestamp", false
+		}
+		urlStr := strings.TrimSpace(e.URL)
+		if msg, ok := validateURL(urlStr); !ok {
+			return nil, "Invalid schedule URL: " + msg, false
+		}
+		schedule = append(schedule, models.ScheduledDestination{
+			At:      at.UTC(),
+			URL:     t.compose(urlStr),
+			BaseURL: urlStr,
+		})
+	}
+	sort.Slice(schedule, func(i, j int) bool { return schedule[i].At.Before(schedule[j].At) })
+	return schedule, "", true
+}
+
+// validateUTMPolicy checks that policy is empty or one of the
+// supported UTM policy values.
+func validateUTMPolicy(policy string) (string, bool) {
+	switch policy {
+	case "", models.UTMPolicyOverride, models.UTMPolicyKeep:
+		return "", true
+	}
+	return "utmPolicy must be \"override\" or \"keep\"", false
+}
+
+// validateQueryPrecedence checks that precedence is empty or one of the
+// supported query precedence values.
+func validateQueryPrecedence(precedence string) (string, bool) {
+	switch precedence {
+	case "", models.QueryPrecedenceStored, models.QueryPrecedenceIncoming:
+		return "", true
+	}
+	return "queryPrecedence must be \"stored\" or \"incoming\"", false
+}
+
 // validateURL checks if the given string is a valid URL with http or https scheme.
+// Destination templates (see package urltemplate) are accepted when
+// their scheme and host are literal.
 func validateURL(urlStr string) (string, bool) {
 	urlStr = strings.TrimSpace(urlStr)
 	if urlStr == "" {
 		return "Missing URL field", false
 	}
+	if urltemplate.IsTemplate(urlStr) {
+		if _, err := urltemplate.Parse(urlStr); err != nil {
+			return "Invalid URL template: " + err.Error(), false
+		}
+		return "", true
+	}
 	u, err := utils.ParseURL(urlStr)
 	if err != nil {
 		return "Invalid URL format", false
@@ -473,3 +1084,12 @@ func validateURL(urlStr string) (string, bool) {
 	}
 	return "", true
 }
+
+// validateStaticURL is validateURL for URLs that are never expanded,
+// such as fallback destinations, and therefore may not be templates.
+func validateStaticURL(urlStr string) (string, bool) {
+	if urltemplate.IsTemplate(urlStr) {
+		return "URL must not contain placeholders", false
+	}
+	return validateURL(urlStr)
+}
@@ -5,27 +5,46 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type mockURLShortener struct {
 	// Add IsSlugAvailable for interface compliance
-	IsSlugAvailableFunc      func(ctx context.Context, slug string) (bool, error)
+	IsSlugAvailableFunc      func(ctx context.Context, domain, slug string) (bool, error)
 	ShortenFunc              func(ctx context.Context, req models.ShortURL) (models.ShortURL, error)
-	GetBySlugFunc            func(ctx context.Context, slug string) (*models.ShortURL, error)
-	IncrementRedirectCountFn func(ctx context.Context, slug string) error
-	ListByUserFunc           func(ctx context.Context, username string, page, size int, includeExpired bool) ([]models.ShortURL, error)
+	GetBySlugFunc            func(ctx context.Context, domain, slug string) (*models.ShortURL, error)
+	GetStoredFunc            func(ctx context.Context, domain, slug string) (*models.ShortURL, error)
+	IncrementRedirectCountFn func(ctx context.Context, domain, slug string) error
+	ListByUserFunc           func(ctx context.Context, username string, opts services.ListOptions) ([]models.ShortURL, error)
+	UpdateMetadataFunc       func(ctx context.Context, domain, slug string, meta models.LinkMetadata) error
+	ListBrokenFunc           func(ctx context.Context, username string) ([]models.ShortURL, error)
+	FlagFunc                 func(ctx context.Context, domain, slug string, flag models.SafetyFlag) error
+	SetDisabledFunc          func(ctx context.Context, domain, slug string, disabled bool) error
+	UnflagFunc               func(ctx context.Context, domain, slug string) error
+	ListFlaggedFunc          func(ctx context.Context, page, size int) ([]models.ShortURL, error)
+	DeleteFunc               func(ctx context.Context, domain, slug string) error
+	CountByUserFunc          func(ctx context.Context, username string, opts services.ListOptions) (int64, error)
+	FindByURLKeyFunc         func(ctx context.Context, username, key string, domain *string) ([]models.ShortURL, error)
+	UpdateUTMsFunc           func(ctx context.Context, domain, slug string, link models.ShortURL) error
+	ListTagsFunc             func(ctx context.Context, username string) ([]models.TagCount, error)
+	ReplaceTagsFunc          func(ctx context.Context, username string, from []string, to string) (int64, error)
+	ClearFolderFunc          func(ctx context.Context, username string, folderID primitive.ObjectID) (int64, error)
 }
 
-func (m *mockURLShortener) IsSlugAvailable(ctx context.Context, slug string) (bool, error) {
+func (m *mockURLShortener) IsSlugAvailable(ctx context.Context, domain, slug string) (bool, error) {
 	if m.IsSlugAvailableFunc != nil {
-		return m.IsSlugAvailableFunc(ctx, slug)
+		return m.IsSlugAvailableFunc(ctx, domain, slug)
 	}
 	return true, nil // default: always available for tests
 }
@@ -33,20 +52,75 @@ func (m *mockURLShortener) IsSlugAvailable(ctx context.Context, slug string) (bo
 func (m *mockURLShortener) Shorten(ctx context.Context, req models.ShortURL) (models.ShortURL, error) {
 	return m.ShortenFunc(ctx, req)
 }
-func (m *mockURLShortener) GetBySlug(ctx context.Context, slug string) (*models.ShortURL, error) {
-	return m.GetBySlugFunc(ctx, slug)
+func (m *mockURLShortener) GetBySlug(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+	return m.GetBySlugFunc(ctx, domain, slug)
 }
-func (m *mockURLShortener) IncrementRedirectCount(ctx context.Context, slug string) error {
-	return m.IncrementRedirectCountFn(ctx, slug)
+func (m *mockURLShortener) GetStored(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+	return m.GetStoredFunc(ctx, domain, slug)
 }
-func (m *mockURLShortener) ListByUser(ctx context.Context, username string, page, size int, includeExpired bool) ([]models.ShortURL, error) {
-	return m.ListByUserFunc(ctx, username, page, size, includeExpired)
+func (m *mockURLShortener) IncrementRedirectCount(ctx context.Context, domain, slug string) error {
+	return m.IncrementRedirectCountFn(ctx, domain, slug)
+}
+func (m *mockURLShortener) UpdateMetadata(ctx context.Context, domain, slug string, meta models.LinkMetadata) error {
+	if m.UpdateMetadataFunc != nil {
+		return m.UpdateMetadataFunc(ctx, domain, slug, meta)
+	}
+	return nil
+}
+func (m *mockURLShortener) ListActive(ctx context.Context, now time.Time, fn func(models.ShortURL) error) error {
+	return nil
+}
+func (m *mockURLShortener) UpdateHealth(ctx context.Context, domain, slug string, health models.LinkHealth) error {
+	return nil
+}
+func (m *mockURLShortener) ListBroken(ctx context.Context, username string) ([]models.ShortURL, error) {
+	return m.ListBrokenFunc(ctx, username)
+}
+func (m *mockURLShortener) Flag(ctx context.Context, domain, slug string, flag models.SafetyFlag) error {
+	if m.FlagFunc != nil {
+		return m.FlagFunc(ctx, domain, slug, flag)
+	}
+	return nil
+}
+func (m *mockURLShortener) Unflag(ctx context.Context, domain, slug string) error {
+	return m.UnflagFunc(ctx, domain, slug)
+}
+func (m *mockURLShortener) ListFlagged(ctx context.Context, page, size int) ([]models.ShortURL, error) {
+	return m.ListFlaggedFunc(ctx, page, size)
+}
+func (m *mockURLShortener) SetDisabled(ctx context.Context, domain, slug string, disabled bool) error {
+	return m.SetDisabledFunc(ctx, domain, slug, disabled)
+}
+func (m *mockURLShortener) Delete(ctx context.Context, domain, slug string) error {
+	return m.DeleteFunc(ctx, domain, slug)
+}
+func (m *mockURLShortener) CountByUser(ctx context.Context, username string, opts services.ListOptions) (int64, error) {
+	return m.CountByUserFunc(ctx, username, opts)
+}
+func (m *mockURLShortener) FindByURLKey(ctx context.Context, username, key string, domain *string) ([]models.ShortURL, error) {
+	return m.FindByURLKeyFunc(ctx, username, key, domain)
+}
+func (m *mockURLShortener) UpdateUTMs(ctx context.Context, domain, slug string, link models.ShortURL) error {
+	return m.UpdateUTMsFunc(ctx, domain, slug, link)
+}
+func (m *mockURLShortener) ListTags(ctx context.Context, username string) ([]models.TagCount, error) {
+	return m.ListTagsFunc(ctx, username)
+}
+func (m *mockURLShortener) ReplaceTags(ctx context.Context, username string, from []string, to string) (int64, error) {
+	return m.ReplaceTagsFunc(ctx, username, from, to)
+}
+func (m *mockURLShortener) ClearFolder(ctx context.Context, username string, folderID primitive.ObjectID) (int64, error) {
+	return m.ClearFolderFunc(ctx, username, folderID)
+}
+func (m *mockURLShortener) ListByUser(ctx context.Context, username string, opts services.ListOptions) ([]models.ShortURL, error) {
+	return m.ListByUserFunc(ctx, username, opts)
 }
 
 type mockUserService struct {
-	RegisterFunc    func(ctx context.Context, username, password string) error
-	LoginFunc       func(ctx context.Context, username, password string) (*models.User, error)
-	GetByUsernameFn func(ctx context.Context, username string) (*models.User, error)
+	RegisterFunc     func(ctx context.Context, username, password string) error
+	LoginFunc        func(ctx context.Context, username, password string) (*models.User, error)
+	GetByUsernameFn  func(ctx context.Context, username string) (*models.User, error)
+	UpdateFallbackFn func(ctx context.Context, username, expiredURL, notFoundURL string) error
 }
 
 func (m *mockUserService) Register(ctx context.Context, username, password string) error {
@@ -56,8 +130,14 @@ func (m *mockUserService) Login(ctx context.Context, username, password string)
 	return m.LoginFunc(ctx, username, password)
 }
 func (m *mockUserService) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	if m.GetByUsernameFn == nil {
+		return nil, nil
+	}
 	return m.GetByUsernameFn(ctx, username)
 }
+func (m *mockUserService) UpdateFallbacks(ctx context.Context, username, expiredURL, notFoundURL string) error {
+	return m.UpdateFallbackFn(ctx, username, expiredURL, notFoundURL)
+}
 
 func TestShortenHandler_Success(t *testing.T) {
 	h := NewHandler(&mockURLShortener{
@@ -95,10 +175,10 @@ func TestShortenHandler_BadRequest(t *testing.T) {
 
 func TestRedirectHandler_Success(t *testing.T) {
 	h := NewHandler(&mockURLShortener{
-		GetBySlugFunc: func(ctx context.Context, slug string) (*models.ShortURL, error) {
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
 			return &models.ShortURL{Slug: slug, URL: "https://example.com", TrackClicks: false}, nil
 		},
-		IncrementRedirectCountFn: func(ctx context.Context, slug string) error { return nil },
+		IncrementRedirectCountFn: func(ctx context.Context, domain, slug string) error { return nil },
 	}, &mockUserService{}, "http://localhost")
 	r := httptest.NewRequest("GET", "/abc12345", nil)
 	rctx := chi.NewRouteContext()
@@ -113,7 +193,7 @@ func TestRedirectHandler_Success(t *testing.T) {
 
 func TestRedirectHandler_NotFound(t *testing.T) {
 	h := NewHandler(&mockURLShortener{
-		GetBySlugFunc: func(ctx context.Context, slug string) (*models.ShortURL, error) {
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
 			return nil, nil
 		},
 	}, &mockUserService{}, "http://localhost")
@@ -190,7 +270,7 @@ func TestLoginHandler_Unauthorized(t *testing.T) {
 
 func TestSlugsHandler_Success(t *testing.T) {
 	h := NewHandler(&mockURLShortener{
-		ListByUserFunc: func(ctx context.Context, username string, page, size int, includeExpired bool) ([]models.ShortURL, error) {
+		ListByUserFunc: func(ctx context.Context, username string, opts services.ListOptions) ([]models.ShortURL, error) {
 			return []models.ShortURL{{Slug: "abc", URL: "https://x.com", TrackClicks: true}}, nil
 		},
 	}, &mockUserService{}, "http://localhost")
@@ -207,3 +287,180 @@ func TestSlugsHandler_Success(t *testing.T) {
 		t.Errorf("expected at least one slug")
 	}
 }
+
+func TestBrokenSlugsHandler(t *testing.T) {
+	var gotUser string
+	h := NewHandler(&mockURLShortener{
+		ListBrokenFunc: func(ctx context.Context, username string) ([]models.ShortURL, error) {
+			gotUser = username
+			return []models.ShortURL{{Slug: "dead", URL: "https://gone.example", Health: &models.LinkHealth{
+				StatusCode: 404, ConsecutiveFailures: 3, Broken: true,
+			}}}, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	req := httptest.NewRequest("GET", "/api/slugs/broken", nil)
+	req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+	w := httptest.NewRecorder()
+	h.BrokenSlugs(w, req)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Result().StatusCode)
+	}
+	if gotUser != "tester" {
+		t.Errorf("expected broken links of tester, got %q", gotUser)
+	}
+	var out slugsResponse
+	_ = json.NewDecoder(w.Body).Decode(&out)
+	if len(out.Slugs) != 1 || out.Slugs[0].Health == nil || !out.Slugs[0].Health.Broken {
+		t.Errorf("expected one broken slug with health, got %+v", out.Slugs)
+	}
+}
+
+func TestRedirectHandler_ForwardPathAndQuery(t *testing.T) {
+	link := &models.ShortURL{
+		URL:          "https://docs.example.com/v2?utm_source=short",
+		ForwardPath:  true,
+		ForwardQuery: true,
+	}
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return link, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	r := httptest.NewRequest("GET", "/docs1234/guide/intro?ref=x&utm_source=ads", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("slug", "docs1234")
+	rctx.URLParams.Add("*", "guide/intro")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+	w := httptest.NewRecorder()
+	h.Redirect(w, r)
+	want := "https://docs.example.com/v2/guide/intro?ref=x&utm_source=short"
+	if loc := w.Result().Header.Get("Location"); loc != want {
+		t.Errorf("expected Location %s, got %s", want, loc)
+	}
+
+	// Incoming parameters win when configured
+	link.QueryPrecedence = models.QueryPrecedenceIncoming
+	w = httptest.NewRecorder()
+	h.Redirect(w, r)
+	want = "https://docs.example.com/v2/guide/intro?ref=x&utm_source=ads"
+	if loc := w.Result().Header.Get("Location"); loc != want {
+		t.Errorf("expected Location %s, got %s", want, loc)
+	}
+
+	// A trailing path on a link without path forwarding is not found
+	link.ForwardPath = false
+	w = httptest.NewRecorder()
+	h.Redirect(w, r)
+	if w.Result().StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestSlugsHandler_Filters(t *testing.T) {
+	var got services.ListOptions
+	h := NewHandler(&mockURLShortener{
+		ListByUserFunc: func(ctx context.Context, username string, opts services.ListOptions) ([]models.ShortURL, error) {
+			got = opts
+			return nil, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	list := func(query string) int {
+		req := httptest.NewRequest("GET", "/api/slugs?"+query, nil)
+		req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+		w := httptest.NewRecorder()
+		h.Slugs(w, req)
+		return w.Result().StatusCode
+	}
+	status := list("q=sale&tag=Promo,email&tag=ads&domain=localhost&createdFrom=2024-01-01&expiresTo=2024-06-01T00:00:00Z&tracked=true&broken=false&sort=-clicks&page=2&size=20")
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	// One link more than the page size is fetched to detect a next page
+	if got.Search != "sale" || len(got.Tags) != 3 || got.Tags[0] != "promo" || got.Sort != "-clicks" || got.Page != 2 || got.Size != 21 {
+		t.Errorf("unexpected options %+v", got)
+	}
+	if got.Domain == nil || *got.Domain != "" {
+		t.Errorf("expected the base host to select default-host links, got %v", got.Domain)
+	}
+	if got.CreatedFrom == nil || !got.CreatedFrom.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) || got.ExpiresTo == nil {
+		t.Errorf("unexpected date range %v %v", got.CreatedFrom, got.ExpiresTo)
+	}
+	if got.Tracked == nil || !*got.Tracked || got.Broken == nil || *got.Broken || got.HasExpiration != nil {
+		t.Errorf("unexpected flags %v %v %v", got.Tracked, got.Broken, got.HasExpiration)
+	}
+
+	for _, query := range []string{"sort=name", "tracked=maybe", "createdFrom=yesterday", "folder=nope"} {
+		if status := list(query); status != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, status)
+		}
+	}
+}
+
+func TestSlugsHandler_CursorPagination(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var links []models.ShortURL
+	for i := 0; i < 5; i++ {
+		links = append(links, models.ShortURL{ID: primitive.NewObjectID(), Slug: fmt.Sprintf("link%d", i), CreatedAt: base.Add(-time.Duration(i) * time.Hour)})
+	}
+	var got services.ListOptions
+	h := NewHandler(&mockURLShortener{
+		ListByUserFunc: func(ctx context.Context, username string, opts services.ListOptions) ([]models.ShortURL, error) {
+			got = opts
+			start := 0
+			if opts.After != nil {
+				for i, l := range links {
+					if l.ID == opts.After.ID {
+						start = i + 1
+					}
+				}
+			}
+			return links[start:min(start+opts.Size, len(links))], nil
+		},
+		CountByUserFunc: func(ctx context.Context, username string, opts services.ListOptions) (int64, error) {
+			return int64(len(links)), nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	list := func(query string) (*httptest.ResponseRecorder, slugsResponse) {
+		req := httptest.NewRequest("GET", "/api/slugs?"+query, nil)
+		req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+		w := httptest.NewRecorder()
+		h.Slugs(w, req)
+		var resp slugsResponse
+		_ = json.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&resp)
+		return w, resp
+	}
+
+	w, resp := list("size=2&total=true")
+	if len(resp.Slugs) != 2 || resp.NextCursor == "" || resp.Total == nil || *resp.Total != 5 {
+		t.Fatalf("unexpected first page %+v", resp)
+	}
+	link := w.Result().Header.Get("Link")
+	if !strings.Contains(link, `rel="next"`) || !strings.Contains(link, "cursor="+resp.NextCursor) {
+		t.Errorf("expected next link with cursor, got %q", link)
+	}
+
+	w, resp = list("size=2&cursor=" + resp.NextCursor)
+	if got.After == nil || got.After.ID != links[1].ID || !got.After.CreatedAt.Equal(links[1].CreatedAt) {
+		t.Fatalf("expected cursor after link1, got %+v", got.After)
+	}
+	if len(resp.Slugs) != 2 || resp.Slugs[0].Slug != "link2" || resp.Total != nil {
+		t.Errorf("unexpected second page %+v", resp)
+	}
+
+	_, resp = list("size=2&cursor=" + resp.NextCursor)
+	if len(resp.Slugs) != 1 || resp.NextCursor != "" {
+		t.Errorf("expected last page without cursor, got %+v", resp)
+	}
+
+	w, _ = list("size=2&page=2&sort=clicks")
+	link = w.Result().Header.Get("Link")
+	if !strings.Contains(link, `page=3`) || !strings.Contains(link, `page=1`) || !strings.Contains(link, `rel="prev"`) {
+		t.Errorf("expected page links for clicks sort, got %q", link)
+	}
+
+	for _, query := range []string{"cursor=bad", "cursor=" + encodeCursor(links[0]) + "&sort=clicks", "cursor=" + encodeCursor(links[0]) + "&page=2"} {
+		if w, _ := list(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
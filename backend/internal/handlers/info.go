@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/pages"
+)
+
+// Link statuses reported by the info page.  Only active links reveal
+// their destination.
+const (
+	infoActive    = "active"
+	infoScheduled = "scheduled"
+	infoExpired   = "expired"
+	infoProtected = "protected"
+	infoBlocked   = "blocked"
+)
+
+// infoMessages explain why the destination of a link is withheld.
+var infoMessages = map[string]string{
+	infoScheduled: "This link is not live yet.",
+	infoExpired:   "This link has expired.",
+	infoProtected: "This link is password protected.",
+	infoBlocked:   "This link has been blocked.",
+}
+
+// linkInfoResponse is the public description of a link returned by
+// the JSON variant of the info page.
+type linkInfoResponse struct {
+	Slug              string     `json:"slug"`
+	ShortLink         string     `json:"shortLink"`
+	Status            string     `json:"status"`
+	Destination       string     `json:"destination,omitempty"`
+	OtherDestinations []string   `json:"otherDestinations,omitempty"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	ExpireAt          *time.Time `json:"expiration,omitempty"`
+	Owner             string     `json:"owner,omitempty"`
+	Clicks            *int64     `json:"clicks,omitempty"`
+}
+
+// Info shows where a link goes without redirecting
+// @Summary Public link info page
+// @Description Shows the destination, creation date and, when enabled on the link, the owner and click count of a short link without following it. Destinations of links that are scheduled, expired, password protected or blocked are withheld. Links with privateInfo set return 404. Returns JSON for clients that accept only application/json or pass format=json, and an HTML page otherwise.
+// @Tags redirect
+// @Produce json,html
+// @Param slug path string true "Slug"
+// @Param format query string false "json for the JSON variant"
+// @Success 200 {object} linkInfoResponse
+// @Failure 404 {object} map[string]string "Not Found"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /{slug}+ [get]
+func (h *Handler) Info(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	asJSON := wantsJSON(r) || r.URL.Query().Get("format") == "json"
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	domain, _, err := h.requestDomain(ctx, r)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	link, err := h.URLShortener.GetBySlug(ctx, domain, slug)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	// Private links are indistinguishable from unknown ones
+	if link == nil || link.PrivateInfo {
+		const msg = "The link you followed does not exist."
+		if asJSON {
+			writeJSONError(w, http.StatusNotFound, msg)
+			return
+		}
+		writeStatusPage(w, r, http.StatusNotFound, pages.NotFound, msg)
+		return
+	}
+	info := h.linkInfo(ctx, link)
+	w.Header().Set("Cache-Control", "no-cache")
+	if asJSON {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(info)
+		return
+	}
+	data := pages.InfoData{
+		ShortLink:         info.ShortLink,
+		Destination:       info.Destination,
+		OtherDestinations: info.OtherDestinations,
+		Message:           infoMessages[info.Status],
+		CreatedAt:         info.CreatedAt,
+		ExpireAt:          info.ExpireAt,
+		Owner:             info.Owner,
+		Clicks:            info.Clicks,
+	}
+	if err := pages.Render(w, http.StatusOK, pages.Info, data); err != nil {
+		http.Error(w, "Failed to render info page", http.StatusInternalServerError)
+	}
+}
+
+// linkInfo builds the public description of link.
+func (h *Handler) linkInfo(ctx context.Context, link *models.ShortURL) linkInfoResponse {
+	now := h.now()
+	info := linkInfoResponse{
+		Slug:      link.Slug,
+		ShortLink: h.shortLink(link.Domain, link.Slug),
+		Status:    linkStatus(link, now),
+		CreatedAt: link.CreatedAt,
+		ExpireAt:  link.ExpireAt,
+	}
+	if info.Status == infoActive {
+		info.Destination = link.URL
+		info.OtherDestinations = otherDestinations(link)
+	}
+	if link.ShowOwner {
+		info.Owner = link.CreatedBy
+	}
+	if link.TrackClicks {
+		clicks := int64(link.RedirectCount)
+		if h.Clicks != nil {
+			if stats, err := h.Clicks.Stats(ctx, link.Domain, link.Slug); err == nil {
+				clicks = stats.Total
+			}
+		}
+		info.Clicks = &clicks
+	}
+	return info
+}
+
+// linkStatus classifies link for the info page.
+func linkStatus(link *models.ShortURL, now time.Time) string {
+	switch {
+	case link.Disabled || link.Flag != nil:
+		return infoBlocked
+	case !link.IsActive(now):
+		return infoScheduled
+	case link.ExpireAt != nil && now.After(*link.ExpireAt):
+		return infoExpired
+	case link.MaxClicks > 0 && link.RedirectCount >= link.MaxClicks:
+		return infoExpired
+	case link.PasswordHash != "":
+		return infoProtected
+	}
+	return infoActive
+}
+
+// otherDestinations lists the A/B variants and targeting rule URLs of
+// link that differ from its main destination, without duplicates.
+func otherDestinations(link *models.ShortURL) []string {
+	seen := map[string]bool{link.URL: true}
+	var out []string
+	add := func(u string) {
+		if u = strings.TrimSpace(u); u != "" && !seen[u] {
+			seen[u] = true
+			out = append(out, u)
+		}
+	}
+	for _, d := range link.Destinations {
+		add(d.URL)
+	}
+	for _, rule := range link.Rules {
+		add(rule.URL)
+	}
+	return out
+}
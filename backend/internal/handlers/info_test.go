@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+func newInfoRequest(slug, query string) *http.Request {
+	r := httptest.NewRequest("GET", "/"+slug+"+"+query, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("slug", slug)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestInfoHandler(t *testing.T) {
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	links := map[string]*models.ShortURL{
+		"public12": {
+			Slug: "public12", URL: "https://example.com/landing", CreatedAt: created, CreatedBy: "tester",
+			ShowOwner: true, TrackClicks: true, RedirectCount: 42,
+			Destinations: []models.Destination{{Label: "A", URL: "https://example.com/landing"}, {Label: "B", URL: "https://example.com/b"}},
+		},
+		"private1": {Slug: "private1", URL: "https://example.com/secret", PrivateInfo: true},
+		"locked12": {Slug: "locked12", URL: "https://example.com/locked", PasswordHash: "x", CreatedBy: "tester"},
+	}
+	increments := 0
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return links[slug], nil
+		},
+		IncrementRedirectCountFn: func(ctx context.Context, domain, slug string) error {
+			increments++
+			return nil
+		},
+	}, &mockUserService{}, "https://sym.ph")
+
+	w := httptest.NewRecorder()
+	h.Info(w, newInfoRequest("public12", "?format=json"))
+	if w.Code != http.StatusOK || w.Header().Get("Location") != "" {
+		t.Fatalf("expected 200 without redirect, got %d", w.Code)
+	}
+	var info linkInfoResponse
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if info.Status != "active" || info.Destination != "https://example.com/landing" || info.ShortLink != "https://sym.ph/public12" {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if len(info.OtherDestinations) != 1 || info.OtherDestinations[0] != "https://example.com/b" {
+		t.Errorf("expected the other variant to be listed, got %v", info.OtherDestinations)
+	}
+	if info.Owner != "tester" || info.Clicks == nil || *info.Clicks != 42 || !info.CreatedAt.Equal(created) {
+		t.Errorf("expected owner, clicks and creation date, got %+v", info)
+	}
+	if increments != 0 {
+		t.Error("viewing the info page must not count as a click")
+	}
+
+	// The HTML page shows the destination as text
+	w = httptest.NewRecorder()
+	h.Info(w, newInfoRequest("public12", ""))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<code>https://example.com/landing</code>") {
+		t.Errorf("expected HTML info page, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Private links look like unknown ones
+	for _, slug := range []string{"private1", "missing1"} {
+		req := newInfoRequest(slug, "")
+		req.Header.Set("Accept", "application/json")
+		w = httptest.NewRecorder()
+		h.Info(w, req)
+		if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "secret") {
+			t.Errorf("%s: expected 404, got %d: %s", slug, w.Code, w.Body.String())
+		}
+	}
+
+	// Protected links withhold the destination and hide the owner by default
+	w = httptest.NewRecorder()
+	h.Info(w, newInfoRequest("locked12", "?format=json"))
+	info = linkInfoResponse{}
+	_ = json.NewDecoder(w.Body).Decode(&info)
+	if info.Status != "protected" || info.Destination != "" || info.Owner != "" || info.Clicks != nil {
+		t.Errorf("expected withheld destination, got %+v", info)
+	}
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/services"
+)
+
+// maxSearch limits the length of the q parameter of GET /api/slugs.
+const maxSearch = 200
+
+// listOptions reads the paging, search, filter and sort parameters of
+// GET /api/slugs, returning a message and false when one is invalid.
+func (h *Handler) listOptions(r *http.Request) (services.ListOptions, string, bool) {
+	q := r.URL.Query()
+	opts := services.ListOptions{Page: 1, Size: 100, IncludeExpired: q.Get("includeExpired") == "true"}
+	if n, err := strconv.Atoi(q.Get("page")); err == nil && n > 0 {
+		opts.Page = n
+	}
+	if n, err := strconv.Atoi(q.Get("size")); err == nil && n > 0 {
+		opts.Size = n
+	}
+	opts.Search = strings.TrimSpace(q.Get("q"))
+	if utf8.RuneCountInString(opts.Search) > maxSearch {
+		return opts, fmt.Sprintf("q must be at most %d characters", maxSearch), false
+	}
+	var tags []string
+	for _, v := range q["tag"] {
+		tags = append(tags, strings.Split(v, ",")...)
+	}
+	tags, msg, ok := normalizeTags(tags, maxTags)
+	if !ok {
+		return opts, msg, false
+	}
+	opts.Tags = tags
+	if _, ok := q["domain"]; ok {
+		domain := h.domainParam(r)
+		opts.Domain = &domain
+	}
+	if v := q.Get("folder"); v != "" {
+		id, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			return opts, "Invalid folder", false
+		}
+		opts.FolderID = &id
+	}
+	dates := []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"createdFrom", &opts.CreatedFrom},
+		{"createdTo", &opts.CreatedTo},
+		{"expiresFrom", &opts.ExpiresFrom},
+		{"expiresTo", &opts.ExpiresTo},
+	}
+	for _, d := range dates {
+		v := q.Get(d.name)
+		if v == "" {
+			continue
+		}
+		t, ok := parseListDate(v)
+		if !ok {
+			return opts, "Invalid " + d.name + " date", false
+		}
+		*d.dst = &t
+	}
+	flags := []struct {
+		name string
+		dst  **bool
+	}{
+		{"hasExpiration", &opts.HasExpiration},
+		{"tracked", &opts.Tracked},
+		{"broken", &opts.Broken},
+	}
+	for _, f := range flags {
+		v := q.Get(f.name)
+		if v == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, f.name + " must be true or false", false
+		}
+		*f.dst = &b
+	}
+	opts.Sort = q.Get("sort")
+	if opts.Sort != "" && !services.ValidSort(opts.Sort) {
+		return opts, "sort must be created, clicks or expiry, optionally prefixed with -", false
+	}
+	if v := q.Get("cursor"); v != "" {
+		if !services.CursorSort(opts.Sort) {
+			return opts, "cursor can only be used when sorting by created", false
+		}
+		if q.Has("page") {
+			return opts, "cursor cannot be combined with page", false
+		}
+		after, ok := decodeCursor(v)
+		if !ok {
+			return opts, "Invalid cursor", false
+		}
+		opts.After = &after
+	}
+	return opts, "", true
+}
+
+// encodeCursor returns the opaque cursor continuing a listing after
+// link.
+func encodeCursor(link models.ShortURL) string {
+	raw := strconv.FormatInt(link.CreatedAt.UnixMilli(), 10) + "." + link.ID.Hex()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a cursor made by encodeCursor.
+func decodeCursor(s string) (services.Cursor, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return services.Cursor{}, false
+	}
+	millis, hexID, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return services.Cursor{}, false
+	}
+	n, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return services.Cursor{}, false
+	}
+	id, err := primitive.ObjectIDFromHex(hexID)
+	if err != nil {
+		return services.Cursor{}, false
+	}
+	return services.Cursor{CreatedAt: time.UnixMilli(n).UTC(), ID: id}, true
+}
+
+// listLinks builds the RFC 8288 Link header of a page of GET
+// /api/slugs.  Listings continued by cursor link to the next cursor;
+// others link to the neighbouring pages.
+func listLinks(r *http.Request, opts services.ListOptions, nextCursor string, more bool) string {
+	link := func(rel string, set map[string]string) string {
+		q := r.URL.Query()
+		q.Del("page")
+		q.Del("cursor")
+		for k, v := range set {
+			q.Set(k, v)
+		}
+		u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+		return fmt.Sprintf("<%s>; rel=%q", u.String(), rel)
+	}
+	links := []string{link("first", nil)}
+	switch {
+	case nextCursor != "":
+		links = append(links, link("next", map[string]string{"cursor": nextCursor}))
+	case more:
+		links = append(links, link("next", map[string]string{"page": strconv.Itoa(opts.Page + 1)}))
+	}
+	if opts.After == nil && opts.Page > 1 {
+		links = append(links, link("prev", map[string]string{"page": strconv.Itoa(opts.Page - 1)}))
+	}
+	return strings.Join(links, ", ")
+}
+
+// parseListDate parses an RFC3339 timestamp or a YYYY-MM-DD date (as
+// midnight UTC).
+func parseListDate(s string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), true
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/services"
+	"github.com/richmondwang/symph-url-shortener/internal/utils"
+)
+
+// Lookup finds the caller's links to a destination
+// @Summary Find links by destination
+// @Description Returns the caller's links whose destination matches url, newest first.  URLs match regardless of host case, default ports and the order of query parameters.
+// @Tags slugs
+// @Produce json
+// @Param url query string true "Destination URL"
+// @Param domain query string false "Only links on this domain"
+// @Success 200 {object} slugsResponse
+// @Failure 400 {object} map[string]string "Bad Request"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/lookup [get]
+func (h *Handler) Lookup(w http.ResponseWriter, r *http.Request) {
+	key, err := utils.NormalizeURL(r.URL.Query().Get("url"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "url must be an absolute http(s) URL")
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	var domain *string
+	if _, ok := r.URL.Query()["domain"]; ok {
+		d := h.domainParam(r)
+		domain = &d
+	}
+	links, err := h.URLShortener.FindByURLKey(ctx, username, key, domain)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	resp := slugsResponse{Slugs: []SlugInfo{}}
+	for _, link := range links {
+		resp.Slugs = append(resp.Slugs, h.slugInfo(link))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// reusableLink returns the user's newest link on domain whose
+// destination key is key and that still redirects at now, or nil when
+// there is none.
+func (h *Handler) reusableLink(ctx context.Context, username, domain, key string, now time.Time) (*models.ShortURL, error) {
+	if key == "" {
+		return nil, nil
+	}
+	links, err := h.URLShortener.FindByURLKey(ctx, username, key, &domain)
+	if err != nil {
+		return nil, err
+	}
+	for i, link := range links {
+		if !linkLive(&link, now) {
+			continue
+		}
+		return &links[i], nil
+	}
+	return nil, nil
+}
+
+// reuseMatches reports whether link already has the access settings a
+// shorten request asks for, so that reusing it does not silently drop
+// them.  Settings the request leaves unset always match.
+func reuseMatches(link *models.ShortURL, want models.ShortURL, password string) bool {
+	switch {
+	case want.Slug != "" && want.Slug != link.Slug:
+		return false
+	case password != "" && !services.CheckLinkPassword(link.PasswordHash, password):
+		return false
+	case want.MaxClicks > 0 && want.MaxClicks != link.MaxClicks:
+		return false
+	case want.ExpireAt != nil && (link.ExpireAt == nil || !want.ExpireAt.Equal(*link.ExpireAt)):
+		return false
+	case len(want.Rules) > 0 && !reflect.DeepEqual(want.Rules, link.Rules):
+		return false
+	case len(want.Destinations) > 0 && !reflect.DeepEqual(want.Destinations, link.Destinations):
+		return false
+	}
+	return true
+}
+
+// linkLive reports whether link is active and would redirect visitors at
+// now rather than show an expired, blocked or disabled page.
+func linkLive(link *models.ShortURL, now time.Time) bool {
+	switch {
+	case link.Disabled, link.Flag != nil, !link.IsActive(now):
+		return false
+	case link.ExpireAt != nil && now.After(*link.ExpireAt):
+		return false
+	case link.MaxClicks > 0 && link.RedirectCount >= link.MaxClicks:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/services"
+)
+
+func TestLookupHandler(t *testing.T) {
+	var gotKey string
+	h := NewHandler(&mockURLShortener{
+		FindByURLKeyFunc: func(ctx context.Context, username, key string, domain *string) ([]models.ShortURL, error) {
+			gotKey = key
+			var links []models.ShortURL
+			for _, link := range []models.ShortURL{
+				{Slug: "branded1", Domain: "go.brand.com", URL: "https://example.com/a?x=1&y=2"},
+				{Slug: "plain123", URL: "https://example.com/a?y=2&x=1"},
+			} {
+				if domain == nil || *domain == link.Domain {
+					links = append(links, link)
+				}
+			}
+			return links, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	lookup := func(query string) (int, slugsResponse) {
+		req := withURLParams(httptest.NewRequest("GET", "/api/lookup?"+query, nil), "tester", nil)
+		w := httptest.NewRecorder()
+		h.Lookup(w, req)
+		var resp slugsResponse
+		_ = json.NewDecoder(w.Body).Decode(&resp)
+		return w.Code, resp
+	}
+	status, resp := lookup("url=" + "HTTPS%3A%2F%2FExample.com%3A443%2Fa%3Fy%3D2%26x%3D1")
+	if status != http.StatusOK || len(resp.Slugs) != 2 {
+		t.Fatalf("expected both links, got %d %+v", status, resp)
+	}
+	if gotKey != "https://example.com/a?x=1&y=2" {
+		t.Errorf("expected normalised key, got %q", gotKey)
+	}
+	if _, resp = lookup("url=https://example.com/a&domain=go.brand.com"); len(resp.Slugs) != 1 || resp.Slugs[0].Slug != "branded1" {
+		t.Errorf("expected domain filter, got %+v", resp)
+	}
+	if status, _ = lookup("url=not-a-url"); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid url, got %d", status)
+	}
+}
+
+func TestShortenHandler_ReuseExisting(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	var existing []models.ShortURL
+	var saved *models.ShortURL
+	h := NewHandler(&mockURLShortener{
+		FindByURLKeyFunc: func(ctx context.Context, username, key string, domain *string) ([]models.ShortURL, error) {
+			if key != "https://example.com/page?a=1&b=2" {
+				t.Errorf("unexpected key %q", key)
+			}
+			if domain == nil || *domain != "" {
+				t.Errorf("expected lookup on the default domain, got %v", domain)
+			}
+			return existing, nil
+		},
+		ShortenFunc: func(ctx context.Context, req models.ShortURL) (models.ShortURL, error) {
+			saved = &req
+			return req, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	h.Now = func() time.Time { return now }
+	shorten := func(extra ...string) (*httptest.ResponseRecorder, shortenResponse) {
+		saved = nil
+		body := `{"url":"https://Example.com/page?b=2&a=1","reuseExisting":true` + strings.Join(extra, "") + `}`
+		req := withURLParams(httptest.NewRequest("POST", "/api/shorten", bytes.NewBufferString(body)), "tester", nil)
+		w := httptest.NewRecorder()
+		h.Shorten(w, req)
+		var resp shortenResponse
+		_ = json.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&resp)
+		return w, resp
+	}
+
+	// Expired and disabled links are not reused
+	existing = []models.ShortURL{
+		{Slug: "expired1", URL: "https://example.com/page?a=1&b=2", ExpireAt: &past},
+		{Slug: "disabled", URL: "https://example.com/page?a=1&b=2", Disabled: true},
+	}
+	w, _ := shorten()
+	if w.Code != http.StatusCreated || saved == nil {
+		t.Fatalf("expected a new link, got %d", w.Code)
+	}
+	if saved.URLKey != "https://example.com/page?a=1&b=2" {
+		t.Errorf("expected URL key on the new link, got %q", saved.URLKey)
+	}
+
+	existing = append(existing, models.ShortURL{Slug: "live1234", URL: "https://example.com/page?a=1&b=2"})
+	w, resp := shorten()
+	if w.Code != http.StatusOK || saved != nil {
+		t.Fatalf("expected the existing link to be reused, got %d", w.Code)
+	}
+	if !resp.Reused || resp.Slug != "live1234" || resp.ShortLink != "http://localhost/live1234" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	// An open link is not handed out for a password-protected request
+	w, _ = shorten(`,"password":"s3cret"`)
+	if w.Code != http.StatusConflict || saved != nil {
+		t.Fatalf("expected 409 for a password the existing link lacks, got %d", w.Code)
+	}
+	hash, _ := services.HashLinkPassword("s3cret")
+	existing[len(existing)-1].PasswordHash = hash
+	w, resp = shorten(`,"password":"s3cret"`)
+	if w.Code != http.StatusOK || !resp.Reused {
+		t.Fatalf("expected reuse when the password matches, got %d", w.Code)
+	}
+	w, _ = shorten(`,"maxClicks":5`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a different maxClicks, got %d", w.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+type recordingEnqueuer struct {
+	urls []string
+}
+
+func (e *recordingEnqueuer) Enqueue(domain, slug, rawURL string) bool {
+	e.urls = append(e.urls, rawURL)
+	return true
+}
+
+func TestShortenHandler_QueuesMetadataFetch(t *testing.T) {
+	queue := &recordingEnqueuer{}
+	h := NewHandler(&mockURLShortener{
+		ShortenFunc: func(ctx context.Context, req models.ShortURL) (models.ShortURL, error) {
+			return req, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	h.Metadata = queue
+	for _, body := range []string{
+		`{"url":"https://example.com/article"}`,
+		`{"url":"https://example.com/p/{query.id}"}`,
+	} {
+		req := httptest.NewRequest("POST", "/api/shorten", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+		w := httptest.NewRecorder()
+		h.Shorten(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d", w.Code)
+		}
+	}
+	if len(queue.urls) != 1 || queue.urls[0] != "https://example.com/article" {
+		t.Errorf("expected only the static destination to be queued, got %v", queue.urls)
+	}
+}
@@ -0,0 +1,495 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/pages"
+)
+
+// Visitors may file maxReports reports per reportWindow from one
+// address, which keeps the moderation queue from being flooded.
+const (
+	maxReports       = 10
+	reportWindow     = time.Hour
+	maxReportDetails = 1000
+)
+
+// disabledMessage is shown to visitors of a link taken down by a
+// moderator.
+const disabledMessage = "This link has been disabled for violating our terms of use."
+
+// reportRequest is the payload of POST /{slug}/report, sent as JSON or
+// as an HTML form.  Reason is phishing, malware, spam or other.
+type reportRequest struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details,omitempty"`
+}
+
+type reportResponse struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// moderationRequest is the optional payload of the moderation actions.
+type moderationRequest struct {
+	Note string `json:"note,omitempty"`
+}
+
+// reportInfo is a report in the moderation queue together with the
+// reported link, when it still exists.
+type reportInfo struct {
+	models.Report
+	Link *SlugInfo `json:"link,omitempty"`
+}
+
+type reportsResponse struct {
+	Reports []reportInfo `json:"reports"`
+}
+
+type flaggedResponse struct {
+	Links []SlugInfo `json:"links"`
+}
+
+type auditResponse struct {
+	Entries []models.AuditEntry `json:"entries"`
+}
+
+// Report files a visitor's abuse report against a link
+// @Summary Report a link
+// @Description Reports a short link as phishing, malware, spam or other abuse. Accepts JSON or a form post; form posts receive a confirmation page. Reports are limited per visitor address.
+// @Tags moderation
+// @Accept json,x-www-form-urlencoded
+// @Produce json,html
+// @Param slug path string true "Slug"
+// @Param request body reportRequest true "Report"
+// @Success 202 {object} reportResponse
+// @Failure 400 {object} map[string]string "Bad Request"
+// @Failure 404 {object} map[string]string "Not Found"
+// @Failure 429 {object} map[string]string "Too Many Requests"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /{slug}/report [post]
+func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
+	if h.Moderation == nil {
+		writeJSONError(w, http.StatusNotFound, "Reporting is not enabled")
+		return
+	}
+	isJSON := isJSONRequest(r)
+	var req reportRequest
+	if isJSON {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid JSON payload")
+			return
+		}
+	} else {
+		req.Reason = r.PostFormValue("reason")
+		req.Details = r.PostFormValue("details")
+	}
+	req.Reason = strings.ToLower(strings.TrimSpace(req.Reason))
+	req.Details = strings.TrimSpace(req.Details)
+	if msg, ok := validateReport(req); !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	key := clientIP(r)
+	if h.reportLimiter.Blocked(key) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(reportWindow.Seconds())))
+		writeJSONError(w, http.StatusTooManyRequests, "Too many reports, try again later")
+		return
+	}
+	h.reportLimiter.Fail(key)
+	slug := chi.URLParam(r, "slug")
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	domain, _, err := h.requestDomain(ctx, r)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	link, err := h.URLShortener.GetBySlug(ctx, domain, slug)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if link == nil {
+		writeJSONError(w, http.StatusNotFound, "Link not found")
+		return
+	}
+	report, err := h.Moderation.CreateReport(ctx, models.Report{
+		Domain:    domain,
+		Slug:      slug,
+		Reason:    req.Reason,
+		Details:   req.Details,
+		Status:    models.ReportOpen,
+		CreatedAt: h.now(),
+	})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !isJSON && !wantsJSON(r) {
+		data := pages.StatusData{Host: normalizeHost(r.Host), Message: "Thank you. Your report will be reviewed by our moderators."}
+		if err := pages.Render(w, http.StatusAccepted, pages.Reported, data); err != nil {
+			http.Error(w, data.Message, http.StatusAccepted)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(reportResponse{ID: report.ID.Hex(), Status: report.Status})
+}
+
+// validateReport checks the reason and the length of the details.
+func validateReport(req reportRequest) (string, bool) {
+	switch req.Reason {
+	case models.ReportPhishing, models.ReportMalware, models.ReportSpam, models.ReportOther:
+	default:
+		return "reason must be one of phishing, malware, spam or other", false
+	}
+	if utf8.RuneCountInString(req.Details) > maxReportDetails {
+		return "details must be at most 1000 characters", false
+	}
+	return "", true
+}
+
+// isJSONRequest reports whether the request body is JSON.
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+// AdminOnly rejects requests from users without the admin role.  It
+// must run after JWTAuthMiddleware.
+func (h *Handler) AdminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(contextKey("username")).(string)
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		user, err := h.UserService.GetByUsername(ctx, username)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if !user.IsAdmin() {
+			writeJSONError(w, http.StatusForbidden, "Moderator access required")
+			return
+		}
+		if h.Moderation == nil {
+			writeJSONError(w, http.StatusNotFound, "Moderation is not enabled")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ListReports returns the moderation queue
+// @Summary List abuse reports
+// @Description Returns abuse reports oldest first, with the reported link when it still exists. Status defaults to open; use all for every status. Admin only.
+// @Tags moderation
+// @Produce json
+// @Param status query string false "open, actioned, dismissed or all"
+// @Param page query int false "Page number"
+// @Param size query int false "Page size (max 100)"
+// @Success 200 {object} reportsResponse
+// @Failure 400 {object} map[string]string "Bad Request"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/admin/reports [get]
+func (h *Handler) ListReports(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = models.ReportOpen
+	case "all":
+		status = ""
+	case models.ReportOpen, models.ReportActioned, models.ReportDismissed:
+	default:
+		writeJSONError(w, http.StatusBadRequest, "status must be open, actioned, dismissed or all")
+		return
+	}
+	page, size := pageParams(r)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	reports, err := h.Moderation.ListReports(ctx, status, page, size)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	out := reportsResponse{Reports: []reportInfo{}}
+	for _, report := range reports {
+		info := reportInfo{Report: report}
+		if link, err := h.URLShortener.GetBySlug(ctx, report.Domain, report.Slug); err == nil && link != nil {
+			slugInfo := h.slugInfo(*link)
+			info.Link = &slugInfo
+		}
+		out.Reports = append(out.Reports, info)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// ListFlagged returns the links blocked by safety checks
+// @Summary List flagged links
+// @Description Returns links whose destination failed a safety check, oldest flag first, so moderators can confirm them with disable or clear false positives with unflag or enable. Admin only.
+// @Tags moderation
+// @Produce json
+// @Param page query int false "Page number"
+// @Param size query int false "Page size (max 100)"
+// @Success 200 {object} flaggedResponse
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/admin/flagged [get]
+func (h *Handler) ListFlagged(w http.ResponseWriter, r *http.Request) {
+	page, size := pageParams(r)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	links, err := h.URLShortener.ListFlagged(ctx, page, size)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	out := flaggedResponse{Links: []SlugInfo{}}
+	for _, link := range links {
+		out.Links = append(out.Links, h.slugInfo(link))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// DismissReport closes a report without acting on the link
+// @Summary Dismiss an abuse report
+// @Description Closes an open report as dismissed and records the decision in the audit trail. Admin only.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param id path string true "Report ID"
+// @Param request body moderationRequest false "Moderator note"
+// @Success 200 {object} models.Report
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "Not Found"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/admin/reports/{id}/dismiss [post]
+func (h *Handler) DismissReport(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Report not found")
+		return
+	}
+	note := decodeModerationNote(r)
+	moderator, _ := r.Context().Value(contextKey("username")).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	now := h.now()
+	report, err := h.Moderation.CloseReport(ctx, id, models.ReportDismissed, moderator, now)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if report == nil {
+		writeJSONError(w, http.StatusNotFound, "Report not found")
+		return
+	}
+	h.audit(ctx, models.AuditEntry{
+		Actor: moderator, Action: models.AuditDismiss, Domain: report.Domain, Slug: report.Slug,
+		ReportID: &id, Note: note, At: now,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// DisableLink takes a link down
+// @Summary Disable a link
+// @Description Disables a link so visitors see a warning page instead of being redirected, closes its open reports as actioned and records the action in the audit trail. Admin only.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param slug path string true "Slug"
+// @Param domain query string false "Custom domain of the link"
+// @Param request body moderationRequest false "Moderator note"
+// @Success 204 "No Content"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "Not Found"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/admin/links/{slug}/disable [post]
+func (h *Handler) DisableLink(w http.ResponseWriter, r *http.Request) {
+	h.moderateLink(w, r, models.AuditDisable)
+}
+
+// EnableLink restores a disabled link
+// @Summary Re-enable a link
+// @Description Restores a disabled link, clears any safety flag on it and records the action in the audit trail. Admin only.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param slug path string true "Slug"
+// @Param domain query string false "Custom domain of the link"
+// @Param request body moderationRequest false "Moderator note"
+// @Success 204 "No Content"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "Not Found"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/admin/links/{slug}/enable [post]
+func (h *Handler) EnableLink(w http.ResponseWriter, r *http.Request) {
+	h.moderateLink(w, r, models.AuditEnable)
+}
+
+// UnflagLink clears a link's safety flag
+// @Summary Clear a safety flag
+// @Description Clears the flag set when a link's destination failed a safety check, so the link redirects again, and records the action in the audit trail. Admin only.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param slug path string true "Slug"
+// @Param domain query string false "Custom domain of the link"
+// @Param request body moderationRequest false "Moderator note"
+// @Success 204 "No Content"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "Not Found"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/admin/links/{slug}/unflag [post]
+func (h *Handler) UnflagLink(w http.ResponseWriter, r *http.Request) {
+	h.moderateLink(w, r, models.AuditUnflag)
+}
+
+// DeleteLink removes a link
+// @Summary Delete a link
+// @Description Deletes a link, closes its open reports as actioned and records the action in the audit trail. Admin only.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param slug path string true "Slug"
+// @Param domain query string false "Custom domain of the link"
+// @Param request body moderationRequest false "Moderator note"
+// @Success 204 "No Content"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "Not Found"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/admin/links/{slug} [delete]
+func (h *Handler) DeleteLink(w http.ResponseWriter, r *http.Request) {
+	h.moderateLink(w, r, models.AuditDelete)
+}
+
+// moderateLink applies a disable, enable, unflag or delete action to
+// the link named by the request and records it in the audit trail.
+func (h *Handler) moderateLink(w http.ResponseWriter, r *http.Request, action string) {
+	slug := chi.URLParam(r, "slug")
+	domain := h.domainParam(r)
+	note := decodeModerationNote(r)
+	moderator, _ := r.Context().Value(contextKey("username")).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	link, err := h.URLShortener.GetBySlug(ctx, domain, slug)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if link == nil {
+		writeJSONError(w, http.StatusNotFound, "Link not found")
+		return
+	}
+	switch action {
+	case models.AuditDisable:
+		err = h.URLShortener.SetDisabled(ctx, domain, slug, true)
+	case models.AuditEnable:
+		// Enabling restores the link fully, including after a safety
+		// flag the moderator judged a false positive
+		err = h.URLShortener.SetDisabled(ctx, domain, slug, false)
+		if err == nil && link.Flag != nil {
+			err = h.URLShortener.Unflag(ctx, domain, slug)
+		}
+	case models.AuditUnflag:
+		err = h.URLShortener.Unflag(ctx, domain, slug)
+	case models.AuditDelete:
+		err = h.URLShortener.Delete(ctx, domain, slug)
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	now := h.now()
+	if action != models.AuditEnable && action != models.AuditUnflag {
+		if _, err := h.Moderation.CloseReports(ctx, domain, slug, models.ReportActioned, moderator, now); err != nil {
+			log.Printf("moderation: failed to close reports for %s: %v", slug, err)
+		}
+	}
+	h.audit(ctx, models.AuditEntry{Actor: moderator, Action: action, Domain: domain, Slug: slug, Note: note, At: now})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AuditLog returns the moderation audit trail
+// @Summary List moderation actions
+// @Description Returns audit entries newest first, optionally for a single link. Admin only.
+// @Tags moderation
+// @Produce json
+// @Param slug query string false "Slug"
+// @Param domain query string false "Custom domain of the link"
+// @Param page query int false "Page number"
+// @Param size query int false "Page size (max 100)"
+// @Success 200 {object} auditResponse
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/admin/audit [get]
+func (h *Handler) AuditLog(w http.ResponseWriter, r *http.Request) {
+	page, size := pageParams(r)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	entries, err := h.Moderation.ListAudit(ctx, h.domainParam(r), r.URL.Query().Get("slug"), page, size)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(auditResponse{Entries: entries})
+}
+
+// audit records a moderation action.  A failure is logged rather than
+// undoing an action that has already been applied.
+func (h *Handler) audit(ctx context.Context, entry models.AuditEntry) {
+	if h.Moderation == nil {
+		return
+	}
+	if err := h.Moderation.RecordAudit(ctx, entry); err != nil {
+		log.Printf("moderation: failed to record %s of %s: %v", entry.Action, entry.Slug, err)
+	}
+}
+
+// decodeModerationNote reads the optional moderator note.
+func decodeModerationNote(r *http.Request) string {
+	var req moderationRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	return strings.TrimSpace(req.Note)
+}
+
+// domainParam reads the ?domain= parameter naming a link's custom
+// domain; the default host is stored as an empty domain.
+func (h *Handler) domainParam(r *http.Request) string {
+	domain := normalizeHost(r.URL.Query().Get("domain"))
+	if domain == h.baseHost() {
+		return ""
+	}
+	return domain
+}
+
+// pageParams reads the page and size query parameters of the admin
+// listings.
+func pageParams(r *http.Request) (int, int) {
+	page, size := 1, 50
+	if n, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && n > 0 {
+		page = n
+	}
+	if n, err := strconv.Atoi(r.URL.Query().Get("size")); err == nil && n > 0 {
+		size = min(n, 100)
+	}
+	return page, size
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+type mockModerationService struct {
+	reports []models.Report
+	audit   []models.AuditEntry
+}
+
+func (m *mockModerationService) CreateReport(ctx context.Context, report models.Report) (models.Report, error) {
+	report.ID = primitive.NewObjectID()
+	m.reports = append(m.reports, report)
+	return report, nil
+}
+func (m *mockModerationService) ListReports(ctx context.Context, status string, page, size int) ([]models.Report, error) {
+	var out []models.Report
+	for _, r := range m.reports {
+		if status == "" || r.Status == status {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+func (m *mockModerationService) CloseReports(ctx context.Context, domain, slug, status, by string, at time.Time) (int64, error) {
+	var n int64
+	for i := range m.reports {
+		r := &m.reports[i]
+		if r.Domain == domain && r.Slug == slug && r.Status == models.ReportOpen {
+			r.Status, r.ResolvedBy, r.ResolvedAt = status, by, &at
+			n++
+		}
+	}
+	return n, nil
+}
+func (m *mockModerationService) CloseReport(ctx context.Context, id primitive.ObjectID, status, by string, at time.Time) (*models.Report, error) {
+	for i := range m.reports {
+		r := &m.reports[i]
+		if r.ID == id && r.Status == models.ReportOpen {
+			r.Status, r.ResolvedBy, r.ResolvedAt = status, by, &at
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+func (m *mockModerationService) RecordAudit(ctx context.Context, entry models.AuditEntry) error {
+	m.audit = append(m.audit, entry)
+	return nil
+}
+func (m *mockModerationService) ListAudit(ctx context.Context, domain, slug string, page, size int) ([]models.AuditEntry, error) {
+	return m.audit, nil
+}
+
+// withURLParams adds chi route parameters and the username to r.
+func withURLParams(r *http.Request, username string, params map[string]string) *http.Request {
+	rctx := chi.NewRouteContext()
+	for k, v := range params {
+		rctx.URLParams.Add(k, v)
+	}
+	ctx := context.WithValue(r.Context(), chi.RouteCtxKey, rctx)
+	if username != "" {
+		ctx = context.WithValue(ctx, contextKey("username"), username)
+	}
+	return r.WithContext(ctx)
+}
+
+func TestReportHandler(t *testing.T) {
+	mod := &mockModerationService{}
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			if slug != "phish123" {
+				return nil, nil
+			}
+			return &models.ShortURL{Slug: slug, URL: "https://example.com"}, nil
+		},
+	}, &mockUserService{}, "https://sym.ph")
+	h.Moderation = mod
+
+	req := httptest.NewRequest("POST", "/phish123/report", strings.NewReader(`{"reason":"Phishing","details":"fake bank login"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.Report(w, withURLParams(req, "", map[string]string{"slug": "phish123"}))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(mod.reports) != 1 || mod.reports[0].Reason != models.ReportPhishing || mod.reports[0].Status != models.ReportOpen {
+		t.Fatalf("expected an open phishing report, got %+v", mod.reports)
+	}
+
+	// HTML form posts get a confirmation page
+	form := url.Values{"reason": {"spam"}}
+	req = httptest.NewRequest("POST", "/phish123/report", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	h.Report(w, withURLParams(req, "", map[string]string{"slug": "phish123"}))
+	if w.Code != http.StatusAccepted || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("expected HTML confirmation, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	for body, want := range map[string]int{
+		`{"reason":"boring"}`: http.StatusBadRequest,
+		`{"reason":"other","details":"` + strings.Repeat("x", 1001) + `"}`: http.StatusBadRequest,
+	} {
+		req = httptest.NewRequest("POST", "/phish123/report", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		h.Report(w, withURLParams(req, "", map[string]string{"slug": "phish123"}))
+		if w.Code != want {
+			t.Errorf("%.40s: expected %d, got %d", body, want, w.Code)
+		}
+	}
+
+	req = httptest.NewRequest("POST", "/missing1/report", strings.NewReader(`{"reason":"spam"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	h.Report(w, withURLParams(req, "", map[string]string{"slug": "missing1"}))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown slug, got %d", w.Code)
+	}
+}
+
+func TestReportHandler_RateLimited(t *testing.T) {
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return &models.ShortURL{Slug: slug, URL: "https://example.com"}, nil
+		},
+	}, &mockUserService{}, "https://sym.ph")
+	h.Moderation = &mockModerationService{}
+	var last int
+	for i := 0; i <= maxReports; i++ {
+		req := httptest.NewRequest("POST", "/abc12345/report", strings.NewReader(`{"reason":"spam"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.Report(w, withURLParams(req, "", map[string]string{"slug": "abc12345"}))
+		last = w.Code
+	}
+	if last != http.StatusTooManyRequests {
+		t.Errorf("expected 429 after %d reports, got %d", maxReports, last)
+	}
+}
+
+func TestAdminOnly(t *testing.T) {
+	h := NewHandler(&mockURLShortener{}, &mockUserService{
+		GetByUsernameFn: func(ctx context.Context, username string) (*models.User, error) {
+			if username == "mod" {
+				return &models.User{Username: username, Role: models.RoleAdmin}, nil
+			}
+			return &models.User{Username: username}, nil
+		},
+	}, "https://sym.ph")
+	h.Moderation = &mockModerationService{}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	for user, want := range map[string]int{"mod": http.StatusTeapot, "tester": http.StatusForbidden} {
+		req := withURLParams(httptest.NewRequest("GET", "/api/admin/reports", nil), user, nil)
+		w := httptest.NewRecorder()
+		h.AdminOnly(next).ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", user, want, w.Code)
+		}
+	}
+}
+
+func TestModerationActions(t *testing.T) {
+	link := &models.ShortURL{Domain: "go.brand.com", Slug: "phish123", URL: "https://example.com"}
+	deleted := false
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			if deleted || domain != link.Domain || slug != link.Slug {
+				return nil, nil
+			}
+			return link, nil
+		},
+		SetDisabledFunc: func(ctx context.Context, domain, slug string, disabled bool) error {
+			link.Disabled = disabled
+			return nil
+		},
+		DeleteFunc: func(ctx context.Context, domain, slug string) error {
+			deleted = true
+			return nil
+		},
+	}, &mockUserService{}, "https://sym.ph")
+	h.Domains = &mockDomainService{domains: map[string]*models.Domain{
+		"go.brand.com": {Host: "go.brand.com", Owner: "tester", Verified: true},
+	}}
+	mod := &mockModerationService{}
+	h.Moderation = mod
+	report, _ := mod.CreateReport(context.Background(), models.Report{Domain: "go.brand.com", Slug: "phish123", Reason: "phishing", Status: models.ReportOpen})
+	other, _ := mod.CreateReport(context.Background(), models.Report{Slug: "fine1234", Reason: "spam", Status: models.ReportOpen})
+
+	// The queue lists open reports with their link
+	w := httptest.NewRecorder()
+	h.ListReports(w, withURLParams(httptest.NewRequest("GET", "/api/admin/reports", nil), "mod", nil))
+	var queue reportsResponse
+	_ = json.NewDecoder(w.Body).Decode(&queue)
+	if len(queue.Reports) != 2 || queue.Reports[0].Link == nil || queue.Reports[0].Link.Destination != "https://example.com" {
+		t.Fatalf("unexpected queue %+v", queue)
+	}
+
+	// Disabling closes the link's reports and shows a warning page
+	req := httptest.NewRequest("POST", "/api/admin/links/phish123/disable?domain=go.brand.com", bytes.NewBufferString(`{"note":"confirmed phishing"}`))
+	w = httptest.NewRecorder()
+	h.DisableLink(w, withURLParams(req, "mod", map[string]string{"slug": "phish123"}))
+	if w.Code != http.StatusNoContent || !link.Disabled {
+		t.Fatalf("expected link to be disabled, got %d", w.Code)
+	}
+	if mod.reports[0].Status != models.ReportActioned || mod.reports[0].ResolvedBy != "mod" {
+		t.Errorf("expected report to be actioned, got %+v", mod.reports[0])
+	}
+	r := newRedirectRequest("phish123")
+	r.Host = "go.brand.com"
+	w = httptest.NewRecorder()
+	h.Redirect(w, r)
+	if w.Code != http.StatusForbidden || w.Header().Get("Location") != "" {
+		t.Errorf("expected disabled link to show a warning page, got %d", w.Code)
+	}
+
+	// Dismissing an already closed report fails; an open one succeeds
+	for id, want := range map[primitive.ObjectID]int{report.ID: http.StatusNotFound, other.ID: http.StatusOK} {
+		w = httptest.NewRecorder()
+		h.DismissReport(w, withURLParams(httptest.NewRequest("POST", "/", nil), "mod", map[string]string{"id": id.Hex()}))
+		if w.Code != want {
+			t.Errorf("dismiss %s: expected %d, got %d", id.Hex(), want, w.Code)
+		}
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/admin/links/phish123?domain=go.brand.com", nil)
+	w = httptest.NewRecorder()
+	h.DeleteLink(w, withURLParams(req, "mod", map[string]string{"slug": "phish123"}))
+	if w.Code != http.StatusNoContent || !deleted {
+		t.Errorf("expected link to be deleted, got %d", w.Code)
+	}
+
+	var actions []string
+	for _, e := range mod.audit {
+		actions = append(actions, e.Action)
+	}
+	if strings.Join(actions, ",") != "disable,dismiss,delete" {
+		t.Errorf("unexpected audit trail %v", actions)
+	}
+	if mod.audit[0].Note != "confirmed phishing" || mod.audit[0].Actor != "mod" || mod.audit[0].Domain != "go.brand.com" {
+		t.Errorf("unexpected audit entry %+v", mod.audit[0])
+	}
+}
+
+func TestUnflagLink(t *testing.T) {
+	link := &models.ShortURL{Slug: "falsepos", URL: "https://example.com", Flag: &models.SafetyFlag{Check: "blocklist", Reason: "listed"}}
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return link, nil
+		},
+		UnflagFunc: func(ctx context.Context, domain, slug string) error {
+			link.Flag = nil
+			return nil
+		},
+	}, &mockUserService{}, "https://sym.ph")
+	mod := &mockModerationService{}
+	h.Moderation = mod
+	_, _ = mod.CreateReport(context.Background(), models.Report{Slug: "falsepos", Reason: "phishing", Status: models.ReportOpen})
+
+	w := httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("falsepos"))
+	if w.Header().Get("Location") != "" {
+		t.Fatalf("expected flagged link to be blocked, got %d", w.Code)
+	}
+	req := httptest.NewRequest("POST", "/api/admin/links/falsepos/unflag", bytes.NewBufferString(`{"note":"false positive"}`))
+	w = httptest.NewRecorder()
+	h.UnflagLink(w, withURLParams(req, "mod", map[string]string{"slug": "falsepos"}))
+	if w.Code != http.StatusNoContent || link.Flag != nil {
+		t.Fatalf("expected flag cleared, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("falsepos"))
+	if w.Header().Get("Location") != "https://example.com" {
+		t.Errorf("expected redirect after unflag, got %d", w.Code)
+	}
+	if len(mod.audit) != 1 || mod.audit[0].Action != models.AuditUnflag || mod.audit[0].Note != "false positive" {
+		t.Errorf("unexpected audit trail %+v", mod.audit)
+	}
+	if mod.reports[0].Status != models.ReportOpen {
+		t.Errorf("expected reports to stay open, got %+v", mod.reports[0])
+	}
+}
+
+func TestFlaggedQueue(t *testing.T) {
+	link := &models.ShortURL{Slug: "flagged1", URL: "https://example.com", Disabled: true, Flag: &models.SafetyFlag{Check: "blocklist", Reason: "listed"}}
+	var gotPage, gotSize int
+	var disabled *bool
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return link, nil
+		},
+		ListFlaggedFunc: func(ctx context.Context, page, size int) ([]models.ShortURL, error) {
+			gotPage, gotSize = page, size
+			return []models.ShortURL{*link}, nil
+		},
+		SetDisabledFunc: func(ctx context.Context, domain, slug string, d bool) error {
+			disabled = &d
+			return nil
+		},
+		UnflagFunc: func(ctx context.Context, domain, slug string) error {
+			link.Flag = nil
+			return nil
+		},
+	}, &mockUserService{}, "https://sym.ph")
+	h.Moderation = &mockModerationService{}
+
+	w := httptest.NewRecorder()
+	h.ListFlagged(w, withURLParams(httptest.NewRequest("GET", "/api/admin/flagged?page=2&size=10", nil), "mod", nil))
+	var resp flaggedResponse
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || len(resp.Links) != 1 || resp.Links[0].Flag == nil || gotPage != 2 || gotSize != 10 {
+		t.Fatalf("unexpected flagged queue %d %+v (page %d, size %d)", w.Code, resp, gotPage, gotSize)
+	}
+
+	// Enabling restores the link and clears its flag
+	w = httptest.NewRecorder()
+	h.EnableLink(w, withURLParams(httptest.NewRequest("POST", "/", nil), "mod", map[string]string{"slug": "flagged1"}))
+	if w.Code != http.StatusNoContent || disabled == nil || *disabled || link.Flag != nil {
+		t.Fatalf("expected link enabled and unflagged, got %d", w.Code)
+	}
+}
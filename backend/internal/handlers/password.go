@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/pages"
+	"github.com/richmondwang/symph-url-shortener/internal/services"
+)
+
+// unlockCookieTTL is how long a successful password entry is
+// remembered before the visitor is asked again.
+const unlockCookieTTL = 30 * time.Minute
+
+// Failed password attempts are limited per client IP and slug.
+const (
+	maxUnlockFailures   = 5
+	unlockFailureWindow = 15 * time.Minute
+)
+
+// Unlock checks the password submitted for a protected link
+// @Summary Unlock a password-protected link
+// @Description Accepts the password form posted from the prompt page. On success sets a signed short-lived cookie and redirects back to the short link; failed attempts are rate-limited per client IP and slug.
+// @Tags redirect
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param slug path string true "Slug"
+// @Param password formData string true "Link password"
+// @Success 303 {string} string "See Other"
+// @Failure 401 {string} string "Wrong password"
+// @Failure 404 {string} string "Not Found"
+// @Failure 429 {string} string "Too Many Requests"
+// @Router /{slug} [post]
+func (h *Handler) Unlock(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	domain, _, err := h.requestDomain(ctx, r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	link, err := h.URLShortener.GetBySlug(ctx, domain, slug)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if link == nil || link.PasswordHash == "" {
+		http.NotFound(w, r)
+		return
+	}
+	secret := os.Getenv("JWT_SECRET_KEY")
+	if secret == "" {
+		http.Error(w, "Cookie secret not configured", http.StatusInternalServerError)
+		return
+	}
+	key := clientIP(r) + "|" + domain + "/" + slug
+	if h.unlockLimiter.Blocked(key) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(unlockFailureWindow.Seconds())))
+		_ = pages.Render(w, http.StatusTooManyRequests, pages.Password, pages.PasswordData{Error: "Too many attempts. Please try again later."})
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+	if !services.CheckLinkPassword(link.PasswordHash, r.PostFormValue("password")) {
+		h.unlockLimiter.Fail(key)
+		_ = pages.Render(w, http.StatusUnauthorized, pages.Password, pages.PasswordData{Error: "Incorrect password."})
+		return
+	}
+	h.unlockLimiter.Reset(key)
+	expires := time.Now().Add(unlockCookieTTL)
+	http.SetCookie(w, &http.Cookie{
+		Name:     unlockCookieName(slug),
+		Value:    signUnlock(secret, link, expires.Unix()),
+		Path:     "/" + slug,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, r.URL.RequestURI(), http.StatusSeeOther)
+}
+
+// passwordSatisfied reports whether the request may follow a link: the
+// link has no password, or the visitor holds a valid unlock cookie.
+func passwordSatisfied(r *http.Request, link *models.ShortURL) bool {
+	if link.PasswordHash == "" {
+		return true
+	}
+	secret := os.Getenv("JWT_SECRET_KEY")
+	c, err := r.Cookie(unlockCookieName(link.Slug))
+	if err != nil || secret == "" {
+		return false
+	}
+	expStr, _, ok := strings.Cut(c.Value, ".")
+	if !ok {
+		return false
+	}
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(c.Value), []byte(signUnlock(secret, link, exp)))
+}
+
+// signUnlock produces the "<expiry>.<mac>" unlock cookie value.  The
+// MAC covers the link's password hash so changing the password revokes
+// outstanding cookies.
+func signUnlock(secret string, link *models.ShortURL, exp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%d\n%s", link.Domain, link.Slug, exp, link.PasswordHash)
+	return strconv.FormatInt(exp, 10) + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+func unlockCookieName(slug string) string {
+	return "symph_unlock_" + slug
+}
+
+// clientIP returns the host part of the request's remote address.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/services"
+)
+
+func newUnlockRequest(slug, password string) *http.Request {
+	form := url.Values{"password": {password}}
+	r := httptest.NewRequest("POST", "/"+slug, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("slug", slug)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestPasswordProtectedLink(t *testing.T) {
+	os.Setenv("JWT_SECRET_KEY", "testsecret")
+	hash, err := services.HashLinkPassword("open-sesame")
+	if err != nil {
+		t.Fatalf("hash error: %v", err)
+	}
+	link := &models.ShortURL{Slug: "secret12", URL: "https://drive.example.com/folder", PasswordHash: hash}
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return link, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+
+	// Without a cookie the prompt is shown
+	w := httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("secret12"))
+	if w.Result().StatusCode != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `name="password"`) {
+		t.Fatalf("expected password prompt, got %d", w.Result().StatusCode)
+	}
+
+	// A wrong password is rejected
+	w = httptest.NewRecorder()
+	h.Unlock(w, newUnlockRequest("secret12", "nope"))
+	if w.Result().StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for wrong password, got %d", w.Result().StatusCode)
+	}
+
+	// The right password sets an unlock cookie that allows the redirect
+	w = httptest.NewRecorder()
+	h.Unlock(w, newUnlockRequest("secret12", "open-sesame"))
+	if w.Result().StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected 303 after unlock, got %d", w.Result().StatusCode)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("expected one HttpOnly unlock cookie, got %v", cookies)
+	}
+	r := newRedirectRequest("secret12")
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	h.Redirect(w, r)
+	// The unlocked redirect is temporary and uncached so later visits
+	// are checked again
+	if w.Result().StatusCode != http.StatusFound || w.Result().Header.Get("Cache-Control") != "private, no-store" {
+		t.Fatalf("expected uncached 302 with unlock cookie, got %d %q", w.Result().StatusCode, w.Result().Header.Get("Cache-Control"))
+	}
+
+	// Redirect pages of protected links are not cached either
+	link.RedirectType = models.RedirectMetaRefresh
+	w = httptest.NewRecorder()
+	h.Redirect(w, r)
+	if w.Result().StatusCode != http.StatusOK || w.Result().Header.Get("Cache-Control") != "private, no-store" {
+		t.Fatalf("expected uncached meta refresh page, got %d %q", w.Result().StatusCode, w.Result().Header.Get("Cache-Control"))
+	}
+	link.RedirectType = ""
+
+	// A tampered cookie is ignored
+	r = newRedirectRequest("secret12")
+	r.AddCookie(&http.Cookie{Name: cookies[0].Name, Value: cookies[0].Value + "0"})
+	w = httptest.NewRecorder()
+	h.Redirect(w, r)
+	if w.Result().StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected prompt for tampered cookie, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestUnlockRateLimited(t *testing.T) {
+	os.Setenv("JWT_SECRET_KEY", "testsecret")
+	hash, _ := services.HashLinkPassword("open-sesame")
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return &models.ShortURL{Slug: slug, URL: "https://x.com", PasswordHash: hash}, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	for i := 0; i < maxUnlockFailures; i++ {
+		w := httptest.NewRecorder()
+		h.Unlock(w, newUnlockRequest("secret12", "guess"))
+	}
+	// Even the right password is refused while blocked
+	w := httptest.NewRecorder()
+	h.Unlock(w, newUnlockRequest("secret12", "open-sesame"))
+	if w.Result().StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 after repeated failures, got %d", w.Result().StatusCode)
+	}
+	// Other slugs are unaffected
+	w = httptest.NewRecorder()
+	h.Unlock(w, newUnlockRequest("another1", "open-sesame"))
+	if w.Result().StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected 303 for a different slug, got %d", w.Result().StatusCode)
+	}
+}
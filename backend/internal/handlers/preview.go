@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/pages"
+)
+
+// Limits on custom preview metadata, in characters.
+const (
+	maxPreviewTitle       = 200
+	maxPreviewDescription = 500
+)
+
+// socialCrawlers are User-Agent substrings of the link unfurlers of
+// social networks and chat apps.  They are matched case-insensitively.
+var socialCrawlers = []string{
+	"facebookexternalhit",
+	"facebot",
+	"twitterbot",
+	"linkedinbot",
+	"slackbot",
+	"slack-imgproxy",
+	"discordbot",
+	"whatsapp",
+	"telegrambot",
+	"skypeuripreview",
+	"pinterestbot",
+	"redditbot",
+	"embedly",
+	"vkshare",
+	"iframely",
+	"mastodon",
+}
+
+// isSocialCrawler reports whether ua belongs to a known link unfurler.
+func isSocialCrawler(ua string) bool {
+	ua = strings.ToLower(ua)
+	for _, c := range socialCrawlers {
+		if strings.Contains(ua, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePreview trims and checks custom preview metadata in place.
+func validatePreview(p *models.PreviewMeta) (string, bool) {
+	if p == nil {
+		return "", true
+	}
+	p.Title = strings.TrimSpace(p.Title)
+	p.Description = strings.TrimSpace(p.Description)
+	p.Image = strings.TrimSpace(p.Image)
+	if utf8.RuneCountInString(p.Title) > maxPreviewTitle {
+		return "Preview title must be at most 200 characters", false
+	}
+	if utf8.RuneCountInString(p.Description) > maxPreviewDescription {
+		return "Preview description must be at most 500 characters", false
+	}
+	if p.Image != "" {
+		if msg, ok := validateStaticURL(p.Image); !ok {
+			return "Invalid preview image: " + msg, false
+		}
+	}
+	return "", true
+}
+
+// writeSocialPreview serves a crawler the link's Open Graph and Twitter
+// card tags.  The page names only the short link, never the
+// destination, so protected links do not leak where they lead.
+func (h *Handler) writeSocialPreview(w http.ResponseWriter, link *models.ShortURL) {
+	data := pages.SocialData{URL: h.shortLink(link.Domain, link.Slug)}
+	if link.Preview != nil {
+		data.Title = link.Preview.Title
+		data.Description = link.Preview.Description
+		data.Image = link.Preview.Image
+	}
+	_ = pages.Render(w, http.StatusOK, pages.Social, data)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+func TestRedirectHandler_SocialPreview(t *testing.T) {
+	link := models.ShortURL{
+		Slug:        "launch123",
+		URL:         "https://example.com/secret-landing",
+		TrackClicks: true,
+		Preview: &models.PreviewMeta{
+			Title:       `Launch "day"`,
+			Description: "Everything <new>",
+			Image:       "https://cdn.example.com/og.png",
+		},
+	}
+	increments := 0
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return &link, nil
+		},
+		IncrementRedirectCountFn: func(ctx context.Context, domain, slug string) error {
+			increments++
+			return nil
+		},
+	}, &mockUserService{}, "https://sym.ph")
+
+	req := newRedirectRequest("launch123")
+	req.Header.Set("User-Agent", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)")
+	w := httptest.NewRecorder()
+	h.Redirect(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected preview page, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`property="og:title" content="Launch &#34;day&#34;"`,
+		`property="og:description" content="Everything &lt;new&gt;"`,
+		`property="og:image" content="https://cdn.example.com/og.png"`,
+		`name="twitter:card" content="summary_large_image"`,
+		`property="og:url" content="https://sym.ph/launch123"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in page: %s", want, body)
+		}
+	}
+	if strings.Contains(body, "secret-landing") {
+		t.Error("preview page must not reveal the destination")
+	}
+	if increments != 0 {
+		t.Error("crawler visits must not count as clicks")
+	}
+
+	req = newRedirectRequest("launch123")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7)")
+	w = httptest.NewRecorder()
+	h.Redirect(w, req)
+	if w.Code != http.StatusMovedPermanently || increments != 1 {
+		t.Errorf("expected humans to be redirected and counted, got %d (%d clicks)", w.Code, increments)
+	}
+}
+
+func TestIsSocialCrawler(t *testing.T) {
+	for ua, want := range map[string]bool{
+		"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)":             true,
+		"LinkedInBot/1.0 (compatible; Mozilla/5.0; Apache-HttpClient +http://www.linkedin.com)": true,
+		"Twitterbot/1.0": true,
+		"Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)": true,
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0":            false,
+		"": false,
+	} {
+		if got := isSocialCrawler(ua); got != want {
+			t.Errorf("isSocialCrawler(%q) = %v, want %v", ua, got, want)
+		}
+	}
+}
+
+func TestShortenHandler_InvalidPreview(t *testing.T) {
+	h := NewHandler(&mockURLShortener{}, &mockUserService{}, "http://localhost")
+	for _, body := range []string{
+		`{"url":"https://example.com","preview":{"image":"javascript:alert(1)"}}`,
+		`{"url":"https://example.com","preview":{"title":"` + strings.Repeat("x", 201) + `"}}`,
+	} {
+		req := httptest.NewRequest("POST", "/api/shorten", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+		w := httptest.NewRecorder()
+		h.Shorten(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", body[:60], w.Code)
+		}
+	}
+}
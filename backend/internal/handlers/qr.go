@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/qr"
+)
+
+// Limits and defaults of the QR code endpoint.
+const (
+	defaultQRSize = 256
+	minQRSize     = 64
+	maxQRSize     = 2048
+	// qrSourceParam marks redirects made by scanning a generated code.
+	qrSourceParam = "src"
+)
+
+// QRCode renders a QR code for one of the caller's links
+// @Summary Get a QR code for a link
+// @Description Renders the short link as a PNG or SVG QR code.  Scans are recorded with source "qr" in the link's click statistics.  A logo configured on the server can be drawn in the centre, which raises the error correction level to H.
+// @Tags links
+// @Produce image/png
+// @Produce image/svg+xml
+// @Param slug path string true "Slug"
+// @Param domain query string false "Custom domain of the link"
+// @Param format query string false "png (default) or svg"
+// @Param size query int false "Width in pixels, 64-2048 (default 256)"
+// @Param ecc query string false "Error correction level L, M (default), Q or H"
+// @Param fg query string false "Foreground hex colour (default 000000)"
+// @Param bg query string false "Background hex colour (default ffffff)"
+// @Param logo query bool false "Draw the configured logo in the centre"
+// @Success 200 {file} binary
+// @Success 304 "Not Modified"
+// @Failure 400 {object} map[string]string "Bad Request"
+// @Failure 404 {object} map[string]string "Not Found"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/slugs/{slug}/qr [get]
+func (h *Handler) QRCode(w http.ResponseWriter, r *http.Request) {
+	format, opts, msg, ok := h.qrOptions(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	slug := chi.URLParam(r, "slug")
+	domain := h.domainParam(r)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	link, err := h.URLShortener.GetBySlug(ctx, domain, slug)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if link == nil || link.CreatedBy != username {
+		writeJSONError(w, http.StatusNotFound, "Link not found")
+		return
+	}
+	content := h.shortLink(domain, slug) + "?" + qrSourceParam + "=" + models.ClickSourceQR
+	etag := qrETag(content, format, opts)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("ETag", etag)
+	if match := r.Header.Get("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	var body []byte
+	if format == "svg" {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		body, err = qr.SVG(content, opts)
+	} else {
+		w.Header().Set("Content-Type", "image/png")
+		body, err = qr.PNG(content, opts)
+	}
+	if err != nil {
+		w.Header().Del("Content-Type")
+		w.Header().Del("ETag")
+		writeJSONError(w, http.StatusInternalServerError, "Could not render QR code")
+		return
+	}
+	_, _ = w.Write(body)
+}
+
+// qrOptions reads the rendering options of a QR code request, returning
+// a message and false when one of them is invalid.
+func (h *Handler) qrOptions(r *http.Request) (string, qr.Options, string, bool) {
+	q := r.URL.Query()
+	opts := qr.Options{
+		Size:       defaultQRSize,
+		Level:      "M",
+		Foreground: color.RGBA{A: 0xff},
+		Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+	format := strings.ToLower(q.Get("format"))
+	if format == "" {
+		format = "png"
+	}
+	if format != "png" && format != "svg" {
+		return "", opts, "format must be png or svg", false
+	}
+	if v := q.Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < minQRSize || n > maxQRSize {
+			return "", opts, fmt.Sprintf("size must be between %d and %d", minQRSize, maxQRSize), false
+		}
+		opts.Size = n
+	}
+	if v := q.Get("ecc"); v != "" {
+		opts.Level = strings.ToUpper(v)
+		if !qr.ValidLevel(opts.Level) {
+			return "", opts, "ecc must be L, M, Q or H", false
+		}
+	}
+	var err error
+	if v := q.Get("fg"); v != "" {
+		if opts.Foreground, err = qr.ParseColor(v); err != nil {
+			return "", opts, "fg must be a hex colour", false
+		}
+	}
+	if v := q.Get("bg"); v != "" {
+		if opts.Background, err = qr.ParseColor(v); err != nil {
+			return "", opts, "bg must be a hex colour", false
+		}
+	}
+	if qr.Contrast(opts.Foreground, opts.Background) < qr.MinContrast {
+		return "", opts, "fg and bg do not contrast enough to scan reliably", false
+	}
+	if logo, _ := strconv.ParseBool(q.Get("logo")); logo {
+		if h.QRLogo == nil {
+			return "", opts, "No QR code logo is configured", false
+		}
+		// The logo hides modules in the centre of the code, which only
+		// the highest error correction level reliably recovers.
+		opts.Logo = h.QRLogo
+		opts.Level = "H"
+	}
+	return format, opts, "", true
+}
+
+// qrETag identifies a rendered code by its content and options.
+func qrETag(content, format string, opts qr.Options) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s|%s|%d|%s|%v|%v|%t",
+		content, format, opts.Size, opts.Level, opts.Foreground, opts.Background, opts.Logo != nil))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// takeClickSource strips the source marker that generated QR codes add
+// to the short link, returning the request without it and the source it
+// names.  The marker is not forwarded to the destination.
+func takeClickSource(r *http.Request) (*http.Request, string) {
+	q := r.URL.Query()
+	if q.Get(qrSourceParam) != models.ClickSourceQR {
+		return r, ""
+	}
+	q.Del(qrSourceParam)
+	r = r.Clone(r.Context())
+	r.URL.RawQuery = q.Encode()
+	return r, models.ClickSourceQR
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+func newQRHandler() *Handler {
+	return NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return &models.ShortURL{Slug: slug, CreatedBy: "owner"}, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+}
+
+func TestQRCodeHandler(t *testing.T) {
+	h := newQRHandler()
+	req := withURLParams(httptest.NewRequest("GET", "/api/slugs/abc12345/qr?size=200", nil), "owner", map[string]string{"slug": "abc12345"})
+	w := httptest.NewRecorder()
+	h.QRCode(w, req)
+	res := w.Result()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", res.StatusCode, w.Body.String())
+	}
+	if res.Header.Get("Content-Type") != "image/png" {
+		t.Errorf("unexpected content type %q", res.Header.Get("Content-Type"))
+	}
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+	if img.Bounds().Dx() != 200 {
+		t.Errorf("expected 200px image, got %d", img.Bounds().Dx())
+	}
+	etag := res.Header.Get("ETag")
+	if etag == "" || !strings.Contains(res.Header.Get("Cache-Control"), "max-age") {
+		t.Fatalf("expected caching headers, got %v", res.Header)
+	}
+
+	req = withURLParams(httptest.NewRequest("GET", "/api/slugs/abc12345/qr?size=200", nil), "owner", map[string]string{"slug": "abc12345"})
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.QRCode(w, req)
+	if w.Result().StatusCode != http.StatusNotModified {
+		t.Errorf("expected 304 for matching ETag, got %d", w.Result().StatusCode)
+	}
+
+	req = withURLParams(httptest.NewRequest("GET", "/api/slugs/abc12345/qr?format=svg&fg=%23224&bg=eeeeee", nil), "owner", map[string]string{"slug": "abc12345"})
+	w = httptest.NewRecorder()
+	h.QRCode(w, req)
+	if w.Result().StatusCode != http.StatusOK || w.Result().Header.Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("expected svg, got %d %q", w.Result().StatusCode, w.Result().Header.Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), `fill="#222244"`) {
+		t.Errorf("expected foreground colour in svg: %s", w.Body.String()[:200])
+	}
+
+	req = withURLParams(httptest.NewRequest("GET", "/api/slugs/abc12345/qr", nil), "intruder", map[string]string{"slug": "abc12345"})
+	w = httptest.NewRecorder()
+	h.QRCode(w, req)
+	if w.Result().StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's link, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestQRCodeHandler_InvalidOptions(t *testing.T) {
+	h := newQRHandler()
+	for _, query := range []string{
+		"format=gif",
+		"size=10",
+		"size=5000",
+		"ecc=X",
+		"fg=zzz",
+		"fg=777777&bg=888888",
+		"logo=true",
+	} {
+		req := withURLParams(httptest.NewRequest("GET", "/api/slugs/abc12345/qr?"+query, nil), "owner", map[string]string{"slug": "abc12345"})
+		w := httptest.NewRecorder()
+		h.QRCode(w, req)
+		if w.Result().StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Result().StatusCode)
+		}
+	}
+}
+
+func TestQRCodeHandler_Logo(t *testing.T) {
+	h := newQRHandler()
+	h.QRLogo = image.NewRGBA(image.Rect(0, 0, 40, 20))
+	req := withURLParams(httptest.NewRequest("GET", "/api/slugs/abc12345/qr?logo=true&ecc=L", nil), "owner", map[string]string{"slug": "abc12345"})
+	w := httptest.NewRecorder()
+	h.QRCode(w, req)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Result().StatusCode, w.Body.String())
+	}
+	_, opts, _, _ := h.qrOptions(req)
+	if opts.Level != "H" {
+		t.Errorf("expected logo to raise error correction to H, got %s", opts.Level)
+	}
+}
+
+func TestRedirectHandler_QRSource(t *testing.T) {
+	clicks := &mockClickService{}
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return &models.ShortURL{Slug: slug, URL: "https://example.org/page", TrackClicks: true, ForwardQuery: true}, nil
+		},
+		IncrementRedirectCountFn: func(ctx context.Context, domain, slug string) error { return nil },
+	}, &mockUserService{}, "http://localhost")
+	h.Clicks = clicks
+
+	req := newRedirectRequest("abc12345")
+	req.URL.RawQuery = "src=qr&ref=flyer"
+	w := httptest.NewRecorder()
+	h.Redirect(w, req)
+	if got := w.Result().Header.Get("Location"); got != "https://example.org/page?ref=flyer" {
+		t.Errorf("expected marker to be stripped, got %s", got)
+	}
+	if len(clicks.clicks) != 1 || clicks.clicks[0].Source != models.ClickSourceQR {
+		t.Fatalf("expected a qr click, got %+v", clicks.clicks)
+	}
+
+	h.Redirect(httptest.NewRecorder(), newRedirectRequest("abc12345"))
+	if len(clicks.clicks) != 2 || clicks.clicks[1].Source != "" {
+		t.Errorf("expected a direct click, got %+v", clicks.clicks)
+	}
+}
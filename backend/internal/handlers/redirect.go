@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/pages"
+	"github.com/richmondwang/symph-url-shortener/internal/urltemplate"
+	"github.com/richmondwang/symph-url-shortener/internal/utils"
+)
+
+// redirectStatuses maps HTTP-based redirect types to status codes.
+var redirectStatuses = map[string]int{
+	models.RedirectMovedPermanently: http.StatusMovedPermanently,
+	models.RedirectFound:            http.StatusFound,
+	models.RedirectTemporary:        http.StatusTemporaryRedirect,
+	models.RedirectPermanent:        http.StatusPermanentRedirect,
+}
+
+// resolveDestination computes the destination for a redirect request
+// from base (the link URL, A/B variant or targeting rule served).
+// Destination templates are expanded first; a template that uses
+// {path} consumes the trailing path.
+func (h *Handler) resolveDestination(r *http.Request, link *models.ShortURL, base string, now time.Time) (string, bool, error) {
+	tail := chi.URLParam(r, "*")
+	if urltemplate.IsTemplate(base) {
+		tmpl, err := urltemplate.Parse(base)
+		if err != nil {
+			return "", false, err
+		}
+		base, err = tmpl.Expand(urltemplate.Vars{
+			Slug:    link.Slug,
+			Path:    tail,
+			Query:   r.URL.Query(),
+			Now:     now,
+			Country: h.Geo.Country(net.ParseIP(clientIP(r))),
+		})
+		if err != nil {
+			return "", false, err
+		}
+		if tmpl.UsesPath() {
+			tail = ""
+		}
+	}
+	return forwardedDestination(r, link, base, tail)
+}
+
+// forwardedDestination appends the trailing path to base and merges
+// the incoming query when the link allows it.  ok is false when the
+// request carries a trailing path the link does not forward.
+func forwardedDestination(r *http.Request, link *models.ShortURL, base, tail string) (string, bool, error) {
+	if tail != "" && !link.ForwardPath {
+		return "", false, nil
+	}
+	if tail == "" && (!link.ForwardQuery || r.URL.RawQuery == "") {
+		return base, true, nil
+	}
+	var incoming url.Values
+	if link.ForwardQuery {
+		incoming = r.URL.Query()
+	}
+	incomingWins := link.QueryPrecedence == models.QueryPrecedenceIncoming
+	destination, err := utils.ForwardDestination(base, tail, incoming, incomingWins)
+	if err != nil {
+		return "", false, err
+	}
+	return destination, true, nil
+}
+
+// pixelDelayMillis is how long the pixel interstitial waits before
+// forwarding, giving the pixel scripts time to fire.
+const pixelDelayMillis = 300
+
+// pixelIDPattern restricts pixel IDs to the characters used by the
+// supported ad platforms (e.g. "1234567890", "AW-123456", "G-ABC123").
+var pixelIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// privateRedirect reports whether the redirect for link must not be
+// cached because it depends on the visitor or the time of the visit:
+// password-protected links only redirect visitors who unlocked them,
+// scheduled links change destination over time, split links and
+// targeting rules pick a destination per visitor, and a template base
+// expands differently per date, country and query.
+func privateRedirect(link *models.ShortURL, base string) bool {
+	return link.PasswordHash != "" || len(link.Schedule) > 0 || len(link.Destinations) > 0 ||
+		len(link.Rules) > 0 || urltemplate.IsTemplate(base)
+}
+
+// writeRedirect sends the visitor to destination using the link's
+// redirect type.  Links with retargeting pixels are served an
+// interstitial page unless the visitor opted out of tracking.  Links
+// without a type keep the historical behaviour: 301 for permanent
+// links and 302 for links with an expiration or, when private, for
+// redirects that must not be cached (see privateRedirect).
+func writeRedirect(w http.ResponseWriter, r *http.Request, link *models.ShortURL, destination string, private bool) {
+	if !link.Pixels.IsEmpty() && trackingAllowed(r) {
+		data := pages.PixelData{
+			URL:         destination,
+			FacebookID:  link.Pixels.FacebookID,
+			GoogleID:    link.Pixels.GoogleID,
+			LinkedInID:  link.Pixels.LinkedInID,
+			DelayMillis: pixelDelayMillis,
+		}
+		if w.Header().Get("Cache-Control") == "" {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		if err := pages.Render(w, http.StatusOK, pages.PixelPage, data); err != nil {
+			http.Error(w, "Failed to render redirect page", http.StatusInternalServerError)
+		}
+		return
+	}
+	switch link.RedirectType {
+	case models.RedirectMetaRefresh, models.RedirectJavaScript:
+		name := pages.MetaRefresh
+		if link.RedirectType == models.RedirectJavaScript {
+			name = pages.JSRedirect
+		}
+		if err := pages.Render(w, http.StatusOK, name, pages.RedirectData{URL: destination}); err != nil {
+			http.Error(w, "Failed to render redirect page", http.StatusInternalServerError)
+		}
+		return
+	}
+	status, ok := redirectStatuses[link.RedirectType]
+	if !ok {
+		status = http.StatusMovedPermanently
+		if link.ExpireAt != nil || private {
+			status = http.StatusFound
+		}
+	}
+	http.Redirect(w, r, destination, status)
+}
+
+// trackingAllowed reports whether the visitor permits third-party
+// tracking.  Do Not Track and Global Privacy Control headers, or a
+// "consent" cookie set to a refusal, disable retargeting pixels.
+func trackingAllowed(r *http.Request) bool {
+	if r.Header.Get("DNT") == "1" || r.Header.Get("Sec-GPC") == "1" {
+		return false
+	}
+	if c, err := r.Cookie("consent"); err == nil {
+		switch strings.ToLower(c.Value) {
+		case "0", "false", "no", "denied", "deny":
+			return false
+		}
+	}
+	return true
+}
+
+// validatePixels checks that configured pixel IDs are well formed.
+func validatePixels(p *models.PixelConfig) (string, bool) {
+	if p == nil {
+		return "", true
+	}
+	// Checked in a fixed order so the error names the same field on
+	// every run
+	for _, f := range []struct{ name, id string }{
+		{"facebookId", p.FacebookID},
+		{"googleId", p.GoogleID},
+		{"linkedinId", p.LinkedInID},
+	} {
+		if f.id != "" && !pixelIDPattern.MatchString(f.id) {
+			return "Invalid pixel ID for " + f.name, false
+		}
+	}
+	return "", true
+}
+
+// validateRedirectType checks that redirectType is empty or supported.
+func validateRedirectType(redirectType string) (string, bool) {
+	switch redirectType {
+	case "", models.RedirectMetaRefresh, models.RedirectJavaScript:
+		return "", true
+	}
+	if _, ok := redirectStatuses[redirectType]; ok {
+		return "", true
+	}
+	return "redirectType must be one of 301, 302, 307, 308, meta or js", false
+}
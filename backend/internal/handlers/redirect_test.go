@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/services"
+)
+
+// newRedirectRequest builds a GET request for slug with chi route
+// parameters populated the way the router would.
+func newRedirectRequest(slug string) *http.Request {
+	r := httptest.NewRequest("GET", "/"+slug, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("slug", slug)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestRedirectHandler_RedirectTypes(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	tests := []struct {
+		name     string
+		link     models.ShortURL
+		status   int
+		location bool
+	}{
+		{"default permanent", models.ShortURL{}, http.StatusMovedPermanently, true},
+		{"default expiring", models.ShortURL{ExpireAt: &future}, http.StatusFound, true},
+		{"explicit 302", models.ShortURL{RedirectType: models.RedirectFound}, http.StatusFound, true},
+		{"explicit 307", models.ShortURL{RedirectType: models.RedirectTemporary}, http.StatusTemporaryRedirect, true},
+		{"explicit 308", models.ShortURL{RedirectType: models.RedirectPermanent, ExpireAt: &future}, http.StatusPermanentRedirect, true},
+		{"meta refresh", models.ShortURL{RedirectType: models.RedirectMetaRefresh}, http.StatusOK, false},
+		{"javascript", models.ShortURL{RedirectType: models.RedirectJavaScript}, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		link := tt.link
+		link.URL = "https://example.com/landing"
+		h := NewHandler(&mockURLShortener{
+			GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+				return &link, nil
+			},
+		}, &mockUserService{}, "http://localhost")
+		w := httptest.NewRecorder()
+		h.Redirect(w, newRedirectRequest("abc12345"))
+		resp := w.Result()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.status, resp.StatusCode)
+		}
+		if tt.location && resp.Header.Get("Location") != link.URL {
+			t.Errorf("%s: expected Location %s, got %s", tt.name, link.URL, resp.Header.Get("Location"))
+		}
+		if !tt.location && !strings.Contains(w.Body.String(), link.URL) {
+			t.Errorf("%s: expected page to reference destination", tt.name)
+		}
+	}
+}
+
+func TestShortenHandler_InvalidRedirectType(t *testing.T) {
+	h := NewHandler(&mockURLShortener{}, &mockUserService{}, "http://localhost")
+	body := `{"url":"https://example.com","redirectType":"303"}`
+	req := httptest.NewRequest("POST", "/api/shorten", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+	w := httptest.NewRecorder()
+	h.Shorten(w, req)
+	if w.Result().StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for unsupported redirect type, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestRedirectHandler_Pixels(t *testing.T) {
+	link := models.ShortURL{
+		URL:    "https://example.com/sale",
+		Pixels: &models.PixelConfig{FacebookID: "1234567890", GoogleID: "AW-42"},
+	}
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return &link, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+
+	w := httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("abc12345"))
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("expected interstitial 200, got %d", w.Result().StatusCode)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "fbevents.js") || !strings.Contains(body, "AW-42") {
+		t.Errorf("expected configured pixels in page")
+	}
+	if strings.Contains(body, "insight.min.js") {
+		t.Errorf("unexpected LinkedIn snippet for unconfigured pixel")
+	}
+
+	// Do Not Track skips the interstitial
+	r := newRedirectRequest("abc12345")
+	r.Header.Set("DNT", "1")
+	w = httptest.NewRecorder()
+	h.Redirect(w, r)
+	if w.Result().StatusCode != http.StatusMovedPermanently {
+		t.Errorf("expected 301 when DNT is set, got %d", w.Result().StatusCode)
+	}
+
+	// So does a refused consent cookie
+	r = newRedirectRequest("abc12345")
+	r.AddCookie(&http.Cookie{Name: "consent", Value: "denied"})
+	w = httptest.NewRecorder()
+	h.Redirect(w, r)
+	if w.Result().StatusCode != http.StatusMovedPermanently {
+		t.Errorf("expected 301 when consent is denied, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestValidatePixels(t *testing.T) {
+	if _, ok := validatePixels(&models.PixelConfig{FacebookID: "123", GoogleID: "G-ABC_1", LinkedInID: "987"}); !ok {
+		t.Error("expected valid pixel IDs")
+	}
+	if _, ok := validatePixels(&models.PixelConfig{GoogleID: "');alert(1)//"}); ok {
+		t.Error("expected invalid pixel ID to be rejected")
+	}
+	// With several invalid IDs the first field is always reported
+	for i := 0; i < 20; i++ {
+		if msg, _ := validatePixels(&models.PixelConfig{FacebookID: "a b", GoogleID: "c d", LinkedInID: "e f"}); msg != "Invalid pixel ID for facebookId" {
+			t.Fatalf("unexpected message %q", msg)
+		}
+	}
+}
+
+func TestRedirectHandler_ClickLimit(t *testing.T) {
+	remaining := 1
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return &models.ShortURL{Slug: slug, URL: "https://example.com/invite", MaxClicks: 1}, nil
+		},
+		IncrementRedirectCountFn: func(ctx context.Context, domain, slug string) error {
+			if remaining == 0 {
+				return services.ErrClickLimitReached
+			}
+			remaining--
+			return nil
+		},
+	}, &mockUserService{}, "http://localhost")
+
+	// Unfurling the link in a chat does not use up its only click
+	req := newRedirectRequest("invite12")
+	req.Header.Set("User-Agent", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)")
+	w := httptest.NewRecorder()
+	h.Redirect(w, req)
+	if w.Result().StatusCode != http.StatusOK || w.Result().Header.Get("Location") != "" || remaining != 1 {
+		t.Fatalf("expected crawler to get a preview without a click, got %d (%d left)", w.Result().StatusCode, remaining)
+	}
+	if strings.Contains(w.Body.String(), "example.com/invite") {
+		t.Error("crawler page must not reveal the destination")
+	}
+
+	w = httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("invite12"))
+	if w.Result().StatusCode != http.StatusMovedPermanently {
+		t.Fatalf("expected first click to redirect, got %d", w.Result().StatusCode)
+	}
+	if w.Result().Header.Get("Cache-Control") == "" {
+		t.Errorf("expected click-limited redirect to disable caching")
+	}
+	w = httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("invite12"))
+	if w.Result().StatusCode != http.StatusGone {
+		t.Fatalf("expected 410 once exhausted, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestRedirectHandler_ClickLimitFromRecord(t *testing.T) {
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return &models.ShortURL{Slug: slug, URL: "https://example.com", MaxClicks: 3, RedirectCount: 3}, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	w := httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("used1234"))
+	if w.Result().StatusCode != http.StatusGone {
+		t.Fatalf("expected 410 for exhausted link, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestRedirectHandler_Activation(t *testing.T) {
+	launch := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	link := models.ShortURL{URL: "https://example.com/launch", ActivateAt: &launch}
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return &link, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	now := launch.Add(-time.Minute)
+	h.Now = func() time.Time { return now }
+
+	w := httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("launch24"))
+	if w.Result().StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 before activation, got %d", w.Result().StatusCode)
+	}
+
+	link.HoldingPage = true
+	w = httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("launch24"))
+	if w.Result().StatusCode != http.StatusOK || !strings.Contains(w.Body.String(), "Coming soon") {
+		t.Fatalf("expected holding page before activation, got %d", w.Result().StatusCode)
+	}
+
+	now = launch
+	w = httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("launch24"))
+	if w.Result().StatusCode != http.StatusMovedPermanently {
+		t.Fatalf("expected redirect once active, got %d", w.Result().StatusCode)
+	}
+
+	// Scheduled links redirect temporarily so visitors see the change
+	link.Schedule = []models.ScheduledDestination{{At: launch.Add(time.Hour), URL: "https://example.com/sale"}}
+	w = httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("launch24"))
+	if w.Result().StatusCode != http.StatusFound || w.Result().Header.Get("Cache-Control") != "private, no-store" {
+		t.Fatalf("expected uncached 302 for scheduled link, got %d %q", w.Result().StatusCode, w.Result().Header.Get("Cache-Control"))
+	}
+}
+
+func TestShortenHandler_Schedule(t *testing.T) {
+	var stored models.ShortURL
+	h := NewHandler(&mockURLShortener{
+		ShortenFunc: func(ctx context.Context, req models.ShortURL) (models.ShortURL, error) {
+			stored = req
+			return req, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	body := `{"url":"https://shop.example.com/presale","activateAt":"2024-06-01T08:00:00Z",
+		"schedule":[{"at":"2024-06-02T09:00:00Z","url":"https://shop.example.com/ended"},
+		            {"at":"2024-06-01T09:00:00+08:00","url":"https://shop.example.com/sale"}]}`
+	req := httptest.NewRequest("POST", "/api/shorten", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+	w := httptest.NewRecorder()
+	h.Shorten(w, req)
+	if w.Result().StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Result().StatusCode)
+	}
+	if stored.ActivateAt == nil || len(stored.Schedule) != 2 {
+		t.Fatalf("expected activation and schedule to be stored, got %+v", stored)
+	}
+	if stored.Schedule[0].URL != "https://shop.example.com/sale" || stored.Schedule[0].At.Hour() != 1 {
+		t.Errorf("expected schedule sorted and in UTC, got %+v", stored.Schedule)
+	}
+
+	body = `{"url":"https://shop.example.com","schedule":[{"at":"tomorrow","url":"https://x.com"}]}`
+	req = httptest.NewRequest("POST", "/api/shorten", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+	w = httptest.NewRecorder()
+	h.Shorten(w, req)
+	if w.Result().StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid schedule, got %d", w.Result().StatusCode)
+	}
+}
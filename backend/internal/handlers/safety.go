@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/pages"
+)
+
+// safetyTimeout bounds the safety checks run when a link is created,
+// which may follow the destination's redirects.
+const safetyTimeout = 10 * time.Second
+
+// blockedMessage is shown to visitors of a flagged link.  The reason
+// and destination are not revealed.
+const blockedMessage = "This link has been blocked because its destination was reported as unsafe."
+
+// checkDestinations runs the shorten-time safety checks against every
+// destination of a new link.  msg describes the first blocked URL;
+// err means a check could not run.
+func (h *Handler) checkDestinations(ctx context.Context, urls []string) (string, bool, error) {
+	if h.Safety == nil {
+		return "", true, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, safetyTimeout)
+	defer cancel()
+	for _, u := range urls {
+		if u == "" {
+			continue
+		}
+		finding, err := h.Safety.Scan(ctx, u)
+		if err != nil {
+			return "", false, err
+		}
+		if finding != nil {
+			return "Destination blocked: " + finding.Reason, false, nil
+		}
+	}
+	return "", true, nil
+}
+
+// linkDestinations lists every URL a link may send visitors to.
+func linkDestinations(link models.ShortURL) []string {
+	urls := []string{link.URL, link.ExpiredRedirectURL}
+	for _, entry := range link.Schedule {
+		urls = append(urls, entry.URL)
+	}
+	for _, d := range link.Destinations {
+		urls = append(urls, d.URL)
+	}
+	for _, rule := range link.Rules {
+		urls = append(urls, rule.URL)
+	}
+	return urls
+}
+
+// flagIfUnsafe runs the redirect-time safety checks against the
+// destination about to be served.  A blocked destination flags the
+// link, which is kept for review rather than deleted.  Failures of the
+// checks themselves never block a redirect.
+func (h *Handler) flagIfUnsafe(ctx context.Context, link *models.ShortURL, destination string) bool {
+	if h.RedirectSafety == nil {
+		return false
+	}
+	finding, err := h.RedirectSafety.Scan(ctx, destination)
+	if err != nil {
+		log.Printf("safety: check failed for %s: %v", link.Slug, err)
+		return false
+	}
+	if finding == nil {
+		return false
+	}
+	flag := models.SafetyFlag{Check: finding.Check, Reason: finding.Reason, FlaggedAt: h.now()}
+	if err := h.URLShortener.Flag(ctx, link.Domain, link.Slug, flag); err != nil {
+		log.Printf("safety: failed to flag %s: %v", link.Slug, err)
+		return true
+	}
+	h.audit(ctx, models.AuditEntry{
+		Actor: "safety", Action: models.AuditFlag, Domain: link.Domain, Slug: link.Slug,
+		Note: finding.Check + ": " + finding.Reason, At: flag.FlaggedAt,
+	})
+	return true
+}
+
+// linkBlocked answers a request for a link that is flagged as unsafe
+// or disabled by a moderator.
+func linkBlocked(w http.ResponseWriter, r *http.Request, msg string) {
+	writeStatusPage(w, r, http.StatusForbidden, pages.Blocked, msg)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/safety"
+)
+
+func TestShortenHandler_SafetyChecks(t *testing.T) {
+	inserted := 0
+	h := NewHandler(&mockURLShortener{
+		ShortenFunc: func(ctx context.Context, req models.ShortURL) (models.ShortURL, error) {
+			inserted++
+			return req, nil
+		},
+	}, &mockUserService{}, "https://sym.ph")
+	h.Safety = safety.NewScanner(safety.Options{Blocklist: safety.NewDomainList("evil.com")})
+
+	for body, wantStatus := range map[string]int{
+		`{"url":"https://example.com"}`:            http.StatusCreated,
+		`{"url":"https://login.evil.com/account"}`: http.StatusBadRequest,
+		`{"url":"https://bit.ly/abc"}`:             http.StatusBadRequest,
+		`{"url":"http://192.168.0.1/"}`:            http.StatusBadRequest,
+		`{"url":"https://xn--80ak6aa92e.com/"}`:    http.StatusBadRequest,
+		`{"url":"https://evil.com/{query.q}"}`:     http.StatusBadRequest,
+		`{"url":"https://example.com","destinations":[{"url":"https://example.com/a","weight":1},{"url":"https://evil.com/b","weight":1}]}`: http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest("POST", "/api/shorten", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+		w := httptest.NewRecorder()
+		h.Shorten(w, req)
+		if w.Code != wantStatus {
+			t.Errorf("%s: expected %d, got %d: %s", body, wantStatus, w.Code, w.Body.String())
+		}
+		if wantStatus == http.StatusBadRequest && !strings.Contains(w.Body.String(), "Destination blocked") {
+			t.Errorf("%s: expected blocked message, got %s", body, w.Body.String())
+		}
+	}
+	if inserted != 1 {
+		t.Errorf("expected only the safe link to be stored, got %d", inserted)
+	}
+}
+
+func TestRedirectHandler_FlaggedLink(t *testing.T) {
+	link := models.ShortURL{
+		Slug: "flagged1",
+		URL:  "https://dest.example.org",
+		Flag: &models.SafetyFlag{Check: "blocklist", Reason: "destination domain is blocked"},
+	}
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return &link, nil
+		},
+	}, &mockUserService{}, "https://sym.ph")
+	w := httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("flagged1"))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", w.Code)
+	}
+	if w.Header().Get("Location") != "" || strings.Contains(w.Body.String(), "dest.example.org") {
+		t.Errorf("expected destination to stay hidden: %s", w.Body.String())
+	}
+}
+
+func TestRedirectHandler_RedirectTimeSafety(t *testing.T) {
+	var flagged *models.SafetyFlag
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			// The domain was added to the block list after the link was created
+			return &models.ShortURL{Slug: slug, URL: "https://later-evil.com/login"}, nil
+		},
+		FlagFunc: func(ctx context.Context, domain, slug string, flag models.SafetyFlag) error {
+			flagged = &flag
+			return nil
+		},
+	}, &mockUserService{}, "https://sym.ph")
+	h.RedirectSafety = safety.NewScanner(safety.Options{Blocklist: safety.NewDomainList("later-evil.com")})
+
+	w := httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("abc12345"))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", w.Code)
+	}
+	if flagged == nil || flagged.Check != "blocklist" {
+		t.Errorf("expected link to be flagged by the blocklist, got %+v", flagged)
+	}
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/split"
+)
+
+// maxDestinations caps the number of variants of an A/B split link.
+const maxDestinations = 10
+
+// variantCookieTTL is how long a visitor keeps their assigned variant.
+const variantCookieTTL = 30 * 24 * time.Hour
+
+// variantLabelPattern restricts variant labels to short identifiers
+// that are safe to store in a cookie.
+var variantLabelPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// destinationRequest describes one weighted variant of an A/B split
+// link.  Label defaults to "A", "B", ... in request order.
+type destinationRequest struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+	Label  string `json:"label,omitempty"`
+}
+
+// statsResponse is returned by GET /api/slugs/{slug}/stats.
+type statsResponse struct {
+	Slug     string                `json:"slug"`
+	Domain   string                `json:"domain,omitempty"`
+	Total    int64                 `json:"total"`
+	Variants []models.VariantStats `json:"variants,omitempty"`
+	Sources  []models.SourceStats  `json:"sources,omitempty"`
+}
+
+// parseDestinations validates the variants of an A/B split link and
+// applies the request's tracking parameters to each URL.
+func parseDestinations(entries []destinationRequest, t tracking) ([]models.Destination, string, bool) {
+	if len(entries) == 0 {
+		return nil, "", true
+	}
+	if len(entries) < 2 || len(entries) > maxDestinations {
+		return nil, "destinations must list between 2 and 10 URLs", false
+	}
+	dests := make([]models.Destination, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for i, e := range entries {
+		urlStr := strings.TrimSpace(e.URL)
+		if msg, ok := validateURL(urlStr); !ok {
+			return nil, "Invalid destination URL: " + msg, false
+		}
+		if e.Weight <= 0 {
+			return nil, "Destination weights must be positive", false
+		}
+		label := strings.TrimSpace(e.Label)
+		if label == "" {
+			label = string(rune('A' + i))
+		}
+		if !variantLabelPattern.MatchString(label) {
+			return nil, "Destination labels must be 1-32 letters, digits, '-' or '_'", false
+		}
+		if seen[label] {
+			return nil, "Destination labels must be unique", false
+		}
+		seen[label] = true
+		dests = append(dests, models.Destination{
+			Label:   label,
+			URL:     t.compose(urlStr),
+			Weight:  e.Weight,
+			BaseURL: urlStr,
+		})
+	}
+	return dests, "", true
+}
+
+// pickVariant chooses the destination served to this visitor.  Links
+// without variants return their URL and an empty label.  A visitor
+// keeps the variant remembered in their cookie; new visitors are
+// assigned by hashing their address and user agent, and the choice is
+// stored in a cookie scoped to the link.
+func pickVariant(w http.ResponseWriter, r *http.Request, link *models.ShortURL) (string, string) {
+	if len(link.Destinations) == 0 {
+		return link.URL, ""
+	}
+	name := variantCookieName(link.Slug)
+	i := -1
+	if c, err := r.Cookie(name); err == nil {
+		i = split.Find(link.Destinations, c.Value)
+	}
+	if i < 0 {
+		i = split.Pick(link.Destinations, clientIP(r)+"|"+r.UserAgent())
+		if i < 0 {
+			return link.URL, ""
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    link.Destinations[i].Label,
+			Path:     "/" + link.Slug,
+			MaxAge:   int(variantCookieTTL / time.Second),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	d := link.Destinations[i]
+	return d.URL, d.Label
+}
+
+func variantCookieName(slug string) string {
+	return "symph_variant_" + slug
+}
+
+// recordClick stores a click for a tracked link.  Failures are ignored
+// so that analytics never block a redirect.
+func (h *Handler) recordClick(ctx context.Context, r *http.Request, link *models.ShortURL, variant, source string) {
+	if h.Clicks == nil {
+		return
+	}
+	_ = h.Clicks.Record(ctx, models.Click{
+		Domain:    link.Domain,
+		Slug:      link.Slug,
+		At:        h.now(),
+		Variant:   variant,
+		Source:    source,
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+	})
+}
+
+// Stats returns click statistics for one of the caller's links
+// @Summary Link click statistics
+// @Description Returns the number of recorded clicks for a link owned by the caller, broken down by A/B variant for multi-destination links and by source (e.g. qr for QR code scans)
+// @Tags slugs
+// @Produce json
+// @Param slug path string true "Slug"
+// @Param domain query string false "Custom domain of the link"
+// @Success 200 {object} statsResponse
+// @Failure 404 {object} map[string]string "Not Found"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/slugs/{slug}/stats [get]
+func (h *Handler) Stats(w http.ResponseWriter, r *http.Request) {
+	if h.Clicks == nil {
+		writeJSONError(w, http.StatusNotFound, "Click statistics are not enabled")
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	slug := chi.URLParam(r, "slug")
+	domain := normalizeHost(r.URL.Query().Get("domain"))
+	if domain == h.baseHost() {
+		domain = ""
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	link, err := h.URLShortener.GetBySlug(ctx, domain, slug)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if link == nil || link.CreatedBy != username {
+		writeJSONError(w, http.StatusNotFound, "Link not found")
+		return
+	}
+	stats, err := h.Clicks.Stats(ctx, domain, slug)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(statsResponse{
+		Slug:     slug,
+		Domain:   domain,
+		Total:    stats.Total,
+		Variants: stats.Variants,
+		Sources:  stats.Sources,
+	})
+}
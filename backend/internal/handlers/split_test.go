@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+type mockClickService struct {
+	clicks []models.Click
+}
+
+func (m *mockClickService) Record(ctx context.Context, click models.Click) error {
+	m.clicks = append(m.clicks, click)
+	return nil
+}
+
+func (m *mockClickService) Stats(ctx context.Context, domain, slug string) (*models.ClickStats, error) {
+	counts := map[string]int64{}
+	var order []string
+	stats := &models.ClickStats{}
+	for _, c := range m.clicks {
+		if c.Domain != domain || c.Slug != slug {
+			continue
+		}
+		stats.Total++
+		if _, ok := counts[c.Variant]; !ok {
+			order = append(order, c.Variant)
+		}
+		counts[c.Variant]++
+	}
+	for _, v := range order {
+		stats.Variants = append(stats.Variants, models.VariantStats{Variant: v, Clicks: counts[v]})
+	}
+	return stats, nil
+}
+
+func TestShortenHandler_Destinations(t *testing.T) {
+	var saved models.ShortURL
+	h := NewHandler(&mockURLShortener{
+		ShortenFunc: func(ctx context.Context, req models.ShortURL) (models.ShortURL, error) {
+			saved = req
+			return req, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	body := `{"url":"https://example.com/a","utms":{"source":"ads"},"destinations":[{"url":"https://example.com/a","weight":70},{"url":"https://example.com/b","weight":30,"label":"blue"}]}`
+	req := httptest.NewRequest("POST", "/api/shorten", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+	w := httptest.NewRecorder()
+	h.Shorten(w, req)
+	if w.Result().StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Result().StatusCode, w.Body.String())
+	}
+	if !saved.TrackClicks {
+		t.Error("expected multi-destination link to track clicks")
+	}
+	if len(saved.Destinations) != 2 || saved.Destinations[0].Label != "A" || saved.Destinations[1].Label != "blue" {
+		t.Fatalf("unexpected destinations: %+v", saved.Destinations)
+	}
+	if saved.Destinations[1].URL != "https://example.com/b?utm_source=ads" {
+		t.Errorf("expected UTMs on variant URL, got %s", saved.Destinations[1].URL)
+	}
+}
+
+func TestParseDestinations_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []destinationRequest
+	}{
+		{"single", []destinationRequest{{URL: "https://example.com", Weight: 1}}},
+		{"zero weight", []destinationRequest{{URL: "https://example.com", Weight: 1}, {URL: "https://example.org", Weight: 0}}},
+		{"bad url", []destinationRequest{{URL: "https://example.com", Weight: 1}, {URL: "ftp://example.org", Weight: 1}}},
+		{"duplicate label", []destinationRequest{{URL: "https://example.com", Weight: 1, Label: "x"}, {URL: "https://example.org", Weight: 1, Label: "x"}}},
+		{"bad label", []destinationRequest{{URL: "https://example.com", Weight: 1, Label: "a b"}, {URL: "https://example.org", Weight: 1}}},
+	}
+	for _, tt := range tests {
+		if _, _, ok := parseDestinations(tt.entries, tracking{}); ok {
+			t.Errorf("%s: expected validation error", tt.name)
+		}
+	}
+}
+
+func TestRedirectHandler_StickyVariant(t *testing.T) {
+	link := models.ShortURL{
+		Slug:        "abc12345",
+		URL:         "https://example.com/a",
+		TrackClicks: true,
+		Destinations: []models.Destination{
+			{Label: "A", URL: "https://example.com/a", Weight: 1},
+			{Label: "B", URL: "https://example.com/b", Weight: 1},
+		},
+	}
+	clicks := &mockClickService{}
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return &link, nil
+		},
+		IncrementRedirectCountFn: func(ctx context.Context, domain, slug string) error { return nil },
+	}, &mockUserService{}, "http://localhost")
+	h.Clicks = clicks
+
+	w := httptest.NewRecorder()
+	h.Redirect(w, newRedirectRequest("abc12345"))
+	first := w.Result()
+	cookies := first.Cookies()
+	if len(cookies) != 1 || cookies[0].Name != variantCookieName("abc12345") {
+		t.Fatalf("expected variant cookie, got %+v", cookies)
+	}
+	location := first.Header.Get("Location")
+	// The variant is per visitor, so the redirect must not be cached
+	if first.StatusCode != http.StatusFound || first.Header.Get("Cache-Control") != "private, no-store" {
+		t.Errorf("expected uncached 302, got %d %q", first.StatusCode, first.Header.Get("Cache-Control"))
+	}
+
+	// A returning visitor with the cookie is pinned to the other variant
+	// even though their address would hash elsewhere
+	other := "A"
+	otherURL := "https://example.com/a"
+	if cookies[0].Value == "A" {
+		other, otherURL = "B", "https://example.com/b"
+	}
+	if location == otherURL {
+		t.Fatalf("cookie %s does not match location %s", cookies[0].Value, location)
+	}
+	req := newRedirectRequest("abc12345")
+	req.AddCookie(&http.Cookie{Name: variantCookieName("abc12345"), Value: other})
+	w = httptest.NewRecorder()
+	h.Redirect(w, req)
+	if got := w.Result().Header.Get("Location"); got != otherURL {
+		t.Errorf("expected sticky variant %s, got %s", otherURL, got)
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("expected no new cookie for an assigned visitor")
+	}
+	if len(clicks.clicks) != 2 || clicks.clicks[0].Variant != cookies[0].Value || clicks.clicks[1].Variant != other {
+		t.Errorf("expected clicks to record variants, got %+v", clicks.clicks)
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	clicks := &mockClickService{clicks: []models.Click{
+		{Slug: "abc12345", Variant: "A"},
+		{Slug: "abc12345", Variant: "B"},
+		{Slug: "abc12345", Variant: "A"},
+		{Slug: "other123", Variant: "A"},
+	}}
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return &models.ShortURL{Slug: slug, CreatedBy: "owner"}, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	h.Clicks = clicks
+
+	newReq := func(user string) *http.Request {
+		r := httptest.NewRequest("GET", "/api/slugs/abc12345/stats", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("slug", "abc12345")
+		ctx := context.WithValue(r.Context(), chi.RouteCtxKey, rctx)
+		return r.WithContext(context.WithValue(ctx, contextKey("username"), user))
+	}
+	w := httptest.NewRecorder()
+	h.Stats(w, newReq("owner"))
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Result().StatusCode)
+	}
+	var resp statsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Total != 3 || len(resp.Variants) != 2 || resp.Variants[0].Clicks != 2 {
+		t.Errorf("unexpected stats: %+v", resp)
+	}
+
+	w = httptest.NewRecorder()
+	h.Stats(w, newReq("intruder"))
+	if w.Result().StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's link, got %d", w.Result().StatusCode)
+	}
+}
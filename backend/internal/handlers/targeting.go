@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/targeting"
+)
+
+// parseRules validates a link's targeting rules and applies the
+// request's tracking parameters to each rule's destination.
+func parseRules(rules []models.TargetingRule, t tracking) ([]models.TargetingRule, string, bool) {
+	if len(rules) == 0 {
+		return nil, "", true
+	}
+	if len(rules) > targeting.MaxRules {
+		return nil, fmt.Sprintf("A link may have at most %d rules", targeting.MaxRules), false
+	}
+	parsed := make([]models.TargetingRule, 0, len(rules))
+	for i, rule := range rules {
+		rule, err := targeting.Normalize(rule)
+		if err != nil {
+			return nil, fmt.Sprintf("Invalid rule %d: %v", i+1, err), false
+		}
+		urlStr := strings.TrimSpace(rule.URL)
+		if msg, ok := validateURL(urlStr); !ok {
+			return nil, fmt.Sprintf("Invalid rule %d URL: %s", i+1, msg), false
+		}
+		rule.URL = t.compose(urlStr)
+		rule.BaseURL = urlStr
+		parsed = append(parsed, rule)
+	}
+	return parsed, "", true
+}
+
+// chooseDestination picks the URL a visitor is sent to before path
+// and query forwarding: the first matching targeting rule, else the
+// visitor's A/B variant, else the link URL.  variant is the label of
+// the A/B variant served, if any.
+func (h *Handler) chooseDestination(w http.ResponseWriter, r *http.Request, link *models.ShortURL, now time.Time) (base, variant string) {
+	if len(link.Rules) > 0 {
+		v := targeting.FromRequest(r, h.Geo, now)
+		if i := targeting.Match(link.Rules, v); i >= 0 {
+			return link.Rules[i].URL, ""
+		}
+	}
+	return pickVariant(w, r, link)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/targeting"
+)
+
+func TestRedirectHandler_TargetingRules(t *testing.T) {
+	geo, err := targeting.ParseGeoIP(strings.NewReader("203.0.113.0/24,PH\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := models.ShortURL{
+		Slug: "abc12345",
+		URL:  "https://example.com/web",
+		Rules: []models.TargetingRule{
+			{OS: []string{"ios"}, URL: "https://apps.apple.com/app/id123"},
+			{OS: []string{"android"}, URL: "https://play.google.com/store/apps/details?id=com.example"},
+			{Countries: []string{"PH"}, URL: "https://example.com/ph"},
+		},
+	}
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return &link, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	h.Geo = geo
+
+	tests := []struct {
+		name   string
+		ua     string
+		remote string
+		want   string
+	}{
+		{"ios", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)", "192.0.2.1:1000", "https://apps.apple.com/app/id123"},
+		{"android", "Mozilla/5.0 (Linux; Android 14; Pixel 8) Mobile", "192.0.2.1:1000", "https://play.google.com/store/apps/details?id=com.example"},
+		{"philippines desktop", "Mozilla/5.0 (Windows NT 10.0; Win64; x64)", "203.0.113.5:1000", "https://example.com/ph"},
+		{"default", "Mozilla/5.0 (Windows NT 10.0; Win64; x64)", "192.0.2.1:1000", "https://example.com/web"},
+	}
+	for _, tt := range tests {
+		req := newRedirectRequest("abc12345")
+		req.Header.Set("User-Agent", tt.ua)
+		req.RemoteAddr = tt.remote
+		w := httptest.NewRecorder()
+		h.Redirect(w, req)
+		if got := w.Result().Header.Get("Location"); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+		// The chosen destination must not stick in any cache
+		if w.Result().StatusCode != http.StatusFound || w.Result().Header.Get("Cache-Control") != "private, no-store" {
+			t.Errorf("%s: expected uncached 302, got %d %q", tt.name, w.Result().StatusCode, w.Result().Header.Get("Cache-Control"))
+		}
+	}
+}
+
+func TestShortenHandler_Rules(t *testing.T) {
+	var saved models.ShortURL
+	h := NewHandler(&mockURLShortener{
+		ShortenFunc: func(ctx context.Context, req models.ShortURL) (models.ShortURL, error) {
+			saved = req
+			return req, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	shorten := func(body string) int {
+		req := httptest.NewRequest("POST", "/api/shorten", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+		w := httptest.NewRecorder()
+		h.Shorten(w, req)
+		return w.Result().StatusCode
+	}
+	status := shorten(`{"url":"https://example.com","rules":[{"os":["iOS"],"countries":["ph"],"url":"https://apps.apple.com/app/id123"}]}`)
+	if status != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", status)
+	}
+	if len(saved.Rules) != 1 || saved.Rules[0].OS[0] != "ios" || saved.Rules[0].Countries[0] != "PH" {
+		t.Errorf("expected normalised rule, got %+v", saved.Rules)
+	}
+	for _, body := range []string{
+		`{"url":"https://example.com","rules":[{"url":"https://example.org"}]}`,
+		`{"url":"https://example.com","rules":[{"os":["palm"],"url":"https://example.org"}]}`,
+		`{"url":"https://example.com","rules":[{"os":["ios"],"url":"javascript:alert(1)"}]}`,
+	} {
+		if status := shorten(body); status != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", body, status)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+func TestShortenHandler_Template(t *testing.T) {
+	var saved models.ShortURL
+	h := NewHandler(&mockURLShortener{
+		ShortenFunc: func(ctx context.Context, req models.ShortURL) (models.ShortURL, error) {
+			saved = req
+			return req, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	shorten := func(body string) int {
+		req := httptest.NewRequest("POST", "/api/shorten", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), contextKey("username"), "tester"))
+		w := httptest.NewRecorder()
+		h.Shorten(w, req)
+		return w.Code
+	}
+	if code := shorten(`{"url":"https://shop.example.com/p/{query.id}?ref={slug}","utms":{"source":"qr"}}`); code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+	if saved.URL != "https://shop.example.com/p/{query.id}?ref={slug}&utm_source=qr" {
+		t.Errorf("unexpected stored template %q", saved.URL)
+	}
+	for _, body := range []string{
+		`{"url":"https://{query.host}/p"}`,
+		`{"url":"{query.u}"}`,
+		`{"url":"https://example.com/{secret}"}`,
+		`{"url":"https://example.com","expiredRedirectUrl":"https://example.com/{slug}"}`,
+	} {
+		if code := shorten(body); code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", body, code)
+		}
+	}
+}
+
+func TestRedirectHandler_Template(t *testing.T) {
+	link := models.ShortURL{
+		Slug: "product1",
+		URL:  "https://shop.example.com/p/{query.id}?ref={slug}&utm_source=qr",
+	}
+	docs := models.ShortURL{
+		Slug: "docslink",
+		URL:  "https://docs.example.com/{path}",
+	}
+	h := NewHandler(&mockURLShortener{
+		GetBySlugFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			if slug == docs.Slug {
+				return &docs, nil
+			}
+			return &link, nil
+		},
+	}, &mockUserService{}, "http://localhost")
+
+	req := newRedirectRequest("product1")
+	req.URL.RawQuery = "id=12%2F3"
+	w := httptest.NewRecorder()
+	h.Redirect(w, req)
+	if got := w.Header().Get("Location"); got != "https://shop.example.com/p/12%2F3?ref=product1&utm_source=qr" {
+		t.Errorf("unexpected expansion %q", got)
+	}
+	if w.Code != http.StatusFound || w.Header().Get("Cache-Control") != "private, no-store" {
+		t.Errorf("expected uncached 302 for template, got %d %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+
+	// {path} consumes the trailing path even without forwardPath
+	req = newRedirectRequest("docslink")
+	chi.RouteContext(req.Context()).URLParams.Add("*", "guide/../../intro")
+	w = httptest.NewRecorder()
+	h.Redirect(w, req)
+	if got := w.Header().Get("Location"); got != "https://docs.example.com/intro" {
+		t.Errorf("unexpected path expansion %q", got)
+	}
+}
@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+// Limits on UTM presets and vocabularies.
+const (
+	maxPresetName      = 100
+	maxVocabularyTerms = 100
+)
+
+// utmPresetRequest is the payload for creating a UTM preset.  Team
+// shares the preset with the members of one of the caller's teams.
+type utmPresetRequest struct {
+	Name   string            `json:"name"`
+	Team   string            `json:"team,omitempty"`
+	UTMs   map[string]string `json:"utms,omitempty"`
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// utmVocabularyRequest is the payload for setting a UTM vocabulary.
+type utmVocabularyRequest struct {
+	Allowed map[string][]string `json:"allowed"`
+	Enforce bool                `json:"enforce"`
+}
+
+// CreateUTMPreset saves a UTM preset
+// @Summary Create a UTM preset
+// @Description Saves UTM values (source, medium, campaign, term, content) and custom tracking parameters that links can be created from with presetId.  With team the preset is shared with that team's members.  The values must be allowed by the caller's enforced vocabularies.
+// @Tags utm
+// @Accept json
+// @Produce json
+// @Param request body utmPresetRequest true "Preset"
+// @Success 201 {object} models.UTMPreset
+// @Failure 400 {object} map[string]string "Bad Request"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/utm/presets [post]
+func (h *Handler) CreateUTMPreset(w http.ResponseWriter, r *http.Request) {
+	if h.UTM == nil {
+		writeJSONError(w, http.StatusNotFound, "UTM presets are not enabled")
+		return
+	}
+	var req utmPresetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		writeJSONError(w, http.StatusBadRequest, "Preset name is required")
+		return
+	}
+	if utf8.RuneCountInString(name) > maxPresetName {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Preset name must be at most %d characters", maxPresetName))
+		return
+	}
+	utms, msg, ok := normalizePresetUTMs(req.UTMs)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if msg, ok := validateParams(req.Params); !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	teams := h.userTeams(ctx, username)
+	team := strings.TrimSpace(req.Team)
+	if team != "" && !containsString(teams, team) {
+		writeJSONError(w, http.StatusForbidden, "You are not a member of this team")
+		return
+	}
+	vocabs, err := h.UTM.Vocabularies(ctx, username, teams)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if msg, ok := checkVocabularies(vocabs, utms); !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	preset, err := h.UTM.CreatePreset(ctx, models.UTMPreset{
+		Owner:     username,
+		Team:      team,
+		Name:      name,
+		UTMs:      utms,
+		Params:    overlay(nil, req.Params, false),
+		CreatedAt: h.now(),
+	})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(preset)
+}
+
+// ListUTMPresets lists the UTM presets available to the caller
+// @Summary List UTM presets
+// @Description Lists the caller's UTM presets and those shared with the caller's teams, sorted by name.
+// @Tags utm
+// @Produce json
+// @Success 200 {array} models.UTMPreset
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/utm/presets [get]
+func (h *Handler) ListUTMPresets(w http.ResponseWriter, r *http.Request) {
+	if h.UTM == nil {
+		writeJSONError(w, http.StatusNotFound, "UTM presets are not enabled")
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	presets, err := h.UTM.ListPresets(ctx, username, h.userTeams(ctx, username))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(presets)
+}
+
+// DeleteUTMPreset deletes one of the caller's UTM presets
+// @Summary Delete a UTM preset
+// @Description Deletes a preset the caller created.  Links created from it keep their UTMs.
+// @Tags utm
+// @Param id path string true "Preset ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} map[string]string "Not Found"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/utm/presets/{id} [delete]
+func (h *Handler) DeleteUTMPreset(w http.ResponseWriter, r *http.Request) {
+	if h.UTM == nil {
+		writeJSONError(w, http.StatusNotFound, "UTM presets are not enabled")
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "UTM preset not found")
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	deleted, err := h.UTM.DeletePreset(ctx, username, id)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !deleted {
+		writeJSONError(w, http.StatusNotFound, "UTM preset not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListUTMVocabularies lists the UTM vocabularies that apply to the caller
+// @Summary List UTM vocabularies
+// @Description Lists the caller's own UTM vocabulary and those of the caller's teams.  Enforced vocabularies restrict the UTM values of new links and UTM edits; the others are suggestions.
+// @Tags utm
+// @Produce json
+// @Success 200 {array} models.UTMVocabulary
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/utm/vocabularies [get]
+func (h *Handler) ListUTMVocabularies(w http.ResponseWriter, r *http.Request) {
+	if h.UTM == nil {
+		writeJSONError(w, http.StatusNotFound, "UTM presets are not enabled")
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	vocabs, err := h.UTM.Vocabularies(ctx, username, h.userTeams(ctx, username))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(vocabs)
+}
+
+// SetUTMVocabulary replaces a UTM vocabulary
+// @Summary Set a UTM vocabulary
+// @Description Replaces the caller's own UTM vocabulary, or with team the vocabulary of one of the caller's teams; only admins may set a team's vocabulary.  allowed maps UTM keys (source, medium, campaign, term, content) to their allowed values; keys left out accept any value.
+// @Tags utm
+// @Accept json
+// @Produce json
+// @Param team query string false "Team whose vocabulary to set"
+// @Param request body utmVocabularyRequest true "Vocabulary"
+// @Success 200 {object} models.UTMVocabulary
+// @Failure 400 {object} map[string]string "Bad Request"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/utm/vocabularies [put]
+func (h *Handler) SetUTMVocabulary(w http.ResponseWriter, r *http.Request) {
+	if h.UTM == nil {
+		writeJSONError(w, http.StatusNotFound, "UTM presets are not enabled")
+		return
+	}
+	var req utmVocabularyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	allowed, msg, ok := normalizeVocabulary(req.Allowed)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	vocab := models.UTMVocabulary{
+		Owner:     username,
+		Allowed:   allowed,
+		Enforce:   req.Enforce,
+		UpdatedBy: username,
+		UpdatedAt: h.now(),
+	}
+	if team := strings.TrimSpace(r.URL.Query().Get("team")); team != "" {
+		// A team vocabulary binds every member's links, so ordinary
+		// members may not change it
+		user, err := h.UserService.GetByUsername(ctx, username)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if user == nil || !containsString(user.Teams, team) {
+			writeJSONError(w, http.StatusForbidden, "You are not a member of this team")
+			return
+		}
+		if !user.IsAdmin() {
+			writeJSONError(w, http.StatusForbidden, "Only admins may set a team's UTM vocabulary")
+			return
+		}
+		vocab.Owner, vocab.Team = "", team
+	}
+	if err := h.UTM.SetVocabulary(ctx, vocab); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(vocab)
+}
+
+// presetForUser loads the preset with the hex ID requested if username
+// owns it or belongs to the team it is shared with.  On failure it
+// returns the HTTP status and message to respond with.
+func (h *Handler) presetForUser(ctx context.Context, username string, teams []string, requested string) (*models.UTMPreset, int, string) {
+	id, err := primitive.ObjectIDFromHex(requested)
+	if err != nil {
+		return nil, http.StatusNotFound, "UTM preset not found"
+	}
+	preset, err := h.UTM.GetPreset(ctx, id)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Database error"
+	}
+	if preset == nil || (preset.Owner != username && (preset.Team == "" || !containsString(teams, preset.Team))) {
+		return nil, http.StatusNotFound, "UTM preset not found"
+	}
+	return preset, 0, ""
+}
+
+// normalizePresetUTMs keys the UTMs of a preset by the standard UTM keys
+// without their "utm_" prefix and drops empty values.
+func normalizePresetUTMs(utms map[string]string) (map[string]string, string, bool) {
+	out := make(map[string]string, len(utms))
+	for k, v := range utms {
+		key, ok := utmKey(k)
+		if !ok {
+			return nil, fmt.Sprintf("Unknown UTM key %q", k), false
+		}
+		v = strings.TrimSpace(v)
+		if utf8.RuneCountInString(v) > maxTrackingValue {
+			return nil, fmt.Sprintf("UTM values must be at most %d characters", maxTrackingValue), false
+		}
+		if v != "" {
+			out[key] = v
+		}
+	}
+	if len(out) == 0 {
+		return nil, "", true
+	}
+	return out, "", true
+}
+
+// normalizeVocabulary keys a vocabulary by the standard UTM keys and
+// trims and dedupes its values.
+func normalizeVocabulary(allowed map[string][]string) (map[string][]string, string, bool) {
+	out := make(map[string][]string, len(allowed))
+	for k, values := range allowed {
+		key, ok := utmKey(k)
+		if !ok {
+			return nil, fmt.Sprintf("Unknown UTM key %q", k), false
+		}
+		if len(values) > maxVocabularyTerms {
+			return nil, fmt.Sprintf("A UTM key may have at most %d allowed values", maxVocabularyTerms), false
+		}
+		terms := out[key]
+		for _, v := range values {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				return nil, "Allowed values must not be empty", false
+			}
+			if utf8.RuneCountInString(v) > maxTrackingValue {
+				return nil, fmt.Sprintf("UTM values must be at most %d characters", maxTrackingValue), false
+			}
+			if !containsString(terms, v) {
+				terms = append(terms, v)
+			}
+		}
+		out[key] = terms
+	}
+	return out, "", true
+}
+
+// utmKey returns k without its "utm_" prefix if it names one of the
+// standard UTM keys.
+func utmKey(k string) (string, bool) {
+	key := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(k)), "utm_")
+	return key, containsString(models.UTMKeys, key)
+}
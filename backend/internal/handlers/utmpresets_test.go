@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+type mockUTMService struct {
+	presets []models.UTMPreset
+	vocabs  []models.UTMVocabulary
+}
+
+func (m *mockUTMService) CreatePreset(ctx context.Context, preset models.UTMPreset) (models.UTMPreset, error) {
+	preset.ID = primitive.NewObjectID()
+	m.presets = append(m.presets, preset)
+	return preset, nil
+}
+
+func (m *mockUTMService) GetPreset(ctx context.Context, id primitive.ObjectID) (*models.UTMPreset, error) {
+	for i, p := range m.presets {
+		if p.ID == id {
+			return &m.presets[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockUTMService) ListPresets(ctx context.Context, owner string, teams []string) ([]models.UTMPreset, error) {
+	var out []models.UTMPreset
+	for _, p := range m.presets {
+		if p.Owner == owner || containsString(teams, p.Team) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockUTMService) DeletePreset(ctx context.Context, owner string, id primitive.ObjectID) (bool, error) {
+	for i, p := range m.presets {
+		if p.Owner == owner && p.ID == id {
+			m.presets = append(m.presets[:i], m.presets[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockUTMService) Vocabularies(ctx context.Context, owner string, teams []string) ([]models.UTMVocabulary, error) {
+	var out []models.UTMVocabulary
+	for _, v := range m.vocabs {
+		if (v.Team == "" && v.Owner == owner) || (v.Owner == "" && containsString(teams, v.Team)) {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockUTMService) SetVocabulary(ctx context.Context, vocab models.UTMVocabulary) error {
+	for i, v := range m.vocabs {
+		if v.Owner == vocab.Owner && v.Team == vocab.Team {
+			m.vocabs[i] = vocab
+			return nil
+		}
+	}
+	m.vocabs = append(m.vocabs, vocab)
+	return nil
+}
+
+// brandUsers returns a user service where every user is in the "brand"
+// team.
+func brandUsers() *mockUserService {
+	return &mockUserService{GetByUsernameFn: func(ctx context.Context, username string) (*models.User, error) {
+		return &models.User{Username: username, Teams: []string{"brand"}}, nil
+	}}
+}
+
+func TestUTMPresetHandlers(t *testing.T) {
+	utm := &mockUTMService{}
+	h := NewHandler(&mockURLShortener{}, brandUsers(), "http://localhost")
+	h.UTM = utm
+	create := func(user, body string) (int, models.UTMPreset) {
+		req := withURLParams(httptest.NewRequest("POST", "/api/utm/presets", bytes.NewBufferString(body)), user, nil)
+		w := httptest.NewRecorder()
+		h.CreateUTMPreset(w, req)
+		var preset models.UTMPreset
+		_ = json.NewDecoder(w.Body).Decode(&preset)
+		return w.Code, preset
+	}
+	code, preset := create("tester", `{"name":"Newsletter","team":"brand","utms":{"utm_source":" news ","Medium":"email","term":""},"params":{"ref":"nl"}}`)
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+	if !reflect.DeepEqual(preset.UTMs, map[string]string{"source": "news", "medium": "email"}) || preset.Params["ref"] != "nl" || preset.Team != "brand" {
+		t.Errorf("unexpected preset %+v", preset)
+	}
+	for _, body := range []string{
+		`{"name":""}`,
+		`{"name":"Bad","utms":{"channel":"x"}}`,
+		`{"name":"Bad","params":{"utm_id":"1"}}`,
+	} {
+		if code, _ := create("tester", body); code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", body, code)
+		}
+	}
+	if code, _ := create("tester", `{"name":"Other","team":"sales"}`); code != http.StatusForbidden {
+		t.Errorf("expected 403 for another team, got %d", code)
+	}
+
+	// Team members see shared presets but cannot delete them
+	req := withURLParams(httptest.NewRequest("GET", "/api/utm/presets", nil), "teammate", nil)
+	w := httptest.NewRecorder()
+	h.ListUTMPresets(w, req)
+	var presets []models.UTMPreset
+	_ = json.NewDecoder(w.Body).Decode(&presets)
+	if len(presets) != 1 || presets[0].ID != preset.ID {
+		t.Errorf("expected shared preset listed, got %+v", presets)
+	}
+	remove := func(user string) int {
+		req := withURLParams(httptest.NewRequest("DELETE", "/api/utm/presets/"+preset.ID.Hex(), nil), user, map[string]string{"id": preset.ID.Hex()})
+		w := httptest.NewRecorder()
+		h.DeleteUTMPreset(w, req)
+		return w.Code
+	}
+	if code := remove("teammate"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for teammate delete, got %d", code)
+	}
+	if code := remove("tester"); code != http.StatusNoContent || len(utm.presets) != 0 {
+		t.Errorf("expected owner delete, got %d", code)
+	}
+}
+
+func TestSetUTMVocabularyHandler(t *testing.T) {
+	utm := &mockUTMService{}
+	h := NewHandler(&mockURLShortener{}, &mockUserService{GetByUsernameFn: func(ctx context.Context, username string) (*models.User, error) {
+		user := &models.User{Username: username, Teams: []string{"brand"}}
+		if username == "lead" {
+			user.Role = models.RoleAdmin
+		}
+		return user, nil
+	}}, "http://localhost")
+	h.UTM = utm
+	setAs := func(user, query, body string) int {
+		req := withURLParams(httptest.NewRequest("PUT", "/api/utm/vocabularies"+query, bytes.NewBufferString(body)), user, nil)
+		w := httptest.NewRecorder()
+		h.SetUTMVocabulary(w, req)
+		return w.Code
+	}
+	set := func(query, body string) int { return setAs("lead", query, body) }
+	// Team members who are not admins may not change the team's vocabulary
+	if code := setAs("tester", "?team=brand", `{"allowed":{"medium":["print"]},"enforce":true}`); code != http.StatusForbidden || len(utm.vocabs) != 0 {
+		t.Fatalf("expected 403 for a non-admin member, got %d", code)
+	}
+	if code := setAs("tester", "", `{"allowed":{"medium":["print"]}}`); code != http.StatusOK {
+		t.Fatalf("expected 200 for the member's own vocabulary, got %d", code)
+	}
+	utm.vocabs = nil
+	if code := set("?team=brand", `{"allowed":{"utm_medium":["email"," social","email"]},"enforce":true}`); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	want := models.UTMVocabulary{Team: "brand", Allowed: map[string][]string{"medium": {"email", "social"}}, Enforce: true}
+	if got := utm.vocabs[0]; got.Team != want.Team || got.Owner != "" || !reflect.DeepEqual(got.Allowed, want.Allowed) || !got.Enforce {
+		t.Errorf("unexpected vocabulary %+v", got)
+	}
+	if code := set("", `{"allowed":{"channel":["x"]}}`); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown key, got %d", code)
+	}
+	if code := set("?team=sales", `{"allowed":{}}`); code != http.StatusForbidden {
+		t.Errorf("expected 403 for another team, got %d", code)
+	}
+}
+
+func TestShortenHandler_UTMPreset(t *testing.T) {
+	utm := &mockUTMService{
+		vocabs: []models.UTMVocabulary{{Team: "brand", Allowed: map[string][]string{"medium": {"email", "social"}}, Enforce: true}},
+	}
+	preset, _ := utm.CreatePreset(context.Background(), models.UTMPreset{
+		Owner:  "someone",
+		Team:   "brand",
+		Name:   "Newsletter",
+		UTMs:   map[string]string{"source": "news", "medium": "email", "campaign": "spring"},
+		Params: map[string]string{"ref": "nl"},
+	})
+	private, _ := utm.CreatePreset(context.Background(), models.UTMPreset{Owner: "someone", Name: "Private"})
+	var saved models.ShortURL
+	h := NewHandler(&mockURLShortener{
+		ShortenFunc: func(ctx context.Context, req models.ShortURL) (models.ShortURL, error) {
+			saved = req
+			return req, nil
+		},
+	}, brandUsers(), "http://localhost")
+	h.UTM = utm
+	shorten := func(body string) (int, string) {
+		req := withURLParams(httptest.NewRequest("POST", "/api/shorten", bytes.NewBufferString(body)), "tester", nil)
+		w := httptest.NewRecorder()
+		h.Shorten(w, req)
+		var resp map[string]string
+		_ = json.NewDecoder(w.Body).Decode(&resp)
+		return w.Code, resp["error"]
+	}
+	code, _ := shorten(`{"url":"https://example.com/","presetId":"` + preset.ID.Hex() + `","utms":{"utm_campaign":"summer","term":"","content":"hero"},"params":{"cid":"7"}}`)
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+	if saved.URL != "https://example.com/?utm_source=news&utm_medium=email&utm_campaign=summer&utm_content=hero&cid=7&ref=nl" {
+		t.Errorf("unexpected destination %q", saved.URL)
+	}
+	if saved.UTMPresetID == nil || *saved.UTMPresetID != preset.ID || saved.Params["ref"] != "nl" {
+		t.Errorf("expected preset recorded, got %+v", saved)
+	}
+	if code, msg := shorten(`{"url":"https://example.com/","utms":{"medium":"print"}}`); code != http.StatusBadRequest || msg != `"print" is not an allowed value for utm_medium` {
+		t.Errorf("expected vocabulary rejection, got %d %q", code, msg)
+	}
+	// Prefixed or differently cased keys are checked against the same vocabulary
+	if code, msg := shorten(`{"url":"https://example.com/","utms":{"UTM_Medium":"print"}}`); code != http.StatusBadRequest || msg != `"print" is not an allowed value for utm_medium` {
+		t.Errorf("expected vocabulary rejection for a prefixed key, got %d %q", code, msg)
+	}
+	// Non-standard UTM keys are not restricted by vocabularies
+	if code, _ := shorten(`{"url":"https://example.com/","utms":{"id":"x"}}`); code != http.StatusCreated || saved.URL != "https://example.com/?utm_id=x" {
+		t.Errorf("expected utm_id passed through, got %d %q", code, saved.URL)
+	}
+	if code, msg := shorten(`{"url":"https://example.com/","presetId":"` + private.ID.Hex() + `"}`); code != http.StatusBadRequest || msg != "Unknown UTM preset" {
+		t.Errorf("expected unknown preset for another user's preset, got %d %q", code, msg)
+	}
+	utm.vocabs[0].Enforce = false
+	if code, _ := shorten(`{"url":"https://example.com/","utms":{"medium":"print"}}`); code != http.StatusCreated {
+		t.Errorf("expected unenforced vocabulary to be a suggestion, got %d", code)
+	}
+}
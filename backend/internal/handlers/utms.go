@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/services"
+	"github.com/richmondwang/symph-url-shortener/internal/utils"
+)
+
+// Limits on the tracking parameters of a link, in characters except for
+// maxParams.
+const (
+	maxParams        = 10
+	maxParamKey      = 50
+	maxTrackingValue = 200
+)
+
+// tracking is the UTM and custom parameters composed into each of a
+// link's destinations.  PresetID is the UTM preset they came from.
+type tracking struct {
+	UTMs     map[string]string
+	Params   map[string]string
+	Keep     bool
+	PresetID *primitive.ObjectID
+}
+
+// compose merges the tracking parameters into base.
+func (t tracking) compose(base string) string {
+	return utils.MergeTracking(base, t.UTMs, t.Params, t.Keep)
+}
+
+// utmsRequest replaces the UTM parameters of a link.  Params replaces
+// the custom tracking parameters; they are kept when it is omitted and
+// no preset is given.
+type utmsRequest struct {
+	UTMs      map[string]string `json:"utms"`
+	Params    map[string]string `json:"params,omitempty"`
+	PresetID  string            `json:"presetId,omitempty"`
+	UTMPolicy string            `json:"utmPolicy,omitempty"`
+}
+
+// UpdateUTMs edits the UTM parameters of a link
+// @Summary Edit a link's UTMs
+// @Description Replaces the UTM and custom tracking parameters of a link and recomposes its destination, split destinations, targeting rules and schedule from their base URLs.  utmPolicy defaults to the link's current policy.  Values from presetId are used where utms and params leave a key unset, and enforced UTM vocabularies apply.
+// @Tags slugs
+// @Accept json
+// @Produce json
+// @Param slug path string true "Slug"
+// @Param domain query string false "Custom domain of the link"
+// @Param request body utmsRequest true "New UTMs"
+// @Success 200 {object} SlugInfo
+// @Failure 400 {object} map[string]string "Bad Request"
+// @Failure 404 {object} map[string]string "Not Found"
+// @Failure 500 {object} map[string]string "Internal Server Error"
+// @Router /api/slugs/{slug}/utms [put]
+func (h *Handler) UpdateUTMs(w http.ResponseWriter, r *http.Request) {
+	var req utmsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	policy := strings.TrimSpace(req.UTMPolicy)
+	if msg, ok := validateUTMPolicy(policy); !ok {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	username, _ := r.Context().Value(contextKey("username")).(string)
+	slug := chi.URLParam(r, "slug")
+	domain := h.domainParam(r)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	// The stored link, not GetBySlug's view with the scheduled
+	// destination applied, is what gets recomposed and written back
+	link, err := h.URLShortener.GetStored(ctx, domain, slug)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if link == nil || link.CreatedBy != username {
+		writeJSONError(w, http.StatusNotFound, "Link not found")
+		return
+	}
+	if policy == "" {
+		policy = link.UTMPolicy
+	}
+	params := req.Params
+	if params == nil && strings.TrimSpace(req.PresetID) == "" {
+		params = link.Params
+	}
+	t, status, msg := h.resolveTracking(ctx, username, req.PresetID, req.UTMs, params)
+	if status != 0 {
+		writeJSONError(w, status, msg)
+		return
+	}
+	t.Keep = policy == models.UTMPolicyKeep
+	updated := recomposeUTMs(*link, t, policy)
+	if err := h.URLShortener.UpdateUTMs(ctx, domain, slug, updated); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.slugInfo(updated))
+}
+
+// recomposeUTMs returns link with t applied under policy to its main
+// URL and every destination, rule and scheduled URL.  Links created
+// before base URLs were stored recover them by removing the old
+// tracking parameters.
+func recomposeUTMs(link models.ShortURL, t tracking, policy string) models.ShortURL {
+	oldUTMs, oldParams := link.UTMs, link.Params
+	compose := func(base, url string) (string, string) {
+		if base == "" {
+			base = utils.RemoveTracking(url, oldUTMs, oldParams)
+		}
+		return base, t.compose(base)
+	}
+	link.BaseURL, link.URL = compose(link.BaseURL, link.URL)
+	link.URLKey = services.URLKey(link.URL)
+	link.UTMs = t.UTMs
+	link.Params = t.Params
+	link.UTMPresetID = t.PresetID
+	link.UTMPolicy = policy
+
+	// Copy the slices so the caller's link is left untouched.
+	link.Destinations = append([]models.Destination(nil), link.Destinations...)
+	for i := range link.Destinations {
+		d := &link.Destinations[i]
+		d.BaseURL, d.URL = compose(d.BaseURL, d.URL)
+	}
+	link.Rules = append([]models.TargetingRule(nil), link.Rules...)
+	for i := range link.Rules {
+		rule := &link.Rules[i]
+		rule.BaseURL, rule.URL = compose(rule.BaseURL, rule.URL)
+	}
+	link.Schedule = append([]models.ScheduledDestination(nil), link.Schedule...)
+	for i := range link.Schedule {
+		s := &link.Schedule[i]
+		s.BaseURL, s.URL = compose(s.BaseURL, s.URL)
+	}
+	return link
+}
+
+// resolveTracking builds the tracking parameters of a request: the
+// values of the preset named by presetID, if any, overridden key by key
+// by utms and params.  The standard keys of utms are normalised
+// first.  An empty value in utms or params removes the
+// preset's value.  The UTMs must be allowed by every enforced
+// vocabulary of the user and their teams.  On failure it returns the
+// HTTP status and message to respond with.
+func (h *Handler) resolveTracking(ctx context.Context, username, presetID string, utms, params map[string]string) (tracking, int, string) {
+	var t tracking
+	utms, msg, ok := normalizeRequestUTMs(utms)
+	if !ok {
+		return t, http.StatusBadRequest, msg
+	}
+	if msg, ok := validateParams(params); !ok {
+		return t, http.StatusBadRequest, msg
+	}
+	t.UTMs, t.Params = utms, params
+	presetID = strings.TrimSpace(presetID)
+	if presetID == "" && h.UTM == nil {
+		return t, 0, ""
+	}
+	teams := h.userTeams(ctx, username)
+	if presetID != "" {
+		if h.UTM == nil {
+			return t, http.StatusBadRequest, "UTM presets are not enabled"
+		}
+		preset, status, msg := h.presetForUser(ctx, username, teams, presetID)
+		if status != 0 {
+			if status == http.StatusNotFound {
+				status, msg = http.StatusBadRequest, "Unknown UTM preset"
+			}
+			return t, status, msg
+		}
+		t.UTMs = overlay(preset.UTMs, utms, true)
+		t.Params = overlay(preset.Params, params, false)
+		t.PresetID = &preset.ID
+	}
+	vocabs, err := h.UTM.Vocabularies(ctx, username, teams)
+	if err != nil {
+		return t, http.StatusInternalServerError, "Database error"
+	}
+	if msg, ok := checkVocabularies(vocabs, t.UTMs); !ok {
+		return t, http.StatusBadRequest, msg
+	}
+	return t, 0, ""
+}
+
+// overlay returns base with the entries of over applied on top; empty
+// values in over remove the key.  With utmKeys, "utm_source" and
+// "source" name the same key.
+func overlay(base, over map[string]string, utmKeys bool) map[string]string {
+	key := func(k string) string {
+		if utmKeys {
+			return strings.TrimPrefix(k, "utm_")
+		}
+		return k
+	}
+	out := make(map[string]string, len(base)+len(over))
+	for k, v := range base {
+		out[key(k)] = v
+	}
+	for k, v := range over {
+		if v == "" {
+			delete(out, key(k))
+			continue
+		}
+		out[key(k)] = v
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// checkVocabularies reports the first UTM value, in key order, that an
+// enforced vocabulary does not allow.
+func checkVocabularies(vocabs []models.UTMVocabulary, utms map[string]string) (string, bool) {
+	keys := make([]string, 0, len(utms))
+	for k, v := range utms {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, vocab := range vocabs {
+		if !vocab.Enforce {
+			continue
+		}
+		for _, k := range keys {
+			if !vocab.Allows(k, utms[k]) {
+				return fmt.Sprintf("%q is not an allowed value for utm_%s", utms[k], strings.TrimPrefix(k, "utm_")), false
+			}
+		}
+	}
+	return "", true
+}
+
+// normalizeRequestUTMs keys the standard UTMs of a request by their
+// names without the "utm_" prefix and trims their values, so that
+// vocabularies see every value however it was spelled.  Other keys,
+// such as utm_id, are passed through unchanged.  Unlike
+// normalizePresetUTMs it keeps empty values, which remove a preset's
+// value.
+func normalizeRequestUTMs(utms map[string]string) (map[string]string, string, bool) {
+	if utms == nil {
+		return nil, "", true
+	}
+	out := make(map[string]string, len(utms))
+	for k, v := range utms {
+		key, ok := utmKey(k)
+		if !ok {
+			out[k] = v
+			continue
+		}
+		if _, dup := out[key]; dup {
+			return nil, fmt.Sprintf("utm_%s is given more than once", key), false
+		}
+		v = strings.TrimSpace(v)
+		if utf8.RuneCountInString(v) > maxTrackingValue {
+			return nil, fmt.Sprintf("UTM values must be at most %d characters", maxTrackingValue), false
+		}
+		out[key] = v
+	}
+	return out, "", true
+}
+
+// validateParams checks the custom tracking parameters of a request.
+// UTM parameters belong in utms instead.
+func validateParams(params map[string]string) (string, bool) {
+	if len(params) > maxParams {
+		return fmt.Sprintf("A link may have at most %d custom parameters", maxParams), false
+	}
+	for k, v := range params {
+		switch {
+		case strings.TrimSpace(k) == "":
+			return "Custom parameter names must not be empty", false
+		case utf8.RuneCountInString(k) > maxParamKey:
+			return fmt.Sprintf("Custom parameter names must be at most %d characters", maxParamKey), false
+		case strings.HasPrefix(strings.ToLower(k), "utm_"):
+			return fmt.Sprintf("Custom parameter %q is a UTM parameter; set it in utms", k), false
+		case utf8.RuneCountInString(v) > maxTrackingValue:
+			return fmt.Sprintf("Custom parameter values must be at most %d characters", maxTrackingValue), false
+		}
+	}
+	return "", true
+}
+
+// userTeams returns the teams username belongs to, or none when the
+// user cannot be loaded.
+func (h *Handler) userTeams(ctx context.Context, username string) []string {
+	if user, err := h.UserService.GetByUsername(ctx, username); err == nil && user != nil {
+		return user.Teams
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+func TestShortenHandler_UTMPolicy(t *testing.T) {
+	var saved models.ShortURL
+	h := NewHandler(&mockURLShortener{
+		ShortenFunc: func(ctx context.Context, req models.ShortURL) (models.ShortURL, error) {
+			saved = req
+			return req, nil
+		},
+		IsSlugAvailableFunc: func(ctx context.Context, domain, slug string) (bool, error) { return true, nil },
+	}, &mockUserService{}, "http://localhost")
+	shorten := func(body string) int {
+		req := withURLParams(httptest.NewRequest("POST", "/api/shorten", bytes.NewBufferString(body)), "tester", nil)
+		w := httptest.NewRecorder()
+		h.Shorten(w, req)
+		return w.Code
+	}
+	if code := shorten(`{"url":"https://example.com/p?utm_source=old&x=1#top","utms":{"source":"news","medium":"email"},"utmPolicy":"keep"}`); code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+	if saved.URL != "https://example.com/p?utm_source=old&x=1&utm_medium=email#top" {
+		t.Errorf("unexpected destination %q", saved.URL)
+	}
+	if saved.BaseURL != "https://example.com/p?utm_source=old&x=1#top" || saved.UTMPolicy != models.UTMPolicyKeep {
+		t.Errorf("expected base URL and policy stored, got %+v", saved)
+	}
+	if code := shorten(`{"url":"https://example.com","utmPolicy":"merge"}`); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown policy, got %d", code)
+	}
+}
+
+func TestUpdateUTMsHandler(t *testing.T) {
+	link := &models.ShortURL{
+		Slug:      "promo123",
+		CreatedBy: "tester",
+		URL:       "https://example.com/?utm_source=old",
+		BaseURL:   "https://example.com/",
+		UTMs:      map[string]string{"source": "old"},
+		Destinations: []models.Destination{
+			// Stored before base URLs were recorded.
+			{Label: "a", URL: "https://a.example.com/?q=1&utm_source=old", Weight: 1},
+		},
+	}
+	var updated models.ShortURL
+	h := NewHandler(&mockURLShortener{
+		GetStoredFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			if slug != link.Slug {
+				return nil, nil
+			}
+			return link, nil
+		},
+		UpdateUTMsFunc: func(ctx context.Context, domain, slug string, l models.ShortURL) error {
+			updated = l
+			return nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	put := func(slug, user, body string) (int, SlugInfo) {
+		req := httptest.NewRequest("PUT", "/api/slugs/"+slug+"/utms", bytes.NewBufferString(body))
+		req = withURLParams(req, user, map[string]string{"slug": slug})
+		w := httptest.NewRecorder()
+		h.UpdateUTMs(w, req)
+		var info SlugInfo
+		_ = json.NewDecoder(w.Body).Decode(&info)
+		return w.Code, info
+	}
+	code, info := put("promo123", "tester", `{"utms":{"source":"new","campaign":"spring"}}`)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if updated.URL != "https://example.com/?utm_source=new&utm_campaign=spring" || info.Destination != updated.URL {
+		t.Errorf("unexpected destination %q, response %q", updated.URL, info.Destination)
+	}
+	if got := updated.Destinations[0]; got.URL != "https://a.example.com/?q=1&utm_source=new&utm_campaign=spring" || got.BaseURL != "https://a.example.com/?q=1" {
+		t.Errorf("expected legacy destination recomposed from recovered base, got %+v", got)
+	}
+	if link.Destinations[0].BaseURL != "" {
+		t.Errorf("expected stored link to be left untouched, got %+v", link.Destinations[0])
+	}
+	if updated.URLKey != "https://example.com/?utm_campaign=spring&utm_source=new" {
+		t.Errorf("expected URL key refreshed, got %q", updated.URLKey)
+	}
+	if code, _ = put("promo123", "someone", `{"utms":{}}`); code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's link, got %d", code)
+	}
+	if code, _ = put("promo123", "tester", `{"utms":{},"utmPolicy":"bogus"}`); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown policy, got %d", code)
+	}
+	if code, _ = put("promo123", "tester", `{"utms":{"utm_source_platform":"x"}}`); code != http.StatusOK || updated.URL != "https://example.com/?utm_source_platform=x" {
+		t.Errorf("expected non-standard UTM key kept, got %d %q", code, updated.URL)
+	}
+	if code, _ = put("promo123", "tester", `{"utms":{"utm_Source":"new"}}`); code != http.StatusOK || updated.UTMs["source"] != "new" || len(updated.UTMs) != 1 {
+		t.Errorf("expected prefixed key normalised, got %d %+v", code, updated.UTMs)
+	}
+}
+
+func TestUpdateUTMsHandler_Scheduled(t *testing.T) {
+	// Stored before base URLs were recorded, with a schedule entry that
+	// has already taken effect
+	link := &models.ShortURL{
+		Slug:      "sched123",
+		CreatedBy: "tester",
+		URL:       "https://example.com/?utm_source=old",
+		UTMs:      map[string]string{"source": "old"},
+		Schedule: []models.ScheduledDestination{
+			{At: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), URL: "https://later.example.com/?utm_source=old"},
+		},
+	}
+	var updated models.ShortURL
+	h := NewHandler(&mockURLShortener{
+		GetStoredFunc: func(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+			return link, nil
+		},
+		UpdateUTMsFunc: func(ctx context.Context, domain, slug string, l models.ShortURL) error {
+			updated = l
+			return nil
+		},
+	}, &mockUserService{}, "http://localhost")
+	req := httptest.NewRequest("PUT", "/api/slugs/sched123/utms", bytes.NewBufferString(`{"utms":{"source":"new"}}`))
+	w := httptest.NewRecorder()
+	h.UpdateUTMs(w, withURLParams(req, "tester", map[string]string{"slug": "sched123"}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if updated.URL != "https://example.com/?utm_source=new" || updated.BaseURL != "https://example.com/" {
+		t.Errorf("expected the stored destination kept, got %q (base %q)", updated.URL, updated.BaseURL)
+	}
+	if got := updated.Schedule[0]; got.URL != "https://later.example.com/?utm_source=new" {
+		t.Errorf("expected scheduled destination recomposed, got %+v", got)
+	}
+}
@@ -0,0 +1,259 @@
+// Package health periodically checks that the destinations of active
+// links still respond, recording the status code, latency and check
+// time on each link and flagging links that fail repeatedly.
+package health
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/metadata"
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/urltemplate"
+)
+
+// Defaults used for zero Checker fields.
+const (
+	DefaultPerHost          = 2
+	DefaultConcurrency      = 16
+	DefaultFailureThreshold = 3
+	DefaultTimeout          = 10 * time.Second
+	userAgent               = "SymphLinkChecker/1.0"
+)
+
+// Store lists the links to check and saves the results.
+// services.URLShortenerService satisfies it.
+type Store interface {
+	ListActive(ctx context.Context, now time.Time, fn func(models.ShortURL) error) error
+	UpdateHealth(ctx context.Context, domain, slug string, health models.LinkHealth) error
+}
+
+// Checker checks link destinations.  At most PerHost requests run
+// against one host at a time and at most Concurrency overall.  A link
+// is flagged Broken after FailureThreshold consecutive failures.
+// Client defaults to the SSRF-safe client from package metadata; Now
+// defaults to time.Now.
+type Checker struct {
+	Store            Store
+	Client           *http.Client
+	PerHost          int
+	Concurrency      int
+	FailureThreshold int
+	Now              func() time.Time
+}
+
+// NewChecker returns a Checker with default limits.
+func NewChecker(store Store) *Checker {
+	return &Checker{
+		Store:  store,
+		Client: metadata.NewClient(metadata.Options{Timeout: DefaultTimeout, UserAgent: userAgent}),
+	}
+}
+
+// Run checks every active link each interval until ctx is cancelled.
+// The first pass starts immediately.
+func (c *Checker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := c.CheckAll(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("health: check failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckAll checks every destination of each active link once and
+// stores the results.  Template destinations are skipped since they
+// depend on the visitor's request.
+func (c *Checker) CheckAll(ctx context.Context) error {
+	now := c.now()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		hostSems = map[string]chan struct{}{}
+		global   = make(chan struct{}, positive(c.Concurrency, DefaultConcurrency))
+	)
+	hostSem := func(host string) chan struct{} {
+		mu.Lock()
+		defer mu.Unlock()
+		sem, ok := hostSems[host]
+		if !ok {
+			sem = make(chan struct{}, positive(c.PerHost, DefaultPerHost))
+			hostSems[host] = sem
+		}
+		return sem
+	}
+	err := c.Store.ListActive(ctx, now, func(link models.ShortURL) error {
+		targets := checkTargets(&link, now)
+		if len(targets) == 0 {
+			return nil
+		}
+		// Take the global slot before starting the goroutine so a pass
+		// over many links keeps at most Concurrency of them in flight
+		select {
+		case global <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-global }()
+			result, ok := c.checkLink(ctx, targets, hostSem)
+			if !ok {
+				return
+			}
+			result = c.next(link.Health, result)
+			if err := c.Store.UpdateHealth(ctx, link.Domain, link.Slug, result); err != nil {
+				log.Printf("health: failed to store result for %s: %v", link.Slug, err)
+			}
+		}()
+		return nil
+	})
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// target is a destination to check and its lower-cased host.
+type target struct {
+	url, host string
+}
+
+// checkTargets returns the distinct destinations of link that visitors
+// may be sent to at now: the current URL, A/B variants, targeting rules
+// and the expired fallback, or only the fallback once the link has
+// expired.
+func checkTargets(link *models.ShortURL, now time.Time) []target {
+	var urls []string
+	if link.ExpireAt != nil && !now.Before(*link.ExpireAt) {
+		urls = []string{link.ExpiredRedirectURL}
+	} else {
+		urls = append(urls, link.DestinationAt(now))
+		for _, d := range link.Destinations {
+			urls = append(urls, d.URL)
+		}
+		for _, rule := range link.Rules {
+			urls = append(urls, rule.URL)
+		}
+		urls = append(urls, link.ExpiredRedirectURL)
+	}
+	var targets []target
+	seen := map[string]bool{}
+	for _, dest := range urls {
+		if dest == "" || seen[dest] || urltemplate.IsTemplate(dest) {
+			continue
+		}
+		seen[dest] = true
+		u, err := url.Parse(dest)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		targets = append(targets, target{url: dest, host: strings.ToLower(u.Hostname())})
+	}
+	return targets
+}
+
+// checkLink checks targets in order, each within its host's limit, and
+// returns the first failure or the last success.  It reports false when
+// ctx is cancelled before the checks complete.
+func (c *Checker) checkLink(ctx context.Context, targets []target, hostSem func(string) chan struct{}) (models.LinkHealth, bool) {
+	var result models.LinkHealth
+	for _, t := range targets {
+		sem := hostSem(t.host)
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return result, false
+		}
+		result = c.check(ctx, t.url)
+		<-sem
+		if ctx.Err() != nil {
+			return result, false
+		}
+		if result.Error != "" || result.StatusCode >= 400 {
+			result.URL = t.url
+			break
+		}
+	}
+	return result, true
+}
+
+// check requests dest with HEAD, retrying with GET when the server
+// does not support HEAD.  Status codes below 400 count as healthy.
+func (c *Checker) check(ctx context.Context, dest string) models.LinkHealth {
+	start := c.now()
+	status, err := c.request(ctx, http.MethodHead, dest)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = c.request(ctx, http.MethodGet, dest)
+	}
+	result := models.LinkHealth{
+		StatusCode:    status,
+		LatencyMillis: c.now().Sub(start).Milliseconds(),
+		LastCheckedAt: c.now(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (c *Checker) request(ctx context.Context, method, dest string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, dest, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// next combines a new check result with the link's previous health.
+func (c *Checker) next(prev *models.LinkHealth, result models.LinkHealth) models.LinkHealth {
+	if result.Error == "" && result.StatusCode < 400 {
+		return result
+	}
+	result.ConsecutiveFailures = 1
+	if prev != nil {
+		result.ConsecutiveFailures = prev.ConsecutiveFailures + 1
+	}
+	result.Broken = result.ConsecutiveFailures >= positive(c.FailureThreshold, DefaultFailureThreshold)
+	return result
+}
+
+func (c *Checker) now() time.Time {
+	if c.Now != nil {
+		return c.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+func positive(n, def int) int {
+	if n > 0 {
+		return n
+	}
+	return def
+}
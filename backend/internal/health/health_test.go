@@ -0,0 +1,171 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/metadata"
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+type fakeStore struct {
+	mu    sync.Mutex
+	links []models.ShortURL
+	saved map[string]models.LinkHealth
+}
+
+func (s *fakeStore) ListActive(ctx context.Context, now time.Time, fn func(models.ShortURL) error) error {
+	for _, link := range s.links {
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) UpdateHealth(ctx context.Context, domain, slug string, health models.LinkHealth) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved[slug] = health
+	return nil
+}
+
+func newTestChecker(store Store) *Checker {
+	c := NewChecker(store)
+	c.Client = metadata.NewClient(metadata.Options{Timeout: 5 * time.Second, AllowPrivateNetworks: true})
+	return c
+}
+
+func TestCheckAll(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gone":
+			http.NotFound(w, r)
+		case "/get-only":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	store := &fakeStore{saved: map[string]models.LinkHealth{}, links: []models.ShortURL{
+		{Slug: "ok", URL: srv.URL + "/page", Health: &models.LinkHealth{ConsecutiveFailures: 5, Broken: true}},
+		{Slug: "getonly", URL: srv.URL + "/get-only"},
+		{Slug: "gone", URL: srv.URL + "/gone", Health: &models.LinkHealth{ConsecutiveFailures: 2}},
+		{Slug: "new", URL: srv.URL + "/gone"},
+		{Slug: "tmpl", URL: srv.URL + "/{slug}"},
+	}}
+	checkedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newTestChecker(store)
+	c.Now = func() time.Time { return checkedAt }
+	if err := c.CheckAll(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := store.saved["ok"]; got.StatusCode != 200 || got.Broken || got.ConsecutiveFailures != 0 || !got.LastCheckedAt.Equal(checkedAt) {
+		t.Errorf("expected recovered link to be healthy, got %+v", got)
+	}
+	if got := store.saved["getonly"]; got.StatusCode != 200 {
+		t.Errorf("expected GET fallback to succeed, got %+v", got)
+	}
+	if got := store.saved["gone"]; got.StatusCode != 404 || got.ConsecutiveFailures != 3 || !got.Broken {
+		t.Errorf("expected third failure to flag link, got %+v", got)
+	}
+	if got := store.saved["new"]; got.ConsecutiveFailures != 1 || got.Broken {
+		t.Errorf("expected first failure not to flag link, got %+v", got)
+	}
+	if _, ok := store.saved["tmpl"]; ok {
+		t.Error("expected template destinations to be skipped")
+	}
+}
+
+func TestCheckAllLimitsPerHost(t *testing.T) {
+	var inFlight, peak int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}))
+	defer srv.Close()
+
+	store := &fakeStore{saved: map[string]models.LinkHealth{}}
+	for _, slug := range []string{"a", "b", "c", "d", "e", "f"} {
+		store.links = append(store.links, models.ShortURL{Slug: slug, URL: srv.URL})
+	}
+	c := newTestChecker(store)
+	c.PerHost = 2
+	if err := c.CheckAll(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if peak > 2 {
+		t.Errorf("expected at most 2 concurrent requests per host, got %d", peak)
+	}
+	if len(store.saved) != 6 {
+		t.Errorf("expected all links checked, got %d", len(store.saved))
+	}
+}
+
+func TestCheckBlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	store := &fakeStore{saved: map[string]models.LinkHealth{}, links: []models.ShortURL{{Slug: "internal", URL: srv.URL}}}
+	if err := NewChecker(store).CheckAll(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := store.saved["internal"]; got.Error == "" || got.ConsecutiveFailures != 1 {
+		t.Errorf("expected loopback destination to fail, got %+v", got)
+	}
+}
+
+func TestCheckAllDestinations(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	store := &fakeStore{saved: map[string]models.LinkHealth{}, links: []models.ShortURL{
+		{Slug: "variant", URL: srv.URL + "/a", Destinations: []models.Destination{{URL: srv.URL + "/a"}, {URL: srv.URL + "/gone"}}},
+		{Slug: "rule", URL: srv.URL + "/a", Rules: []models.TargetingRule{{OS: []string{"ios"}, URL: srv.URL + "/gone"}}},
+		{Slug: "fallback", URL: srv.URL + "/a", ExpiredRedirectURL: srv.URL + "/gone"},
+		{Slug: "expired", URL: srv.URL + "/gone", ExpireAt: &past, ExpiredRedirectURL: srv.URL + "/a"},
+		{Slug: "healthy", URL: srv.URL + "/a", Rules: []models.TargetingRule{{OS: []string{"ios"}, URL: srv.URL + "/b"}}},
+	}}
+	c := newTestChecker(store)
+	c.Now = func() time.Time { return now }
+	if err := c.CheckAll(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, slug := range []string{"variant", "rule", "fallback"} {
+		if got := store.saved[slug]; got.StatusCode != 404 || got.URL != srv.URL+"/gone" || got.ConsecutiveFailures != 1 {
+			t.Errorf("expected failing %s destination to be reported, got %+v", slug, got)
+		}
+	}
+	// Once expired only the fallback is live
+	if got := store.saved["expired"]; got.StatusCode != 200 || got.ConsecutiveFailures != 0 {
+		t.Errorf("expected expired link to check its fallback, got %+v", got)
+	}
+	if got := store.saved["healthy"]; got.StatusCode != 200 || got.URL != "" {
+		t.Errorf("expected healthy link, got %+v", got)
+	}
+}
@@ -0,0 +1,306 @@
+// Package metadata fetches the title, description, favicon and Open
+// Graph image of destination pages.  Fetches are bounded in time,
+// size and redirects, and refuse to connect to private or local
+// addresses so user-supplied URLs cannot be used to probe internal
+// services.
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+// Default limits used by NewFetcher for zero Options fields.
+const (
+	DefaultTimeout      = 5 * time.Second
+	DefaultMaxBytes     = 512 << 10
+	DefaultMaxRedirects = 5
+	DefaultUserAgent    = "SymphLinkPreview/1.0"
+)
+
+// ErrBlockedAddress is returned when a fetch would connect to a
+// private, loopback, link-local or otherwise non-public address.
+var ErrBlockedAddress = errors.New("destination resolves to a non-public address")
+
+// Options configures a Fetcher.  AllowPrivateNetworks disables the
+// private address check; it exists for tests against httptest servers
+// and must not be set in production.
+type Options struct {
+	Timeout              time.Duration
+	MaxBytes             int64
+	MaxRedirects         int
+	UserAgent            string
+	AllowPrivateNetworks bool
+}
+
+// Fetcher retrieves page metadata over HTTP.
+type Fetcher struct {
+	client    *http.Client
+	maxBytes  int64
+	userAgent string
+}
+
+// NewFetcher returns a Fetcher with its own HTTP client.
+func NewFetcher(opts Options) *Fetcher {
+	opts = opts.withDefaults()
+	return &Fetcher{client: NewClient(opts), maxBytes: opts.MaxBytes, userAgent: opts.UserAgent}
+}
+
+func (opts Options) withDefaults() Options {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = DefaultMaxRedirects
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+	return opts
+}
+
+// NewClient returns an HTTP client enforcing the timeout, redirect and
+// address limits of opts.  It ignores proxy settings so the address
+// check cannot be bypassed.  Other packages making requests to
+// user-supplied URLs should use it too.
+func NewClient(opts Options) *http.Client {
+	opts = opts.withDefaults()
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivateNetworks {
+		// Control runs after DNS resolution, for every address dialled,
+		// so rebinding a public name to a private address is caught too
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil || !IsPublicAddr(addr) {
+				return ErrBlockedAddress
+			}
+			return nil
+		}
+	}
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   opts.Timeout,
+		ResponseHeaderTimeout: opts.Timeout,
+		MaxIdleConnsPerHost:   2,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	maxRedirects := opts.MaxRedirects
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	return client
+}
+
+// IsPublicAddr reports whether addr is a globally routable unicast
+// address.
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// nonPublicPrefixes are special-purpose ranges not covered by the
+// netip predicates.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// Fetch downloads rawURL and extracts its metadata.  Only the first
+// MaxBytes of HTML responses are read.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*models.LinkMetadata, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("unsupported URL %q", rawURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBlockedAddress) {
+			return nil, ErrBlockedAddress
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+	meta := parseHead(io.LimitReader(resp.Body, f.maxBytes), resp.Request.URL)
+	return meta, nil
+}
+
+// parseHead extracts metadata from the <head> of an HTML document.
+// Relative icon and image URLs are resolved against base; the site's
+// /favicon.ico is assumed when no icon is declared.
+func parseHead(r io.Reader, base *url.URL) *models.LinkMetadata {
+	meta := &models.LinkMetadata{}
+	var ogTitle, ogDescription, icon string
+	z := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		name, hasAttr := z.TagName()
+		tag := string(name)
+		if tt == html.EndTagToken {
+			if tag == "title" {
+				inTitle = false
+			}
+			if tag == "head" {
+				break
+			}
+			continue
+		}
+		if tt == html.TextToken && inTitle && meta.Title == "" {
+			meta.Title = collapseSpace(string(z.Text()))
+			continue
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		if tag == "body" {
+			break
+		}
+		if tag == "title" {
+			inTitle = tt == html.StartTagToken
+			continue
+		}
+		if !hasAttr || (tag != "meta" && tag != "link") {
+			continue
+		}
+		attrs := readAttrs(z)
+		switch tag {
+		case "meta":
+			content := collapseSpace(attrs["content"])
+			switch strings.ToLower(attrs["property"] + attrs["name"]) {
+			case "og:title":
+				ogTitle = content
+			case "og:description":
+				ogDescription = content
+			case "description":
+				if meta.Description == "" {
+					meta.Description = content
+				}
+			case "og:image", "og:image:url":
+				if meta.ImageURL == "" {
+					meta.ImageURL = resolve(base, attrs["content"])
+				}
+			}
+		case "link":
+			for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+				if rel == "icon" && icon == "" {
+					icon = attrs["href"]
+				}
+			}
+		}
+	}
+	if meta.Title == "" {
+		meta.Title = ogTitle
+	}
+	if meta.Description == "" {
+		meta.Description = ogDescription
+	}
+	if icon != "" {
+		meta.FaviconURL = resolve(base, icon)
+	}
+	if meta.FaviconURL == "" {
+		meta.FaviconURL = resolve(base, "/favicon.ico")
+	}
+	meta.Title = truncate(meta.Title, 300)
+	meta.Description = truncate(meta.Description, 1000)
+	return meta
+}
+
+func readAttrs(z *html.Tokenizer) map[string]string {
+	attrs := map[string]string{}
+	for {
+		key, val, more := z.TagAttr()
+		attrs[strings.ToLower(string(key))] = string(val)
+		if !more {
+			return attrs
+		}
+	}
+}
+
+// resolve returns ref resolved against base, or "" unless the result
+// is an http(s) URL.
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
@@ -0,0 +1,196 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPage = `<!DOCTYPE html>
+<html><head>
+<meta charset="utf-8">
+<title>
+  Spring   Sale
+</title>
+<meta name="description" content="Up to 50% off">
+<meta property="og:title" content="OG title">
+<meta property="og:image" content="/img/og.png">
+<link rel="shortcut icon" href="/static/icon.png">
+</head>
+<body><title>ignored</title></body></html>`
+
+func newTestFetcher(opts Options) *Fetcher {
+	opts.AllowPrivateNetworks = true
+	return NewFetcher(opts)
+}
+
+func TestFetchExtractsMetadata(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(testPage))
+	}))
+	defer srv.Close()
+
+	meta, err := newTestFetcher(Options{}).Fetch(context.Background(), srv.URL+"/sale")
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if meta.Title != "Spring Sale" || meta.Description != "Up to 50% off" {
+		t.Errorf("unexpected title/description: %+v", meta)
+	}
+	if meta.ImageURL != srv.URL+"/img/og.png" || meta.FaviconURL != srv.URL+"/static/icon.png" {
+		t.Errorf("expected resolved image and favicon, got %+v", meta)
+	}
+}
+
+func TestFetchFallsBackToOpenGraphAndFavicon(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<head><meta property="og:title" content="Only OG"><meta property="og:description" content="Desc"></head>`))
+	}))
+	defer srv.Close()
+
+	meta, err := newTestFetcher(Options{}).Fetch(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if meta.Title != "Only OG" || meta.Description != "Desc" || meta.FaviconURL != srv.URL+"/favicon.ico" {
+		t.Errorf("unexpected metadata: %+v", meta)
+	}
+}
+
+func TestFetchFollowsLimitedRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/hop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/final", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<title>Final</title>`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	f := newTestFetcher(Options{MaxRedirects: 3})
+	meta, err := f.Fetch(context.Background(), srv.URL+"/hop")
+	if err != nil || meta.Title != "Final" {
+		t.Fatalf("expected redirect to be followed, got %+v, %v", meta, err)
+	}
+	if meta.FaviconURL != srv.URL+"/favicon.ico" {
+		t.Errorf("expected favicon relative to the final URL, got %s", meta.FaviconURL)
+	}
+	if _, err := f.Fetch(context.Background(), srv.URL+"/loop"); err == nil {
+		t.Error("expected redirect loop to fail")
+	}
+}
+
+func TestFetchLimits(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<head>" + strings.Repeat("<!-- padding -->", 10000) + "<title>Too late</title></head>"))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	f := newTestFetcher(Options{MaxBytes: 1024, Timeout: 200 * time.Millisecond})
+	meta, err := f.Fetch(context.Background(), srv.URL+"/big")
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if meta.Title != "" {
+		t.Errorf("expected body beyond the size limit to be ignored, got %q", meta.Title)
+	}
+	for _, path := range []string{"/slow", "/json", "/missing"} {
+		if _, err := f.Fetch(context.Background(), srv.URL+path); err == nil {
+			t.Errorf("%s: expected error", path)
+		}
+	}
+}
+
+func TestFetchBlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("private server must not be contacted")
+	}))
+	defer srv.Close()
+
+	f := NewFetcher(Options{})
+	for _, u := range []string{srv.URL, "http://localhost:1/", "http://[::1]:1/"} {
+		if _, err := f.Fetch(context.Background(), u); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("%s: expected ErrBlockedAddress, got %v", u, err)
+		}
+	}
+	if _, err := f.Fetch(context.Background(), "file:///etc/passwd"); err == nil {
+		t.Error("expected non-http URL to be rejected")
+	}
+}
+
+func TestFetchBlocksRedirectToPrivateAddress(t *testing.T) {
+	// The first hop is allowed by a permissive check so that the test
+	// can observe the redirect to a blocked address being refused
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect target must not be contacted")
+	}))
+	defer internal.Close()
+	f := NewFetcher(Options{})
+	transport := f.client.Transport.(*http.Transport)
+	blockedDial := transport.DialContext
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusFound)
+	}))
+	defer public.Close()
+	publicHost := strings.TrimPrefix(public.URL, "http://")
+	plain := NewFetcher(Options{AllowPrivateNetworks: true}).client.Transport.(*http.Transport).DialContext
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == publicHost {
+			return plain(ctx, network, addr)
+		}
+		return blockedDial(ctx, network, addr)
+	}
+	if _, err := f.Fetch(context.Background(), public.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("expected redirect to private address to be blocked, got %v", err)
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::1":              false,
+		"fc00::1":          false,
+		"fe80::1":          false,
+		"::ffff:127.0.0.1": false,
+		"224.0.0.1":        false,
+	} {
+		if got := IsPublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("IsPublicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package metadata
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+// Store saves fetched metadata on a link.
+// services.URLShortenerService satisfies it.
+type Store interface {
+	UpdateMetadata(ctx context.Context, domain, slug string, meta models.LinkMetadata) error
+}
+
+// Enqueuer accepts links whose metadata should be fetched.
+type Enqueuer interface {
+	Enqueue(domain, slug, rawURL string) bool
+}
+
+var _ Enqueuer = (*Worker)(nil)
+
+type job struct {
+	domain, slug, url string
+}
+
+// Worker fetches metadata for queued links in background goroutines
+// and stores the result, recording the error when a fetch fails.  Now
+// supplies the fetch time; it defaults to time.Now.
+type Worker struct {
+	Fetcher *Fetcher
+	Store   Store
+	Now     func() time.Time
+
+	jobs chan job
+	wg   sync.WaitGroup
+}
+
+// NewWorker returns a worker whose queue holds up to queueSize links.
+func NewWorker(fetcher *Fetcher, store Store, queueSize int) *Worker {
+	if queueSize <= 0 {
+		queueSize = 100
+	}
+	return &Worker{Fetcher: fetcher, Store: store, jobs: make(chan job, queueSize)}
+}
+
+// Enqueue queues a link without blocking.  It returns false when the
+// queue is full and the link was dropped.
+func (w *Worker) Enqueue(domain, slug, rawURL string) bool {
+	select {
+	case w.jobs <- job{domain: domain, slug: slug, url: rawURL}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Start runs n fetch goroutines until ctx is cancelled.  Wait blocks
+// until they have exited.
+func (w *Worker) Start(ctx context.Context, n int) {
+	if n <= 0 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-w.jobs:
+					w.process(ctx, j)
+				}
+			}
+		}()
+	}
+}
+
+// Wait blocks until all goroutines started by Start have exited.
+func (w *Worker) Wait() {
+	w.wg.Wait()
+}
+
+func (w *Worker) process(ctx context.Context, j job) {
+	meta, err := w.Fetcher.Fetch(ctx, j.url)
+	if err != nil {
+		meta = &models.LinkMetadata{Error: err.Error()}
+	}
+	meta.FetchedAt = w.now()
+	storeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := w.Store.UpdateMetadata(storeCtx, j.domain, j.slug, *meta); err != nil {
+		log.Printf("metadata: failed to store metadata for %s: %v", j.slug, err)
+	}
+}
+
+func (w *Worker) now() time.Time {
+	if w.Now != nil {
+		return w.Now().UTC()
+	}
+	return time.Now().UTC()
+}
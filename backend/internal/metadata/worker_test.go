@@ -0,0 +1,76 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+type fakeStore struct {
+	mu    sync.Mutex
+	saved map[string]models.LinkMetadata
+	done  chan struct{}
+}
+
+func (s *fakeStore) UpdateMetadata(ctx context.Context, domain, slug string, meta models.LinkMetadata) error {
+	s.mu.Lock()
+	s.saved[domain+"/"+slug] = meta
+	s.mu.Unlock()
+	s.done <- struct{}{}
+	return nil
+}
+
+func TestWorkerStoresResults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<title>Hello</title>`))
+	}))
+	defer srv.Close()
+
+	store := &fakeStore{saved: map[string]models.LinkMetadata{}, done: make(chan struct{}, 2)}
+	fetchedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := NewWorker(newTestFetcher(Options{}), store, 10)
+	w.Now = func() time.Time { return fetchedAt }
+	ctx, cancel := context.WithCancel(context.Background())
+	w.Start(ctx, 2)
+
+	if !w.Enqueue("", "ok123456", srv.URL+"/page") || !w.Enqueue("go.brand.com", "gone1234", srv.URL+"/gone") {
+		t.Fatal("expected jobs to be queued")
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-store.done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for worker")
+		}
+	}
+	cancel()
+	w.Wait()
+
+	ok := store.saved["/ok123456"]
+	if ok.Title != "Hello" || ok.Error != "" || !ok.FetchedAt.Equal(fetchedAt) {
+		t.Errorf("unexpected metadata: %+v", ok)
+	}
+	if gone := store.saved["go.brand.com/gone1234"]; gone.Error == "" || gone.Title != "" {
+		t.Errorf("expected fetch error to be recorded, got %+v", gone)
+	}
+}
+
+func TestWorkerEnqueueDropsWhenFull(t *testing.T) {
+	w := NewWorker(newTestFetcher(Options{}), &fakeStore{}, 1)
+	if !w.Enqueue("", "a", "https://example.com") {
+		t.Fatal("expected first job to be queued")
+	}
+	if w.Enqueue("", "b", "https://example.com") {
+		t.Error("expected full queue to drop the job")
+	}
+}
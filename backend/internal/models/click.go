@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Click records a single tracked redirect.  Variant holds the label of
+// the A/B destination served, when the link has several destinations.
+// Source tells where the visit came from, such as ClickSourceQR for
+// scans of the link's QR code.
+type Click struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Domain    string             `bson:"domain,omitempty" json:"domain,omitempty"`
+	Slug      string             `bson:"slug" json:"slug"`
+	At        time.Time          `bson:"at" json:"at"`
+	Variant   string             `bson:"variant,omitempty" json:"variant,omitempty"`
+	Source    string             `bson:"source,omitempty" json:"source,omitempty"`
+	Referrer  string             `bson:"referrer,omitempty" json:"referrer,omitempty"`
+	UserAgent string             `bson:"userAgent,omitempty" json:"userAgent,omitempty"`
+}
+
+// ClickSourceQR marks clicks that came from scanning a QR code.
+const ClickSourceQR = "qr"
+
+// ClickStats summarises the recorded clicks of a link.
+type ClickStats struct {
+	Total    int64          `json:"total"`
+	Variants []VariantStats `json:"variants,omitempty"`
+	Sources  []SourceStats  `json:"sources,omitempty"`
+}
+
+// VariantStats is the number of clicks served by one A/B variant.
+type VariantStats struct {
+	Variant string `bson:"_id" json:"variant"`
+	Clicks  int64  `bson:"clicks" json:"clicks"`
+}
+
+// SourceStats is the number of clicks that came from one source.
+type SourceStats struct {
+	Source string `bson:"_id" json:"source"`
+	Clicks int64  `bson:"clicks" json:"clicks"`
+}
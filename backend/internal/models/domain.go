@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Domain represents a custom short host (for example "go.brand.com")
+// that can serve its own namespace of slugs.  A domain is owned by a
+// single user and may optionally be shared with a team.  Ownership is
+// proven by publishing VerificationToken in a DNS TXT record; links
+// can only be created on a domain once Verified is true.
+// ExpiredRedirectURL and NotFoundRedirectURL are fallback destinations
+// for expired links and unknown slugs on this domain.  Apps lists the
+// mobile apps allowed to open the domain's links directly.
+type Domain struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Host              string             `bson:"host" json:"host"`
+	Owner             string             `bson:"owner" json:"owner"`
+	Team              string             `bson:"team,omitempty" json:"team,omitempty"`
+	VerificationToken string             `bson:"verificationToken" json:"verificationToken"`
+	Verified          bool               `bson:"verified" json:"verified"`
+	VerifiedAt        *time.Time         `bson:"verifiedAt,omitempty" json:"verifiedAt,omitempty"`
+	CreatedAt         time.Time          `bson:"createdAt" json:"createdAt"`
+
+	ExpiredRedirectURL  string `bson:"expiredRedirectUrl,omitempty" json:"expiredRedirectUrl,omitempty"`
+	NotFoundRedirectURL string `bson:"notFoundRedirectUrl,omitempty" json:"notFoundRedirectUrl,omitempty"`
+
+	Apps *AppAssociation `bson:"apps,omitempty" json:"apps,omitempty"`
+}
+
+// AppAssociation configures the app association files served under
+// /.well-known/.  AppleAppIDs are "<TeamID>.<bundle ID>" identifiers
+// for apple-app-site-association; AndroidApps feed assetlinks.json.
+type AppAssociation struct {
+	AppleAppIDs []string     `bson:"appleAppIds,omitempty" json:"appleAppIds,omitempty"`
+	AndroidApps []AndroidApp `bson:"androidApps,omitempty" json:"androidApps,omitempty"`
+}
+
+// AndroidApp identifies an Android app by package name and the SHA-256
+// fingerprints of its signing certificates.
+type AndroidApp struct {
+	PackageName  string   `bson:"packageName" json:"packageName"`
+	Fingerprints []string `bson:"fingerprints" json:"fingerprints"`
+}
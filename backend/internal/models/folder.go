@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Folder is a named collection of a user's links.  Names are unique per
+// owner.
+type Folder struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Owner     string             `bson:"owner" json:"owner"`
+	Name      string             `bson:"name" json:"name"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// TagCount is a tag in use by a user and the number of links carrying
+// it.
+type TagCount struct {
+	Tag   string `bson:"_id" json:"tag"`
+	Links int64  `bson:"links" json:"links"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Report is a visitor's abuse report against a link.  Reports start
+// open and are closed by a moderator: actioned when the link was
+// disabled or deleted, dismissed when no action was needed.
+type Report struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Domain     string             `bson:"domain,omitempty" json:"domain,omitempty"`
+	Slug       string             `bson:"slug" json:"slug"`
+	Reason     string             `bson:"reason" json:"reason"`
+	Details    string             `bson:"details,omitempty" json:"details,omitempty"`
+	Status     string             `bson:"status" json:"status"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	ResolvedAt *time.Time         `bson:"resolvedAt,omitempty" json:"resolvedAt,omitempty"`
+	ResolvedBy string             `bson:"resolvedBy,omitempty" json:"resolvedBy,omitempty"`
+}
+
+// Report reasons.
+const (
+	ReportPhishing = "phishing"
+	ReportMalware  = "malware"
+	ReportSpam     = "spam"
+	ReportOther    = "other"
+)
+
+// Report statuses.
+const (
+	ReportOpen      = "open"
+	ReportActioned  = "actioned"
+	ReportDismissed = "dismissed"
+)
+
+// AuditEntry records a moderation action.  Actor is the moderator's
+// username, or "safety" for links flagged by the automatic checks.
+// ReportID links a dismissal to its report.
+type AuditEntry struct {
+	ID       primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Actor    string              `bson:"actor" json:"actor"`
+	Action   string              `bson:"action" json:"action"`
+	Domain   string              `bson:"domain,omitempty" json:"domain,omitempty"`
+	Slug     string              `bson:"slug" json:"slug"`
+	ReportID *primitive.ObjectID `bson:"reportId,omitempty" json:"reportId,omitempty"`
+	Note     string              `bson:"note,omitempty" json:"note,omitempty"`
+	At       time.Time           `bson:"at" json:"at"`
+}
+
+// Audit actions.
+const (
+	AuditDisable = "disable"
+	AuditEnable  = "enable"
+	AuditDelete  = "delete"
+	AuditDismiss = "dismiss"
+	AuditFlag    = "flag"
+	AuditUnflag  = "unflag"
+)
@@ -11,9 +11,12 @@ import (
 // (including any appended UTM parameters), an optional expiration
 // timestamp, a map of UTM parameters (for informational purposes)
 // and a creation timestamp.  MongoDB automatically generates a
-// unique ObjectID for the _id field when omitted on insert.
+// unique ObjectID for the _id field when omitted on insert.  Slugs are
+// unique per Domain, the custom host of the link, which is empty for
+// the default BASE_URL host.
 type ShortURL struct {
 	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Domain        string             `bson:"domain,omitempty" json:"domain,omitempty"`
 	Slug          string             `bson:"slug" json:"slug"`
 	URL           string             `bson:"url" json:"url"`
 	ExpireAt      *time.Time         `bson:"expireAt,omitempty" json:"expireAt,omitempty"`
@@ -22,4 +25,245 @@ type ShortURL struct {
 	CreatedBy     string             `bson:"createdBy,omitempty" json:"createdBy,omitempty"`
 	RedirectCount int                `bson:"redirectCount" json:"redirectCount"`
 	TrackClicks   bool               `bson:"trackClicks" json:"trackClicks"`
+
+	// ForwardPath and ForwardQuery let one short link front a whole
+	// site: /{slug}/docs/page?ref=x appends "docs/page" to the
+	// destination path and merges "ref=x" into its query.
+	// QueryPrecedence decides which value wins when an incoming
+	// parameter collides with one already in the destination.
+	// RedirectType is an HTTP status (301, 302, 307, 308) or an HTML
+	// page using a meta refresh or JavaScript; empty keeps the default.
+	ForwardPath     bool   `bson:"forwardPath,omitempty" json:"forwardPath,omitempty"`
+	ForwardQuery    bool   `bson:"forwardQuery,omitempty" json:"forwardQuery,omitempty"`
+	QueryPrecedence string `bson:"queryPrecedence,omitempty" json:"queryPrecedence,omitempty"`
+	RedirectType    string `bson:"redirectType,omitempty" json:"redirectType,omitempty"`
+
+	// Pixels are retargeting pixels loaded on an interstitial page for
+	// visitors who have not opted out of tracking.  PasswordHash gates
+	// the redirect behind a password prompt, and MaxClicks limits the
+	// link to that many redirects.
+	Pixels       *PixelConfig `bson:"pixels,omitempty" json:"pixels,omitempty"`
+	PasswordHash string       `bson:"passwordHash,omitempty" json:"-"`
+	MaxClicks    int          `bson:"maxClicks,omitempty" json:"maxClicks,omitempty"`
+
+	// ActivateAt keeps the link dark until then, showing a holding
+	// page when HoldingPage is set.  Schedule changes the destination
+	// at the given times.
+	ActivateAt  *time.Time             `bson:"activateAt,omitempty" json:"activateAt,omitempty"`
+	HoldingPage bool                   `bson:"holdingPage,omitempty" json:"holdingPage,omitempty"`
+	Schedule    []ScheduledDestination `bson:"schedule,omitempty" json:"schedule,omitempty"`
+
+	// ExpiredRedirectURL is where visitors go once the link has expired
+	// or used up its clicks, instead of a 410 page.
+	ExpiredRedirectURL string `bson:"expiredRedirectUrl,omitempty" json:"expiredRedirectUrl,omitempty"`
+
+	// Destinations turns the link into an A/B split: each visitor is
+	// assigned one in proportion to its Weight and keeps it on later
+	// visits.
+	Destinations []Destination `bson:"destinations,omitempty" json:"destinations,omitempty"`
+
+	// Rules are evaluated in order and the first match picks the
+	// destination; visitors no rule matches get URL (or their variant).
+	Rules []TargetingRule `bson:"rules,omitempty" json:"rules,omitempty"`
+
+	// DeepLink sends iOS and Android visitors to a page that opens the
+	// app and falls back to the web destination.  Preview overrides
+	// what social networks and chat apps show when unfurling the link.
+	DeepLink *DeepLinkConfig `bson:"deepLink,omitempty" json:"deepLink,omitempty"`
+	Preview  *PreviewMeta    `bson:"preview,omitempty" json:"preview,omitempty"`
+
+	// Metadata is fetched from the destination in the background after
+	// the link is created; Health is the last periodic destination check.
+	Metadata *LinkMetadata `bson:"metadata,omitempty" json:"metadata,omitempty"`
+	Health   *LinkHealth   `bson:"health,omitempty" json:"health,omitempty"`
+
+	// Flag is set when the destination fails a safety check after the
+	// link was created, and Disabled when a moderator takes the link
+	// down.  Both keep the link for review but stop it redirecting.
+	Flag     *SafetyFlag `bson:"flag,omitempty" json:"flag,omitempty"`
+	Disabled bool        `bson:"disabled,omitempty" json:"disabled,omitempty"`
+
+	// PrivateInfo hides the public info page ("/{slug}+"), and
+	// ShowOwner adds the owner's name to it.
+	PrivateInfo bool `bson:"privateInfo,omitempty" json:"privateInfo,omitempty"`
+	ShowOwner   bool `bson:"showOwner,omitempty" json:"showOwner,omitempty"`
+
+	// Title, Notes, Tags and FolderID organise the owner's links and
+	// are never shown to visitors.  Tags are lower-case and unique.
+	Title    string              `bson:"title,omitempty" json:"title,omitempty"`
+	Notes    string              `bson:"notes,omitempty" json:"notes,omitempty"`
+	Tags     []string            `bson:"tags,omitempty" json:"tags,omitempty"`
+	FolderID *primitive.ObjectID `bson:"folderId,omitempty" json:"folderId,omitempty"`
+
+	// URLKey is the normalised destination (see utils.NormalizeURL)
+	// used to find links to the same URL; empty for templates.
+	URLKey string `bson:"urlKey,omitempty" json:"-"`
+
+	// BaseURL is the destination before UTMs and Params were merged
+	// into it to form URL, so they can be changed later; Destinations,
+	// Rules and Schedule entries keep their own.  UTMPolicy records
+	// whether UTMs replaced or kept parameters already in BaseURL, and
+	// UTMPresetID is the preset they were taken from, if any.
+	BaseURL     string              `bson:"baseUrl,omitempty" json:"baseUrl,omitempty"`
+	UTMPolicy   string              `bson:"utmPolicy,omitempty" json:"utmPolicy,omitempty"`
+	Params      map[string]string   `bson:"params,omitempty" json:"params,omitempty"`
+	UTMPresetID *primitive.ObjectID `bson:"utmPresetId,omitempty" json:"utmPresetId,omitempty"`
+}
+
+// SafetyFlag records why a link's destination was blocked.  Check names
+// the safety check that blocked it.
+type SafetyFlag struct {
+	Check     string    `bson:"check" json:"check"`
+	Reason    string    `bson:"reason" json:"reason"`
+	FlaggedAt time.Time `bson:"flaggedAt" json:"flaggedAt"`
+}
+
+// LinkHealth records the last check of a link's destinations.
+// StatusCode is zero when the request failed outright, in which case
+// Error says why; URL is the destination that failed.  Broken is set
+// once ConsecutiveFailures reaches the checker's threshold and cleared
+// by the next successful check.
+type LinkHealth struct {
+	StatusCode          int       `bson:"statusCode,omitempty" json:"statusCode,omitempty"`
+	LatencyMillis       int64     `bson:"latencyMillis" json:"latencyMillis"`
+	LastCheckedAt       time.Time `bson:"lastCheckedAt" json:"lastCheckedAt"`
+	ConsecutiveFailures int       `bson:"consecutiveFailures" json:"consecutiveFailures"`
+	Broken              bool      `bson:"broken" json:"broken"`
+	Error               string    `bson:"error,omitempty" json:"error,omitempty"`
+	URL                 string    `bson:"url,omitempty" json:"url,omitempty"`
+}
+
+// LinkMetadata describes the destination page.  Error records why the
+// last fetch failed, in which case the other fields are empty.
+type LinkMetadata struct {
+	Title       string    `bson:"title,omitempty" json:"title,omitempty"`
+	Description string    `bson:"description,omitempty" json:"description,omitempty"`
+	FaviconURL  string    `bson:"faviconUrl,omitempty" json:"faviconUrl,omitempty"`
+	ImageURL    string    `bson:"imageUrl,omitempty" json:"imageUrl,omitempty"`
+	FetchedAt   time.Time `bson:"fetchedAt" json:"fetchedAt"`
+	Error       string    `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// PreviewMeta is the Open Graph / Twitter card metadata served to
+// social crawlers.  Image is an absolute http(s) URL.
+type PreviewMeta struct {
+	Title       string `bson:"title,omitempty" json:"title,omitempty"`
+	Description string `bson:"description,omitempty" json:"description,omitempty"`
+	Image       string `bson:"image,omitempty" json:"image,omitempty"`
+}
+
+// IsEmpty reports whether no preview field is set.  A nil preview is
+// empty.
+func (p *PreviewMeta) IsEmpty() bool {
+	return p == nil || (p.Title == "" && p.Description == "" && p.Image == "")
+}
+
+// DeepLinkConfig lists the app URIs (custom schemes such as
+// "myapp://product/1", or universal/app links) opened on each platform.
+type DeepLinkConfig struct {
+	IOS     string `bson:"ios,omitempty" json:"ios,omitempty"`
+	Android string `bson:"android,omitempty" json:"android,omitempty"`
 }
+
+// IsEmpty reports whether no app URI is configured.  A nil config is
+// empty.
+func (d *DeepLinkConfig) IsEmpty() bool {
+	return d == nil || (d.IOS == "" && d.Android == "")
+}
+
+// Destination is one weighted variant of an A/B split link.
+type Destination struct {
+	Label   string `bson:"label" json:"label"`
+	URL     string `bson:"url" json:"url"`
+	Weight  int    `bson:"weight" json:"weight"`
+	BaseURL string `bson:"baseUrl,omitempty" json:"baseUrl,omitempty"`
+}
+
+// TargetingRule sends matching visitors to URL.  Every non-empty
+// condition must match; within a condition any listed value matches.
+// OS is one of ios, android, windows, macos, linux or chromeos; Devices
+// one of mobile, tablet or desktop; Countries are ISO 3166-1 alpha-2
+// codes; Languages are BCP 47 tags matched against the visitor's
+// preferred Accept-Language ("en" also matches "en-GB").
+type TargetingRule struct {
+	OS        []string    `bson:"os,omitempty" json:"os,omitempty"`
+	Devices   []string    `bson:"devices,omitempty" json:"devices,omitempty"`
+	Countries []string    `bson:"countries,omitempty" json:"countries,omitempty"`
+	Languages []string    `bson:"languages,omitempty" json:"languages,omitempty"`
+	TimeOfDay *TimeWindow `bson:"timeOfDay,omitempty" json:"timeOfDay,omitempty"`
+	URL       string      `bson:"url" json:"url"`
+	BaseURL   string      `bson:"baseUrl,omitempty" json:"baseUrl,omitempty"`
+}
+
+// TimeWindow is a daily window from Start (inclusive) to End
+// (exclusive), both "HH:MM" in Timezone (an IANA name, UTC when
+// empty).  A window whose End is before its Start spans midnight.
+type TimeWindow struct {
+	Start    string `bson:"start" json:"start"`
+	End      string `bson:"end" json:"end"`
+	Timezone string `bson:"timezone,omitempty" json:"timezone,omitempty"`
+}
+
+// ScheduledDestination switches a link to URL from At onwards.
+type ScheduledDestination struct {
+	At      time.Time `bson:"at" json:"at"`
+	URL     string    `bson:"url" json:"url"`
+	BaseURL string    `bson:"baseUrl,omitempty" json:"baseUrl,omitempty"`
+}
+
+// DestinationAt returns the destination in effect at now: the URL of
+// the latest schedule entry whose At is not after now, or URL when no
+// entry has started yet.
+func (s *ShortURL) DestinationAt(now time.Time) string {
+	dest := s.URL
+	var latest time.Time
+	for _, entry := range s.Schedule {
+		if !entry.At.After(now) && !entry.At.Before(latest) {
+			dest = entry.URL
+			latest = entry.At
+		}
+	}
+	return dest
+}
+
+// IsActive reports whether the link has reached its activation time.
+func (s *ShortURL) IsActive(now time.Time) bool {
+	return s.ActivateAt == nil || !now.Before(*s.ActivateAt)
+}
+
+// PixelConfig holds the retargeting pixel IDs attached to a link.
+type PixelConfig struct {
+	FacebookID string `bson:"facebookId,omitempty" json:"facebookId,omitempty"`
+	GoogleID   string `bson:"googleId,omitempty" json:"googleId,omitempty"`
+	LinkedInID string `bson:"linkedinId,omitempty" json:"linkedinId,omitempty"`
+}
+
+// IsEmpty reports whether no pixel is configured.  It is safe to call
+// on a nil *PixelConfig.
+func (p *PixelConfig) IsEmpty() bool {
+	return p == nil || (p.FacebookID == "" && p.GoogleID == "" && p.LinkedInID == "")
+}
+
+// Query precedence values for ShortURL.QueryPrecedence.  An empty value
+// behaves like QueryPrecedenceStored.
+const (
+	QueryPrecedenceStored   = "stored"
+	QueryPrecedenceIncoming = "incoming"
+)
+
+// UTM policy values for ShortURL.UTMPolicy.  An empty value behaves
+// like UTMPolicyOverride.
+const (
+	UTMPolicyOverride = "override"
+	UTMPolicyKeep     = "keep"
+)
+
+// Redirect type values for ShortURL.RedirectType.
+const (
+	RedirectMovedPermanently = "301"
+	RedirectFound            = "302"
+	RedirectTemporary        = "307"
+	RedirectPermanent        = "308"
+	RedirectMetaRefresh      = "meta"
+	RedirectJavaScript       = "js"
+)
@@ -43,3 +43,71 @@ func TestShortURLJSON(t *testing.T) {
 		t.Error("expected non-empty json")
 	}
 }
+
+func TestPixelConfigIsEmpty(t *testing.T) {
+	var nilPixels *PixelConfig
+	if !nilPixels.IsEmpty() {
+		t.Error("expected nil pixel config to be empty")
+	}
+	if !(&PixelConfig{}).IsEmpty() {
+		t.Error("expected zero pixel config to be empty")
+	}
+	if (&PixelConfig{GoogleID: "AW-123"}).IsEmpty() {
+		t.Error("expected pixel config with an ID to be non-empty")
+	}
+}
+
+func TestDeepLinkConfigIsEmpty(t *testing.T) {
+	var nilDeepLink *DeepLinkConfig
+	if !nilDeepLink.IsEmpty() || !(&DeepLinkConfig{}).IsEmpty() {
+		t.Error("expected nil and zero deep link configs to be empty")
+	}
+	if (&DeepLinkConfig{Android: "myapp://x"}).IsEmpty() {
+		t.Error("expected deep link config with a URI to be non-empty")
+	}
+}
+
+func TestPreviewMetaIsEmpty(t *testing.T) {
+	var nilPreview *PreviewMeta
+	if !nilPreview.IsEmpty() || !(&PreviewMeta{}).IsEmpty() {
+		t.Error("expected nil and zero previews to be empty")
+	}
+	if (&PreviewMeta{Title: "Launch"}).IsEmpty() {
+		t.Error("expected preview with a title to be non-empty")
+	}
+}
+
+func TestShortURLDestinationAt(t *testing.T) {
+	base := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	s := ShortURL{
+		URL: "https://example.com/teaser",
+		Schedule: []ScheduledDestination{
+			{At: base.Add(24 * time.Hour), URL: "https://example.com/sale-ended"},
+			{At: base, URL: "https://example.com/sale"},
+		},
+	}
+	if got := s.DestinationAt(base.Add(-time.Minute)); got != "https://example.com/teaser" {
+		t.Errorf("expected teaser before schedule, got %s", got)
+	}
+	if got := s.DestinationAt(base); got != "https://example.com/sale" {
+		t.Errorf("expected sale at 09:00, got %s", got)
+	}
+	if got := s.DestinationAt(base.Add(48 * time.Hour)); got != "https://example.com/sale-ended" {
+		t.Errorf("expected last entry afterwards, got %s", got)
+	}
+}
+
+func TestShortURLIsActive(t *testing.T) {
+	now := time.Now().UTC()
+	later := now.Add(time.Hour)
+	if !(&ShortURL{}).IsActive(now) {
+		t.Error("expected link without activation time to be active")
+	}
+	s := ShortURL{ActivateAt: &later}
+	if s.IsActive(now) {
+		t.Error("expected link to be inactive before ActivateAt")
+	}
+	if !s.IsActive(later) {
+		t.Error("expected link to be active at ActivateAt")
+	}
+}
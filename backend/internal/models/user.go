@@ -6,9 +6,26 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// User is an account that owns short links.  ExpiredRedirectURL and
+// NotFoundRedirectURL are the user's default fallback destinations for
+// expired links and unknown slugs on domains they own.  Role grants
+// extra permissions; RoleAdmin users may moderate reported links.
 type User struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Username  string             `bson:"username" json:"username"`
 	Password  string             `bson:"password" json:"-"`
+	Teams     []string           `bson:"teams,omitempty" json:"teams,omitempty"`
 	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	Role      string             `bson:"role,omitempty" json:"role,omitempty"`
+
+	ExpiredRedirectURL  string `bson:"expiredRedirectUrl,omitempty" json:"expiredRedirectUrl,omitempty"`
+	NotFoundRedirectURL string `bson:"notFoundRedirectUrl,omitempty" json:"notFoundRedirectUrl,omitempty"`
+}
+
+// RoleAdmin is the Role of moderators.
+const RoleAdmin = "admin"
+
+// IsAdmin reports whether the user may moderate links.
+func (u *User) IsAdmin() bool {
+	return u != nil && u.Role == RoleAdmin
 }
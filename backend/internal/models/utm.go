@@ -0,0 +1,55 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UTMKeys are the standard UTM keys presets and vocabularies use, in the
+// order they are written into destinations.
+var UTMKeys = []string{"source", "medium", "campaign", "term", "content"}
+
+// UTMPreset is a saved set of UTM values and custom tracking parameters
+// links can be created from.  A preset belongs to Owner and, when Team
+// is set, may also be used by the members of that team.  UTMs are keyed
+// by the UTMKeys without the "utm_" prefix.
+type UTMPreset struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Owner     string             `bson:"owner" json:"owner"`
+	Team      string             `bson:"team,omitempty" json:"team,omitempty"`
+	Name      string             `bson:"name" json:"name"`
+	UTMs      map[string]string  `bson:"utms,omitempty" json:"utms,omitempty"`
+	Params    map[string]string  `bson:"params,omitempty" json:"params,omitempty"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// UTMVocabulary lists the values allowed for each UTM key, either for a
+// single user (Owner set, Team empty) or for a team (Team set, Owner
+// empty).  Keys without a list accept any value.  The lists only
+// restrict new links and UTM edits when Enforce is true; otherwise they
+// are suggestions.
+type UTMVocabulary struct {
+	Owner     string              `bson:"owner" json:"owner,omitempty"`
+	Team      string              `bson:"team" json:"team,omitempty"`
+	Allowed   map[string][]string `bson:"allowed" json:"allowed"`
+	Enforce   bool                `bson:"enforce" json:"enforce"`
+	UpdatedBy string              `bson:"updatedBy" json:"updatedBy"`
+	UpdatedAt time.Time           `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Allows reports whether value may be used for the UTM key, which may
+// carry the "utm_" prefix.
+func (v *UTMVocabulary) Allows(key, value string) bool {
+	allowed, ok := v.Allowed[strings.TrimPrefix(key, "utm_")]
+	if !ok {
+		return true
+	}
+	for _, a := range allowed {
+		if a == value {
+			return true
+		}
+	}
+	return false
+}
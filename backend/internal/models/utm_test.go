@@ -0,0 +1,21 @@
+package models
+
+import "testing"
+
+func TestUTMVocabularyAllows(t *testing.T) {
+	v := &UTMVocabulary{Allowed: map[string][]string{"medium": {"email", "social"}}}
+	tests := []struct {
+		key, value string
+		want       bool
+	}{
+		{"medium", "email", true},
+		{"utm_medium", "social", true},
+		{"medium", "Email", false},
+		{"source", "anything", true},
+	}
+	for _, tt := range tests {
+		if got := v.Allows(tt.key, tt.value); got != tt.want {
+			t.Errorf("Allows(%q, %q) = %v, want %v", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Package pages renders the small HTML pages served to visitors of
+// short links, such as meta-refresh, JavaScript and retargeting pixel
+// redirect pages, the password prompt for protected links, the
+// holding page for links that are not active yet and the branded
+// "expired", "not found" and "blocked" pages, the confirmation shown
+// after reporting a link and the public link info page.
+// Templates are embedded in the binary and parsed once at start-up;
+// LoadDir lets a deployment replace any of them with its own branding.
+package pages
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var (
+	mu        sync.RWMutex
+	templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+)
+
+// Template names understood by Render.
+const (
+	MetaRefresh = "meta_refresh.html"
+	JSRedirect  = "js_redirect.html"
+	PixelPage   = "pixel_redirect.html"
+	Password    = "password_prompt.html"
+	Holding     = "holding.html"
+	Expired     = "expired.html"
+	NotFound    = "not_found.html"
+	DeepLink    = "deep_link.html"
+	Social      = "social_preview.html"
+	Blocked     = "blocked.html"
+	Reported    = "reported.html"
+	Info        = "link_info.html"
+)
+
+// RedirectData is the data passed to the redirect page templates.
+type RedirectData struct {
+	URL string
+}
+
+// PixelData is the data passed to the pixel interstitial.  Empty IDs
+// omit the corresponding snippet.  DelayMillis gives the pixels time
+// to fire before the visitor is forwarded.
+type PixelData struct {
+	URL         string
+	FacebookID  string
+	GoogleID    string
+	LinkedInID  string
+	DelayMillis int
+}
+
+// DeepLinkData is the data passed to the deep-link page, which tries
+// to open AppURL in the installed app and falls back to URL after
+// DelayMillis.  AppURL is typed as template.URL because app schemes
+// (e.g. "myapp://") would otherwise be filtered; callers must reject
+// dangerous schemes before rendering.
+type DeepLinkData struct {
+	AppURL      template.URL
+	URL         string
+	DelayMillis int
+}
+
+// SocialData is the data passed to the page served to social
+// crawlers.  URL is the short link itself, used as the canonical URL.
+type SocialData struct {
+	URL         string
+	Title       string
+	Description string
+	Image       string
+}
+
+// InfoData is the data passed to the public link info page.
+// Destination is empty when it is withheld, in which case Message says
+// why.  OtherDestinations lists the other URLs visitors may be sent to
+// by A/B splits and targeting rules.  Nil Clicks hides the count.
+type InfoData struct {
+	ShortLink         string
+	Destination       string
+	OtherDestinations []string
+	Message           string
+	CreatedAt         time.Time
+	ExpireAt          *time.Time
+	Owner             string
+	Clicks            *int64
+}
+
+// PasswordData is the data passed to the password prompt.  Error is
+// shown above the form after a failed attempt.
+type PasswordData struct {
+	Error string
+}
+
+// HoldingData is the data passed to the holding page.
+type HoldingData struct {
+	ActivateAt *time.Time
+}
+
+// StatusData is the data passed to the expired, not-found, blocked and
+// report confirmation pages.  Host is the short domain the visitor requested.
+type StatusData struct {
+	Host    string
+	Message string
+}
+
+// LoadDir overrides the embedded templates with any *.html files in
+// dir whose names match a built-in template (for example
+// "not_found.html").  Templates not present in dir keep their embedded
+// defaults.
+func LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no templates found in %s", dir)
+	}
+	// Executed templates cannot be cloned, so start from a fresh parse
+	// of the embedded defaults
+	t, err := template.ParseFS(templateFS, "templates/*.html")
+	if err != nil {
+		return err
+	}
+	if _, err := t.ParseFiles(files...); err != nil {
+		return err
+	}
+	mu.Lock()
+	templates = t
+	mu.Unlock()
+	return nil
+}
+
+// Render executes the named template and writes it with the given
+// status code.  The template is rendered into a buffer first so that
+// a template error never produces a half-written page.
+func Render(w http.ResponseWriter, status int, name string, data any) error {
+	mu.RLock()
+	t := templates
+	mu.RUnlock()
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,74 @@
+package pages
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderRedirectPages(t *testing.T) {
+	dest := `https://example.com/?a=1&b="x"</script>`
+	for _, name := range []string{MetaRefresh, JSRedirect} {
+		w := httptest.NewRecorder()
+		if err := Render(w, http.StatusOK, name, RedirectData{URL: dest}); err != nil {
+			t.Fatalf("%s: render error: %v", name, err)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("%s: unexpected content type %s", name, ct)
+		}
+		body := w.Body.String()
+		if strings.Contains(body, "</script>\"") || strings.Contains(body, `"x"</script>`) {
+			t.Errorf("%s: destination was not escaped: %s", name, body)
+		}
+		if !strings.Contains(body, "example.com") {
+			t.Errorf("%s: destination missing from page", name)
+		}
+	}
+}
+
+func TestRenderDeepLinkPage(t *testing.T) {
+	w := httptest.NewRecorder()
+	data := DeepLinkData{AppURL: "myapp://product/1", URL: "https://example.com/p/1", DelayMillis: 1500}
+	if err := Render(w, http.StatusOK, DeepLink, data); err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `href="myapp://product/1"`) {
+		t.Errorf("expected app link in page: %s", body)
+	}
+	if !strings.Contains(body, "1500") || !strings.Contains(body, "example.com/p/1") {
+		t.Errorf("expected web fallback in page: %s", body)
+	}
+}
+
+func TestLoadDirOverridesTemplates(t *testing.T) {
+	t.Cleanup(func() {
+		templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+	})
+	dir := t.TempDir()
+	custom := `<html><body>Brand: {{.Host}} — {{.Message}}</body></html>`
+	if err := os.WriteFile(filepath.Join(dir, NotFound), []byte(custom), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDir(dir); err != nil {
+		t.Fatalf("LoadDir error: %v", err)
+	}
+	w := httptest.NewRecorder()
+	_ = Render(w, http.StatusNotFound, NotFound, StatusData{Host: "go.brand.com", Message: "Nope"})
+	if !strings.Contains(w.Body.String(), "Brand: go.brand.com") {
+		t.Errorf("expected overridden template, got %s", w.Body.String())
+	}
+	// Templates not in the directory keep their defaults
+	w = httptest.NewRecorder()
+	_ = Render(w, http.StatusGone, Expired, StatusData{Message: "Gone"})
+	if !strings.Contains(w.Body.String(), "Link expired") {
+		t.Errorf("expected default expired template, got %s", w.Body.String())
+	}
+	if err := LoadDir(t.TempDir()); err == nil {
+		t.Error("expected error for a directory without templates")
+	}
+}
@@ -0,0 +1,254 @@
+// Package qr renders QR codes for short links as PNG or SVG images,
+// with custom colours and an optional logo in the centre.
+package qr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"  // logo formats
+	_ "image/jpeg" // logo formats
+	"image/png"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// LogoRatio is the width of the centred logo relative to the code.
+// Together with its padding it hides well under the 30% of modules
+// that level H can recover.
+const LogoRatio = 0.2
+
+// MinContrast is the lowest foreground/background contrast ratio (as
+// defined by WCAG) accepted, below which scanners struggle.
+const MinContrast = 3.0
+
+// Options controls how a code is drawn.  Size is the width and height
+// of the image in pixels; codes needing more pixels than that are
+// drawn larger.  Level is the error correction level (L, M, Q or H).
+type Options struct {
+	Size       int
+	Level      string
+	Foreground color.RGBA
+	Background color.RGBA
+	Logo       image.Image
+}
+
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// ValidLevel reports whether level is one of L, M, Q or H.
+func ValidLevel(level string) bool {
+	_, ok := levels[level]
+	return ok
+}
+
+// ParseColor parses a hex colour such as "1a2b3c", "#1A2B3C" or "fff".
+func ParseColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// Contrast returns the WCAG contrast ratio of two colours, from 1 (no
+// contrast) to 21 (black on white).
+func Contrast(a, b color.RGBA) float64 {
+	la, lb := luminance(a), luminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+func luminance(c color.RGBA) float64 {
+	channel := func(v uint8) float64 {
+		f := float64(v) / 255
+		if f <= 0.03928 {
+			return f / 12.92
+		}
+		return math.Pow((f+0.055)/1.055, 2.4)
+	}
+	return 0.2126*channel(c.R) + 0.7152*channel(c.G) + 0.0722*channel(c.B)
+}
+
+// LoadLogo reads a PNG, JPEG or GIF logo.
+func LoadLogo(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// encode builds the code for content.
+func encode(content string, opts Options) (*qrcode.QRCode, error) {
+	level, ok := levels[opts.Level]
+	if !ok {
+		return nil, errors.New("error correction level must be L, M, Q or H")
+	}
+	code, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, err
+	}
+	code.ForegroundColor = opts.Foreground
+	code.BackgroundColor = opts.Background
+	return code, nil
+}
+
+// PNG renders content as a PNG image.
+func PNG(content string, opts Options) ([]byte, error) {
+	code, err := encode(content, opts)
+	if err != nil {
+		return nil, err
+	}
+	var img image.Image = code.Image(opts.Size)
+	if opts.Logo != nil {
+		canvas := image.NewRGBA(img.Bounds())
+		draw.Draw(canvas, canvas.Bounds(), img, image.Point{}, draw.Src)
+		drawLogo(canvas, opts.Logo, opts.Background)
+		img = canvas
+	}
+	var buf bytes.Buffer
+	if err := (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawLogo paints logo, scaled to LogoRatio of the canvas and framed by
+// the background colour, in the centre of canvas.
+func drawLogo(canvas *image.RGBA, logo image.Image, bg color.RGBA) {
+	size := canvas.Bounds().Dx()
+	box := int(float64(size) * LogoRatio)
+	pad := box / 10
+	frameMin := (size - box) / 2
+	frame := image.Rect(frameMin-pad, frameMin-pad, frameMin+box+pad, frameMin+box+pad)
+	draw.Draw(canvas, frame, image.NewUniform(bg), image.Point{}, draw.Src)
+	w, h := fitWithin(logo.Bounds().Dx(), logo.Bounds().Dy(), box)
+	scaled := scale(logo, w, h)
+	at := image.Pt((size-w)/2, (size-h)/2)
+	draw.Draw(canvas, scaled.Bounds().Add(at), scaled, image.Point{}, draw.Over)
+}
+
+// fitWithin returns the size of a w×h image scaled to fit in a box×box
+// square, keeping its aspect ratio.
+func fitWithin(w, h, box int) (int, int) {
+	if w <= 0 || h <= 0 {
+		return box, box
+	}
+	if w >= h {
+		return box, max(1, h*box/w)
+	}
+	return max(1, w*box/h), box
+}
+
+// scale resizes src to w×h, averaging the source pixels covered by each
+// destination pixel so that downscaled logos stay smooth.
+func scale(src image.Image, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	b := src.Bounds()
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := max(y0+1, b.Min.Y+(y+1)*b.Dy()/h)
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := max(x0+1, b.Min.X+(x+1)*b.Dx()/w)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// SVG renders content as an SVG image.  Modules are drawn as one path
+// so the file stays small and scales without blurring.
+func SVG(content string, opts Options) ([]byte, error) {
+	code, err := encode(content, opts)
+	if err != nil {
+		return nil, err
+	}
+	bitmap := code.Bitmap()
+	n := len(bitmap)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, opts.Size, opts.Size, n, n)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/>`, n, n, hexColor(opts.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, hexColor(opts.Foreground))
+	for y, row := range bitmap {
+		for x := 0; x < n; {
+			if !row[x] {
+				x++
+				continue
+			}
+			run := 1
+			for x+run < n && row[x+run] {
+				run++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", x, y, run, run)
+			x += run
+		}
+	}
+	buf.WriteString(`"/>`)
+	if opts.Logo != nil {
+		if err := writeSVGLogo(&buf, opts.Logo, n, opts.Background); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteString(`</svg>`)
+	return buf.Bytes(), nil
+}
+
+// svgLogoPixels caps the resolution of the logo embedded in SVG codes.
+const svgLogoPixels = 256
+
+// writeSVGLogo embeds logo as a PNG data URI in the centre of an n×n
+// module code.
+func writeSVGLogo(buf *bytes.Buffer, logo image.Image, n int, bg color.RGBA) error {
+	box := float64(n) * LogoRatio
+	pad := box / 10
+	min := (float64(n) - box) / 2
+	fmt.Fprintf(buf, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s"/>`, min-pad, min-pad, box+2*pad, box+2*pad, hexColor(bg))
+	w, h := logo.Bounds().Dx(), logo.Bounds().Dy()
+	if w > svgLogoPixels || h > svgLogoPixels {
+		w, h = fitWithin(w, h, svgLogoPixels)
+		logo = scale(logo, w, h)
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, logo); err != nil {
+		return err
+	}
+	fmt.Fprintf(buf, `<image x="%.2f" y="%.2f" width="%.2f" height="%.2f" href="data:image/png;base64,%s"/>`,
+		min, min, box, box, base64.StdEncoding.EncodeToString(encoded.Bytes()))
+	return nil
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,92 @@
+package qr
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+var (
+	black = color.RGBA{A: 0xff}
+	white = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want color.RGBA
+		ok   bool
+	}{
+		{"#1a2b3c", color.RGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0xff}, true},
+		{"FFF", white, true},
+		{"abcd", color.RGBA{}, false},
+		{"gggggg", color.RGBA{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseColor(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseColor(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestContrast(t *testing.T) {
+	if c := Contrast(black, white); c < 20.9 || c > 21.1 {
+		t.Errorf("expected 21:1 for black on white, got %.2f", c)
+	}
+	if c := Contrast(white, white); c != 1 {
+		t.Errorf("expected 1:1 for identical colours, got %.2f", c)
+	}
+}
+
+func TestPNG(t *testing.T) {
+	logo := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	red := color.RGBA{R: 0xff, A: 0xff}
+	for i := range logo.Pix {
+		logo.Pix[i] = []uint8{red.R, red.G, red.B, red.A}[i%4]
+	}
+	data, err := PNG("https://sho.rt/abc", Options{Size: 300, Level: "H", Foreground: black, Background: white, Logo: logo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 300 {
+		t.Errorf("expected 300px image, got %d", img.Bounds().Dx())
+	}
+	r, g, b, _ := img.At(150, 150).RGBA()
+	if r>>8 != 0xff || g != 0 || b != 0 {
+		t.Errorf("expected logo in the centre, got %v", img.At(150, 150))
+	}
+	if _, err := PNG("x", Options{Size: 100, Level: "Z"}); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
+
+func TestSVG(t *testing.T) {
+	data, err := SVG("https://sho.rt/abc", Options{Size: 512, Level: "M", Foreground: color.RGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xff}, Background: white})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := string(data)
+	for _, want := range []string{`width="512"`, `fill="#112233"`, `fill="#ffffff"`, "<path"} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("expected %s in svg", want)
+		}
+	}
+	if strings.Contains(svg, "<image") {
+		t.Error("expected no logo")
+	}
+	data, err = SVG("https://sho.rt/abc", Options{Size: 512, Level: "H", Foreground: black, Background: white, Logo: image.NewRGBA(image.Rect(0, 0, 600, 300))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `href="data:image/png;base64,`) {
+		t.Error("expected embedded logo")
+	}
+}
@@ -0,0 +1,89 @@
+// Package ratelimit provides a small in-memory limiter for counting
+// failed attempts per key (for example per client IP and slug) within
+// a fixed time window.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter blocks a key once it has recorded Max failures within
+// Window.  The window starts at the first failure and the count is
+// cleared when it elapses or when Reset is called.  Now may be
+// replaced in tests to control the clock.
+type Limiter struct {
+	Max    int
+	Window time.Duration
+	Now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	failures int
+	start    time.Time
+}
+
+// New returns a Limiter allowing max failures per window.
+func New(max int, window time.Duration) *Limiter {
+	return &Limiter{Max: max, Window: window, Now: time.Now, entries: make(map[string]*entry)}
+}
+
+// Blocked reports whether key has exhausted its failures for the
+// current window.
+func (l *Limiter) Blocked(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := l.current(key)
+	return e != nil && e.failures >= l.Max
+}
+
+// Fail records a failed attempt for key.
+func (l *Limiter) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := l.current(key)
+	if e == nil {
+		e = &entry{start: l.Now()}
+		l.entries[key] = e
+	}
+	e.failures++
+	l.prune()
+}
+
+// Reset clears the failures recorded for key.
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
+
+// current returns the live entry for key, discarding it if its window
+// has elapsed.  Callers must hold l.mu.
+func (l *Limiter) current(key string) *entry {
+	e, ok := l.entries[key]
+	if !ok {
+		return nil
+	}
+	if l.Now().Sub(e.start) >= l.Window {
+		delete(l.entries, key)
+		return nil
+	}
+	return e
+}
+
+// prune drops expired entries so the map does not grow without bound.
+// It only scans when the map is large.  Callers must hold l.mu.
+func (l *Limiter) prune() {
+	if len(l.entries) < 10000 {
+		return
+	}
+	now := l.Now()
+	for k, e := range l.entries {
+		if now.Sub(e.start) >= l.Window {
+			delete(l.entries, k)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(3, time.Minute)
+	l.Now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if l.Blocked("ip|slug") {
+			t.Fatalf("blocked after %d failures", i)
+		}
+		l.Fail("ip|slug")
+	}
+	if !l.Blocked("ip|slug") {
+		t.Fatal("expected key to be blocked after 3 failures")
+	}
+	if l.Blocked("other|slug") {
+		t.Fatal("expected other keys to be unaffected")
+	}
+
+	// The window elapses
+	now = now.Add(time.Minute)
+	if l.Blocked("ip|slug") {
+		t.Fatal("expected block to expire with the window")
+	}
+
+	l.Fail("ip|slug")
+	l.Reset("ip|slug")
+	if l.Blocked("ip|slug") {
+		t.Fatal("expected reset to clear failures")
+	}
+}
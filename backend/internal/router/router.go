@@ -44,10 +44,53 @@ func NewRouter(h *handlers.Handler) http.Handler {
 			protected.Use(handlers.JWTAuthMiddleware)
 			protected.Post("/shorten", h.Shorten)
 			protected.Get("/slugs", h.Slugs)
+			protected.Get("/slugs/broken", h.BrokenSlugs)
+			protected.Get("/slugs/{slug}/stats", h.Stats)
+			protected.Get("/slugs/{slug}/qr", h.QRCode)
+			protected.Put("/slugs/{slug}/utms", h.UpdateUTMs)
+			protected.Get("/lookup", h.Lookup)
 			protected.Post("/checkSlug", h.CheckSlug)
+			protected.Post("/folders", h.CreateFolder)
+			protected.Get("/folders", h.ListFolders)
+			protected.Put("/folders/{id}", h.RenameFolder)
+			protected.Delete("/folders/{id}", h.DeleteFolder)
+			protected.Get("/tags", h.ListTags)
+			protected.Post("/tags/merge", h.MergeTags)
+			protected.Post("/tags/{tag}/rename", h.RenameTag)
+			protected.Post("/utm/presets", h.CreateUTMPreset)
+			protected.Get("/utm/presets", h.ListUTMPresets)
+			protected.Delete("/utm/presets/{id}", h.DeleteUTMPreset)
+			protected.Get("/utm/vocabularies", h.ListUTMVocabularies)
+			protected.Put("/utm/vocabularies", h.SetUTMVocabulary)
+			protected.Post("/domains", h.CreateDomain)
+			protected.Get("/domains", h.ListDomains)
+			protected.Post("/domains/{host}/verify", h.VerifyDomain)
+			protected.Put("/domains/{host}/fallbacks", h.UpdateDomainFallbacks)
+			protected.Put("/domains/{host}/apps", h.UpdateDomainApps)
+			protected.Put("/settings/fallbacks", h.UpdateUserFallbacks)
+			// Moderation API for users with the admin role
+			protected.Route("/admin", func(admin chi.Router) {
+				admin.Use(h.AdminOnly)
+				admin.Get("/reports", h.ListReports)
+				admin.Post("/reports/{id}/dismiss", h.DismissReport)
+				admin.Get("/flagged", h.ListFlagged)
+				admin.Post("/links/{slug}/disable", h.DisableLink)
+				admin.Post("/links/{slug}/enable", h.EnableLink)
+				admin.Post("/links/{slug}/unflag", h.UnflagLink)
+				admin.Delete("/links/{slug}", h.DeleteLink)
+				admin.Get("/audit", h.AuditLog)
+			})
 		})
 	})
+	// App association files take precedence over the slug routes
+	r.Get("/.well-known/apple-app-site-association", h.AppleAppSiteAssociation)
+	r.Get("/.well-known/assetlinks.json", h.AssetLinks)
+	r.Get("/{slug}+", h.Info)
 	r.Get("/{slug}", h.Redirect)
+	r.Get("/{slug}/*", h.Redirect)
+	r.Post("/{slug}", h.Unlock)
+	r.Post("/{slug}/report", h.Report)
+	r.Post("/{slug}/*", h.Unlock)
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		_, _ = w.Write([]byte(`{"message":"URL Shortener API"}`))
@@ -5,9 +5,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/richmondwang/symph-url-shortener/internal/handlers"
 	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type mockURLShortener struct{}
@@ -16,14 +19,23 @@ type mockUserService struct{}
 func (m *mockURLShortener) Shorten(ctx context.Context, req models.ShortURL) (models.ShortURL, error) {
 	return req, nil
 }
-func (m *mockURLShortener) GetBySlug(ctx context.Context, slug string) (*models.ShortURL, error) {
+func (m *mockURLShortener) GetBySlug(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
 	return &models.ShortURL{Slug: slug, URL: "https://x.com"}, nil
 }
-func (m *mockURLShortener) IncrementRedirectCount(ctx context.Context, slug string) error { return nil }
-func (m *mockURLShortener) ListByUser(ctx context.Context, username string, page, size int, includeExpired bool) ([]models.ShortURL, error) {
+func (m *mockURLShortener) GetStored(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
+	return &models.ShortURL{Slug: slug, URL: "https://x.com"}, nil
+}
+func (m *mockURLShortener) IncrementRedirectCount(ctx context.Context, domain, slug string) error {
+	return nil
+}
+func (m *mockURLShortener) ListByUser(ctx context.Context, username string, opts services.ListOptions) ([]models.ShortURL, error) {
 	return []models.ShortURL{{Slug: "slugged", URL: "https://x.com"}}, nil
 }
 
+func (m *mockURLShortener) CountByUser(ctx context.Context, username string, opts services.ListOptions) (int64, error) {
+	return 1, nil
+}
+
 func (m *mockUserService) Register(ctx context.Context, username, password string) error { return nil }
 func (m *mockUserService) Login(ctx context.Context, username, password string) (*models.User, error) {
 	return &models.User{Username: username}, nil
@@ -31,7 +43,52 @@ func (m *mockUserService) Login(ctx context.Context, username, password string)
 func (m *mockUserService) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	return &models.User{Username: username}, nil
 }
-func (m *mockURLShortener) IsSlugAvailable(ctx context.Context, slug string) (bool, error) {
+func (m *mockUserService) UpdateFallbacks(ctx context.Context, username, expiredURL, notFoundURL string) error {
+	return nil
+}
+func (m *mockURLShortener) UpdateMetadata(ctx context.Context, domain, slug string, meta models.LinkMetadata) error {
+	return nil
+}
+func (m *mockURLShortener) ListActive(ctx context.Context, now time.Time, fn func(models.ShortURL) error) error {
+	return nil
+}
+func (m *mockURLShortener) UpdateHealth(ctx context.Context, domain, slug string, health models.LinkHealth) error {
+	return nil
+}
+func (m *mockURLShortener) ListBroken(ctx context.Context, username string) ([]models.ShortURL, error) {
+	return nil, nil
+}
+func (m *mockURLShortener) Flag(ctx context.Context, domain, slug string, flag models.SafetyFlag) error {
+	return nil
+}
+func (m *mockURLShortener) Unflag(ctx context.Context, domain, slug string) error {
+	return nil
+}
+func (m *mockURLShortener) ListFlagged(ctx context.Context, page, size int) ([]models.ShortURL, error) {
+	return nil, nil
+}
+func (m *mockURLShortener) SetDisabled(ctx context.Context, domain, slug string, disabled bool) error {
+	return nil
+}
+func (m *mockURLShortener) Delete(ctx context.Context, domain, slug string) error {
+	return nil
+}
+func (m *mockURLShortener) ListTags(ctx context.Context, username string) ([]models.TagCount, error) {
+	return nil, nil
+}
+func (m *mockURLShortener) ReplaceTags(ctx context.Context, username string, from []string, to string) (int64, error) {
+	return 0, nil
+}
+func (m *mockURLShortener) ClearFolder(ctx context.Context, username string, folderID primitive.ObjectID) (int64, error) {
+	return 0, nil
+}
+func (m *mockURLShortener) FindByURLKey(ctx context.Context, username, key string, domain *string) ([]models.ShortURL, error) {
+	return nil, nil
+}
+func (m *mockURLShortener) UpdateUTMs(ctx context.Context, domain, slug string, link models.ShortURL) error {
+	return nil
+}
+func (m *mockURLShortener) IsSlugAvailable(ctx context.Context, domain, slug string) (bool, error) {
 	return true, nil // default: always available for tests
 }
 
@@ -79,6 +136,22 @@ func TestRouterSetup(t *testing.T) {
 		t.Errorf("expected 401 for slugs, got %d", w.Result().StatusCode)
 	}
 
+	// Admin endpoints require a token
+	req = httptest.NewRequest("GET", "/api/admin/reports", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 for admin reports, got %d", w.Result().StatusCode)
+	}
+
+	// Reports reach the report handler rather than the password unlock
+	req = httptest.NewRequest("POST", "/abc123/report", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusNotFound || w.Result().Header.Get("Content-Type") != "application/json" {
+		t.Errorf("expected JSON 404 for report without moderation, got %d", w.Result().StatusCode)
+	}
+
 	// Test redirect endpoint
 	req = httptest.NewRequest("GET", "/abc123", nil)
 	w = httptest.NewRecorder()
@@ -86,4 +159,29 @@ func TestRouterSetup(t *testing.T) {
 	if w.Result().StatusCode != http.StatusMovedPermanently {
 		t.Errorf("expected 301 for redirect, got %d", w.Result().StatusCode)
 	}
+
+	// A "+" suffix shows the info page instead of redirecting
+	req = httptest.NewRequest("GET", "/abc123+", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusOK || w.Result().Header.Get("Location") != "" {
+		t.Errorf("expected 200 info page for slug+, got %d", w.Result().StatusCode)
+	}
+
+	// Trailing paths reach the redirect handler; the mock link does not
+	// forward paths so the request is not found
+	req = httptest.NewRequest("GET", "/abc123/docs/page", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for unforwarded path, got %d", w.Result().StatusCode)
+	}
+
+	// App association files are not treated as slugs
+	req = httptest.NewRequest("GET", "/.well-known/assetlinks.json", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusNotFound || w.Result().Header.Get("Content-Type") != "application/json" {
+		t.Errorf("expected JSON 404 for unconfigured assetlinks.json, got %d", w.Result().StatusCode)
+	}
 }
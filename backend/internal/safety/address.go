@@ -0,0 +1,54 @@
+package safety
+
+import (
+	"context"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/richmondwang/symph-url-shortener/internal/metadata"
+)
+
+// privateSuffixes are name suffixes that only resolve inside private
+// networks.
+var privateSuffixes = []string{".localhost", ".local", ".internal", ".lan", ".intranet", ".corp", ".home.arpa"}
+
+// Addresses blocks IP-literal destinations and hosts on private
+// networks.  With a Resolver, host names are also resolved and
+// blocked when any address is not public; lookup failures pass, as an
+// unresolvable host is broken rather than unsafe.
+type Addresses struct {
+	Resolver Resolver
+}
+
+// Name implements Check.
+func (Addresses) Name() string { return "address" }
+
+// Check implements Check.
+func (a Addresses) Check(ctx context.Context, u *url.URL) (string, error) {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if _, err := netip.ParseAddr(host); err == nil {
+		return "destination is an IP address", nil
+	}
+	if host == "localhost" || !strings.Contains(host, ".") {
+		return "destination is a private network host", nil
+	}
+	for _, suffix := range privateSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return "destination is a private network host", nil
+		}
+	}
+	if a.Resolver == nil {
+		return "", nil
+	}
+	addrs, err := a.Resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return "", nil
+	}
+	for _, addr := range addrs {
+		if !metadata.IsPublicAddr(addr) {
+			return "destination resolves to a private network address", nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,101 @@
+package safety
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// DomainList is a set of domains.  A domain matches itself and all of
+// its subdomains.  A nil list is empty.
+type DomainList struct {
+	domains map[string]bool
+}
+
+// NewDomainList returns a list holding domains.
+func NewDomainList(domains ...string) *DomainList {
+	l := &DomainList{domains: map[string]bool{}}
+	for _, d := range domains {
+		l.Add(d)
+	}
+	return l
+}
+
+// LoadDomainList reads a list from a file; see ParseDomainList.
+func LoadDomainList(path string) (*DomainList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseDomainList(f)
+}
+
+// ParseDomainList reads one domain per line.  Blank lines and text
+// after "#" are ignored, and a leading "*." or "." is accepted for
+// readability since subdomains always match.
+func ParseDomainList(r io.Reader) (*DomainList, error) {
+	l := NewDomainList()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		l.Add(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Add adds a domain to the list.
+func (l *DomainList) Add(domain string) {
+	domain = strings.TrimPrefix(strings.TrimSpace(domain), "*")
+	if domain = canonicalHost(domain); domain != "" {
+		l.domains[domain] = true
+	}
+}
+
+// Len returns the number of domains in the list.
+func (l *DomainList) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.domains)
+}
+
+// Contains reports whether host or one of its parent domains is on
+// the list.
+func (l *DomainList) Contains(host string) bool {
+	if l.Len() == 0 {
+		return false
+	}
+	host = canonicalHost(host)
+	for host != "" {
+		if l.domains[host] {
+			return true
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			break
+		}
+		host = host[i+1:]
+	}
+	return false
+}
+
+// canonicalHost lower-cases host, trims surrounding dots and converts
+// internationalized names to their ASCII (punycode) form so that both
+// spellings of a domain match.
+func canonicalHost(host string) string {
+	host = strings.Trim(strings.ToLower(strings.TrimSpace(host)), ".")
+	if ascii, err := idna.ToASCII(host); err == nil {
+		host = ascii
+	}
+	return host
+}
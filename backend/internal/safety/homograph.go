@@ -0,0 +1,118 @@
+package safety
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+)
+
+// Script combinations that legitimately share a label, as in Japanese
+// names mixing kanji, kana and Latin letters.
+var allowedScriptSets = [][]string{
+	{"Latin", "Han", "Hiragana", "Katakana"},
+	{"Latin", "Han", "Hangul"},
+	{"Latin", "Han", "Bopomofo"},
+}
+
+// latinLookalikes holds Cyrillic and Greek letters that render like
+// Latin ones.  A label spelled only with them can imitate a Latin name
+// ("аррӏе" for "apple").
+var latinLookalikes = map[string]string{
+	"Cyrillic": "аеорсухіјѕһԁӏԛԝԍьвнкмт",
+	"Greek":    "αικνορτυχ",
+}
+
+// Homographs blocks internationalized domain names built to imitate
+// other domains: labels mixing Latin with look-alike scripts and
+// labels written entirely in Latin look-alike letters.  Names that
+// are not valid IDNA are blocked as well.
+type Homographs struct{}
+
+// Name implements Check.
+func (Homographs) Name() string { return "homograph" }
+
+// Check implements Check.
+func (Homographs) Check(ctx context.Context, u *url.URL) (string, error) {
+	host := strings.ToLower(u.Hostname())
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "destination is not a valid internationalized domain name", nil
+	}
+	for _, label := range strings.Split(ascii, ".") {
+		if !strings.HasPrefix(label, "xn--") {
+			continue
+		}
+		unicodeLabel, err := idna.Lookup.ToUnicode(label)
+		if err != nil {
+			return "destination is not a valid internationalized domain name", nil
+		}
+		if reason := homographReason(unicodeLabel); reason != "" {
+			return reason, nil
+		}
+	}
+	return "", nil
+}
+
+// homographReason returns why label looks deceptive, or "".
+func homographReason(label string) string {
+	scripts := map[string]bool{}
+	for _, r := range label {
+		if name := scriptOf(r); name != "" {
+			scripts[name] = true
+		}
+	}
+	if len(scripts) > 1 && !allowedMix(scripts) {
+		return "destination domain mixes scripts"
+	}
+	if len(scripts) == 1 {
+		for name := range scripts {
+			lookalikes, ok := latinLookalikes[name]
+			if ok && onlyRunes(label, lookalikes) {
+				return "destination domain imitates a Latin-script name"
+			}
+		}
+	}
+	return ""
+}
+
+// scriptOf returns the Unicode script of a letter, or "" for digits,
+// punctuation and characters shared between scripts.
+func scriptOf(r rune) string {
+	if !unicode.IsLetter(r) {
+		return ""
+	}
+	for name, table := range unicode.Scripts {
+		if name != "Common" && name != "Inherited" && unicode.Is(table, r) {
+			return name
+		}
+	}
+	return ""
+}
+
+func allowedMix(scripts map[string]bool) bool {
+	for _, set := range allowedScriptSets {
+		matched := 0
+		for _, name := range set {
+			if scripts[name] {
+				matched++
+			}
+		}
+		if matched == len(scripts) {
+			return true
+		}
+	}
+	return false
+}
+
+// onlyRunes reports whether every letter of label is in set.
+func onlyRunes(label, set string) bool {
+	for _, r := range label {
+		if unicode.IsLetter(r) && !strings.ContainsRune(set, r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package safety
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// DefaultMaxHops is the number of redirects RedirectChain follows when
+// MaxHops is zero.
+const DefaultMaxHops = 5
+
+// RedirectChain follows the destination's HTTP redirects and runs
+// Checks against every hop, catching destinations that bounce through
+// another shortener or to a blocked domain.  Client should refuse
+// private addresses (see metadata.NewClient); its own redirect policy
+// is replaced so each hop can be inspected.  Unreachable destinations
+// pass.
+type RedirectChain struct {
+	Client  *http.Client
+	MaxHops int
+	Checks  []Check
+}
+
+// Name implements Check.
+func (RedirectChain) Name() string { return "redirect" }
+
+// Check implements Check.
+func (c RedirectChain) Check(ctx context.Context, u *url.URL) (string, error) {
+	client := *c.Client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	maxHops := c.MaxHops
+	if maxHops <= 0 {
+		maxHops = DefaultMaxHops
+	}
+	current := u
+	for hop := 0; hop < maxHops; hop++ {
+		next, ok := nextHop(ctx, &client, current)
+		if !ok {
+			return "", nil
+		}
+		finding, err := runChecks(ctx, c.Checks, next)
+		if err != nil {
+			return "", err
+		}
+		if finding != nil {
+			return "destination redirects to " + next.Hostname() + ": " + finding.Reason, nil
+		}
+		current = next
+	}
+	return "", nil
+}
+
+// nextHop requests u and returns the URL it redirects to.
+func nextHop(ctx context.Context, client *http.Client, u *url.URL) (*url.URL, bool) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return nil, false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	location := resp.Header.Get("Location")
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 || location == "" {
+		return nil, false
+	}
+	next, err := u.Parse(location)
+	if err != nil || (next.Scheme != "http" && next.Scheme != "https") {
+		return nil, false
+	}
+	return next, true
+}
@@ -0,0 +1,145 @@
+// Package safety checks link destinations for phishing and abuse
+// patterns before they are shortened and, optionally, when they are
+// visited.  A Scanner runs a list of pluggable Checks; the first check
+// that objects to a URL blocks it.
+package safety
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// Finding explains why a URL was blocked.  Check is the Name of the
+// check that blocked it.
+type Finding struct {
+	Check  string
+	Reason string
+}
+
+// Check inspects one destination URL.  It returns a non-empty reason
+// when the URL must be blocked.  Errors mean the check could not run;
+// checks that depend on the network should treat unreachable
+// destinations as a pass rather than an error.
+type Check interface {
+	Name() string
+	Check(ctx context.Context, u *url.URL) (reason string, err error)
+}
+
+// Resolver looks up the addresses of a host.  *net.Resolver
+// satisfies it.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// Scanner runs Checks in order.  Hosts on Allow (and their subdomains)
+// skip every check.  A nil Scanner allows everything.
+type Scanner struct {
+	Allow  *DomainList
+	Checks []Check
+}
+
+// Options configures NewScanner.  Nil lists are empty, except
+// Shorteners which defaults to KnownShorteners.  Client and Resolver
+// enable the network checks (following redirect chains and resolving
+// host names); leave them nil for checks that must stay fast, such as
+// those run on every redirect.
+type Options struct {
+	Blocklist  *DomainList
+	Allowlist  *DomainList
+	Shorteners *DomainList
+	Client     *http.Client
+	Resolver   Resolver
+}
+
+// NewScanner returns a Scanner running the block list, shortener,
+// address and homograph checks, followed by the redirect chain check
+// when opts.Client is set.
+func NewScanner(opts Options) *Scanner {
+	shorteners := opts.Shorteners
+	if shorteners == nil {
+		shorteners = NewDomainList(KnownShorteners...)
+	}
+	blocklist := Blocklist{Domains: opts.Blocklist}
+	shortenerCheck := Shorteners{Domains: shorteners}
+	checks := []Check{blocklist, shortenerCheck, Addresses{Resolver: opts.Resolver}, Homographs{}}
+	if opts.Client != nil {
+		// Every hop is held to the checks that need no network access
+		hops := []Check{blocklist, shortenerCheck, Addresses{}, Homographs{}}
+		checks = append(checks, RedirectChain{Client: opts.Client, Checks: hops})
+	}
+	return &Scanner{Allow: opts.Allowlist, Checks: checks}
+}
+
+// Scan runs the checks against rawURL and returns the first Finding,
+// or nil when the URL is allowed.  Destination templates are checked
+// by their literal scheme and host.
+func (s *Scanner) Scan(ctx context.Context, rawURL string) (*Finding, error) {
+	if s == nil {
+		return nil, nil
+	}
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Hostname() == "" {
+		return &Finding{Check: "url", Reason: "destination is not a valid URL"}, nil
+	}
+	if s.Allow.Contains(u.Hostname()) {
+		return nil, nil
+	}
+	return runChecks(ctx, s.Checks, u)
+}
+
+func runChecks(ctx context.Context, checks []Check, u *url.URL) (*Finding, error) {
+	for _, c := range checks {
+		reason, err := c.Check(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			return &Finding{Check: c.Name(), Reason: reason}, nil
+		}
+	}
+	return nil, nil
+}
+
+// Blocklist blocks destinations on a list of known-bad domains.
+type Blocklist struct {
+	Domains *DomainList
+}
+
+// Name implements Check.
+func (Blocklist) Name() string { return "blocklist" }
+
+// Check implements Check.
+func (b Blocklist) Check(ctx context.Context, u *url.URL) (string, error) {
+	if b.Domains.Contains(u.Hostname()) {
+		return "destination domain is blocked", nil
+	}
+	return "", nil
+}
+
+// Shorteners blocks destinations on other URL shorteners, whose own
+// destination could be changed after our checks have passed.
+type Shorteners struct {
+	Domains *DomainList
+}
+
+// Name implements Check.
+func (Shorteners) Name() string { return "shortener" }
+
+// Check implements Check.
+func (s Shorteners) Check(ctx context.Context, u *url.URL) (string, error) {
+	if s.Domains.Contains(u.Hostname()) {
+		return "destination is another URL shortener", nil
+	}
+	return "", nil
+}
+
+// KnownShorteners lists popular public URL shortening services.
+var KnownShorteners = []string{
+	"bit.ly", "bitly.com", "buff.ly", "cutt.ly", "goo.gl", "is.gd",
+	"ow.ly", "rb.gy", "rebrand.ly", "short.io", "shorturl.at", "t.co",
+	"t.ly", "tiny.cc", "tinyurl.com", "v.gd", "shorte.st", "adf.ly",
+	"bl.ink", "lnkd.in", "s.id", "qr.net", "x.co", "soo.gd",
+}
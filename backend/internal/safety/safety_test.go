@@ -0,0 +1,165 @@
+package safety
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/metadata"
+)
+
+type fakeResolver map[string][]netip.Addr
+
+func (f fakeResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	addrs, ok := f[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return addrs, nil
+}
+
+func TestParseDomainList(t *testing.T) {
+	l, err := ParseDomainList(strings.NewReader("# phishing\nevil.com\n*.bad.net # wildcard\n\n.Worse.ORG\nпример.рф\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if l.Len() != 4 {
+		t.Fatalf("expected 4 domains, got %d", l.Len())
+	}
+	for host, want := range map[string]bool{
+		"evil.com":              true,
+		"login.evil.com":        true,
+		"notevil.com":           false,
+		"bad.net":               true,
+		"a.b.bad.net":           true,
+		"worse.org.":            true,
+		"xn--e1afmkfd.xn--p1ai": true,
+		"example.com":           false,
+	} {
+		if got := l.Contains(host); got != want {
+			t.Errorf("Contains(%q) = %v, want %v", host, got, want)
+		}
+	}
+	var empty *DomainList
+	if empty.Contains("evil.com") {
+		t.Error("expected nil list to be empty")
+	}
+}
+
+func TestScannerOfflineChecks(t *testing.T) {
+	s := NewScanner(Options{
+		Blocklist: NewDomainList("evil.com"),
+		Allowlist: NewDomainList("intranet.example.com"),
+	})
+	for raw, want := range map[string]string{
+		"https://example.com/page":      "",
+		"https://login.evil.com/":       "blocklist",
+		"https://bit.ly/abc":            "shortener",
+		"http://10.0.0.1/admin":         "address",
+		"http://[::1]:8080/":            "address",
+		"http://8.8.8.8/":               "address",
+		"http://localhost:3000/":        "address",
+		"http://printer.local/":         "address",
+		"http://intranet.example.com/":  "",
+		"https://xn--80ak6aa92e.com/":   "homograph",
+		"https://xn--pypal-4ve.com/":    "homograph",
+		"https://münchen.de/":           "",
+		"https://xn--r8jz45g.jp/":       "",
+		"https://example.com/{query.q}": "",
+		"not a url":                     "url",
+	} {
+		f, err := s.Scan(context.Background(), raw)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", raw, err)
+		}
+		got := ""
+		if f != nil {
+			got = f.Check
+		}
+		if got != want {
+			t.Errorf("%s: expected check %q, got %q (%+v)", raw, want, got, f)
+		}
+	}
+	var nilScanner *Scanner
+	if f, _ := nilScanner.Scan(context.Background(), "https://bit.ly/x"); f != nil {
+		t.Error("expected nil scanner to allow everything")
+	}
+}
+
+func TestAddressesResolver(t *testing.T) {
+	check := Addresses{Resolver: fakeResolver{
+		"internal.example.com": {netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("192.168.1.10")},
+		"public.example.com":   {netip.MustParseAddr("93.184.216.34")},
+	}}
+	for host, blocked := range map[string]bool{
+		"internal.example.com": true,
+		"public.example.com":   false,
+		"missing.example.com":  false,
+	} {
+		reason, err := check.Check(context.Background(), &url.URL{Scheme: "https", Host: host})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if (reason != "") != blocked {
+			t.Errorf("%s: expected blocked=%v, got %q", host, blocked, reason)
+		}
+	}
+}
+
+func TestHomographReason(t *testing.T) {
+	for label, suspicious := range map[string]bool{
+		"аррӏе":    true,  // Cyrillic look-alike of "apple"
+		"pаypal":   true,  // Latin with a Cyrillic "а"
+		"пример":   false, // ordinary Cyrillic word
+		"münchen":  false,
+		"日本語":      false,
+		"東京abc":    false,
+		"ελληνικά": false,
+	} {
+		if got := homographReason(label) != ""; got != suspicious {
+			t.Errorf("homographReason(%q) suspicious = %v, want %v", label, got, suspicious)
+		}
+	}
+}
+
+func TestRedirectChain(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/to-shortener":
+			http.Redirect(w, r, "/hop", http.StatusFound)
+		case "/hop":
+			http.Redirect(w, r, "https://bit.ly/abc", http.StatusMovedPermanently)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	client := metadata.NewClient(metadata.Options{Timeout: 5 * time.Second, AllowPrivateNetworks: true})
+	check := RedirectChain{Client: client, Checks: []Check{Shorteners{Domains: NewDomainList(KnownShorteners...)}}}
+	for path, blocked := range map[string]bool{
+		"/to-shortener": true,
+		"/loop":         false,
+		"/ok":           false,
+	} {
+		u, _ := url.Parse(srv.URL + path)
+		reason, err := check.Check(context.Background(), u)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if (reason != "") != blocked {
+			t.Errorf("%s: expected blocked=%v, got %q", path, blocked, reason)
+		}
+		if blocked && !strings.Contains(reason, "bit.ly") {
+			t.Errorf("%s: expected reason to name the hop, got %q", path, reason)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package services
+
+import (
+	"context"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+// ClickService records tracked redirects and aggregates them for the
+// stats API.
+type ClickService interface {
+	Record(ctx context.Context, click models.Click) error
+	Stats(ctx context.Context, domain, slug string) (*models.ClickStats, error)
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ ClickService = (*MongoClickService)(nil)
+
+type MongoClickService struct {
+	Coll *mongo.Collection // clicks collection
+}
+
+func NewMongoClickService(coll *mongo.Collection) *MongoClickService {
+	return &MongoClickService{Coll: coll}
+}
+
+func (s *MongoClickService) Record(ctx context.Context, click models.Click) error {
+	_, err := s.Coll.InsertOne(ctx, click)
+	return err
+}
+
+// Stats counts the clicks of a link, grouped by the variant served and
+// by source.  Clicks recorded without a variant or source are only part
+// of the total.
+func (s *MongoClickService) Stats(ctx context.Context, domain, slug string) (*models.ClickStats, error) {
+	groupBy := func(field string) bson.A {
+		return bson.A{
+			bson.M{"$group": bson.M{"_id": "$" + field, "clicks": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.M{"_id": 1}},
+		}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: slugFilter(domain, slug)}},
+		{{Key: "$facet", Value: bson.M{"variants": groupBy("variant"), "sources": groupBy("source")}}},
+	}
+	cursor, err := s.Coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var facets []struct {
+		Variants []models.VariantStats `bson:"variants"`
+		Sources  []models.SourceStats  `bson:"sources"`
+	}
+	if err := cursor.All(ctx, &facets); err != nil {
+		return nil, err
+	}
+	if len(facets) == 0 {
+		return &models.ClickStats{}, nil
+	}
+	stats := summarizeClicks(facets[0].Variants)
+	for _, g := range facets[0].Sources {
+		if g.Source != "" {
+			stats.Sources = append(stats.Sources, g)
+		}
+	}
+	return stats, nil
+}
+
+// summarizeClicks folds the per-variant groups into ClickStats.
+func summarizeClicks(groups []models.VariantStats) *models.ClickStats {
+	stats := &models.ClickStats{}
+	for _, g := range groups {
+		stats.Total += g.Clicks
+		if g.Variant != "" {
+			stats.Variants = append(stats.Variants, g)
+		}
+	}
+	return stats
+}
@@ -0,0 +1,21 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+func TestSummarizeClicks(t *testing.T) {
+	stats := summarizeClicks([]models.VariantStats{
+		{Variant: "", Clicks: 4},
+		{Variant: "A", Clicks: 10},
+		{Variant: "B", Clicks: 6},
+	})
+	if stats.Total != 20 {
+		t.Errorf("expected total 20, got %d", stats.Total)
+	}
+	if len(stats.Variants) != 2 || stats.Variants[0].Variant != "A" || stats.Variants[1].Clicks != 6 {
+		t.Errorf("unexpected variants: %+v", stats.Variants)
+	}
+}
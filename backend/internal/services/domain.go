@@ -0,0 +1,24 @@
+package services
+
+import (
+	"context"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+// DomainService defines the interface for managing custom short
+// domains and verifying their ownership.
+type DomainService interface {
+	Create(ctx context.Context, domain models.Domain) (models.Domain, error)
+	GetByHost(ctx context.Context, host string) (*models.Domain, error)
+	ListByOwner(ctx context.Context, username string, teams []string) ([]models.Domain, error)
+	Verify(ctx context.Context, host string) (*models.Domain, error)
+	UpdateFallbacks(ctx context.Context, host, expiredURL, notFoundURL string) error
+	UpdateApps(ctx context.Context, host string, apps *models.AppAssociation) error
+}
+
+// TXTResolver looks up DNS TXT records.  *net.Resolver satisfies it;
+// tests substitute a stub so verification does not touch the network.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
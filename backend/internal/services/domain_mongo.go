@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// VerificationRecordPrefix is the label prepended to a custom domain
+// to form the name of its ownership TXT record, e.g.
+// "_symph-verify.go.brand.com".
+const VerificationRecordPrefix = "_symph-verify"
+
+// verificationValuePrefix precedes the token inside the TXT record.
+const verificationValuePrefix = "symph-verify="
+
+var (
+	ErrDomainExists       = errors.New("domain already exists")
+	ErrDomainNotFound     = errors.New("domain not found")
+	ErrDomainVerification = errors.New("verification record not found")
+)
+
+var _ DomainService = (*MongoDomainService)(nil)
+
+type MongoDomainService struct {
+	Coll     *mongo.Collection // domains collection
+	Resolver TXTResolver
+}
+
+func NewMongoDomainService(coll *mongo.Collection, resolver TXTResolver) *MongoDomainService {
+	return &MongoDomainService{Coll: coll, Resolver: resolver}
+}
+
+// VerificationRecord returns the TXT record name and value a domain
+// owner must publish to prove control of host.
+func VerificationRecord(host, token string) (name, value string) {
+	return VerificationRecordPrefix + "." + host, verificationValuePrefix + token
+}
+
+func (s *MongoDomainService) Create(ctx context.Context, domain models.Domain) (models.Domain, error) {
+	err := s.Coll.FindOne(ctx, bson.M{"host": domain.Host}).Err()
+	if err == nil {
+		return domain, ErrDomainExists
+	}
+	if err != mongo.ErrNoDocuments {
+		return domain, err
+	}
+	res, err := s.Coll.InsertOne(ctx, domain)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain, ErrDomainExists
+		}
+		return domain, err
+	}
+	if id, ok := res.InsertedID.(primitive.ObjectID); ok {
+		domain.ID = id
+	}
+	return domain, nil
+}
+
+func (s *MongoDomainService) GetByHost(ctx context.Context, host string) (*models.Domain, error) {
+	var domain models.Domain
+	err := s.Coll.FindOne(ctx, bson.M{"host": host}).Decode(&domain)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &domain, nil
+}
+
+// ListByOwner returns domains owned by username or shared with any of
+// the given teams.
+func (s *MongoDomainService) ListByOwner(ctx context.Context, username string, teams []string) ([]models.Domain, error) {
+	filter := bson.M{"owner": username}
+	if len(teams) > 0 {
+		filter = bson.M{"$or": []bson.M{
+			{"owner": username},
+			{"team": bson.M{"$in": teams}},
+		}}
+	}
+	cursor, err := s.Coll.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var results []models.Domain
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Verify looks up the domain's TXT record and marks it verified when
+// the expected token is present.
+func (s *MongoDomainService) Verify(ctx context.Context, host string) (*models.Domain, error) {
+	domain, err := s.GetByHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if domain == nil {
+		return nil, ErrDomainNotFound
+	}
+	if domain.Verified {
+		return domain, nil
+	}
+	ok, err := checkVerificationRecord(ctx, s.Resolver, domain.Host, domain.VerificationToken)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrDomainVerification
+	}
+	now := time.Now().UTC()
+	_, err = s.Coll.UpdateOne(ctx, bson.M{"host": host}, bson.M{"$set": bson.M{"verified": true, "verifiedAt": now}})
+	if err != nil {
+		return nil, err
+	}
+	domain.Verified = true
+	domain.VerifiedAt = &now
+	return domain, nil
+}
+
+// UpdateFallbacks stores the domain's fallback destinations for
+// expired links and unknown slugs.  Empty values clear a fallback.
+func (s *MongoDomainService) UpdateFallbacks(ctx context.Context, host, expiredURL, notFoundURL string) error {
+	res, err := s.Coll.UpdateOne(ctx, bson.M{"host": host}, bson.M{"$set": bson.M{
+		"expiredRedirectUrl":  expiredURL,
+		"notFoundRedirectUrl": notFoundURL,
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrDomainNotFound
+	}
+	return nil
+}
+
+// UpdateApps stores the mobile apps associated with the domain.  A nil
+// value removes the association.
+func (s *MongoDomainService) UpdateApps(ctx context.Context, host string, apps *models.AppAssociation) error {
+	update := bson.M{"$set": bson.M{"apps": apps}}
+	if apps == nil {
+		update = bson.M{"$unset": bson.M{"apps": ""}}
+	}
+	res, err := s.Coll.UpdateOne(ctx, bson.M{"host": host}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrDomainNotFound
+	}
+	return nil
+}
+
+// checkVerificationRecord reports whether any TXT record published
+// for host carries the expected verification token.  Lookup failures
+// caused by a missing record are treated as "not verified" rather
+// than as errors.
+func checkVerificationRecord(ctx context.Context, resolver TXTResolver, host, token string) (bool, error) {
+	if resolver == nil || token == "" {
+		return false, nil
+	}
+	name, want := VerificationRecord(host, token)
+	records, err := resolver.LookupTXT(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, r := range records {
+		if strings.TrimSpace(r) == want {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+type stubResolver struct {
+	records map[string][]string
+	err     error
+}
+
+func (s *stubResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	recs, ok := s.records[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return recs, nil
+}
+
+func TestVerificationRecord(t *testing.T) {
+	name, value := VerificationRecord("go.brand.com", "tok123")
+	if name != "_symph-verify.go.brand.com" {
+		t.Errorf("unexpected record name %s", name)
+	}
+	if value != "symph-verify=tok123" {
+		t.Errorf("unexpected record value %s", value)
+	}
+}
+
+func TestCheckVerificationRecord(t *testing.T) {
+	ctx := context.Background()
+	resolver := &stubResolver{records: map[string][]string{
+		"_symph-verify.go.brand.com": {"v=spf1 -all", " symph-verify=tok123 "},
+	}}
+	ok, err := checkVerificationRecord(ctx, resolver, "go.brand.com", "tok123")
+	if err != nil || !ok {
+		t.Errorf("expected verified, got %v, err %v", ok, err)
+	}
+	ok, err = checkVerificationRecord(ctx, resolver, "go.brand.com", "other")
+	if err != nil || ok {
+		t.Errorf("expected not verified for wrong token, got %v, err %v", ok, err)
+	}
+	ok, err = checkVerificationRecord(ctx, resolver, "missing.brand.com", "tok123")
+	if err != nil || ok {
+		t.Errorf("expected not verified for missing record, got %v, err %v", ok, err)
+	}
+	failing := &stubResolver{err: errors.New("timeout")}
+	if _, err := checkVerificationRecord(ctx, failing, "go.brand.com", "tok123"); err == nil {
+		t.Errorf("expected lookup error to be returned")
+	}
+}
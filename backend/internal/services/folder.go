@@ -0,0 +1,24 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrFolderExists = errors.New("folder already exists")
+
+// FolderService manages the folders users sort their links into.  Get,
+// Rename and Delete only act on folders owned by owner; Get and Rename
+// return nil, and Delete false, when there is no such folder.  Create
+// and Rename return ErrFolderExists when the owner already has a folder
+// with that name.
+type FolderService interface {
+	Create(ctx context.Context, folder models.Folder) (models.Folder, error)
+	Get(ctx context.Context, owner string, id primitive.ObjectID) (*models.Folder, error)
+	List(ctx context.Context, owner string) ([]models.Folder, error)
+	Rename(ctx context.Context, owner string, id primitive.ObjectID, name string) (*models.Folder, error)
+	Delete(ctx context.Context, owner string, id primitive.ObjectID) (bool, error)
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var _ FolderService = (*MongoFolderService)(nil)
+
+type MongoFolderService struct {
+	Coll *mongo.Collection // folders collection
+}
+
+func NewMongoFolderService(coll *mongo.Collection) *MongoFolderService {
+	return &MongoFolderService{Coll: coll}
+}
+
+func (s *MongoFolderService) Create(ctx context.Context, folder models.Folder) (models.Folder, error) {
+	res, err := s.Coll.InsertOne(ctx, folder)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return folder, ErrFolderExists
+		}
+		return folder, err
+	}
+	if id, ok := res.InsertedID.(primitive.ObjectID); ok {
+		folder.ID = id
+	}
+	return folder, nil
+}
+
+func (s *MongoFolderService) Get(ctx context.Context, owner string, id primitive.ObjectID) (*models.Folder, error) {
+	var folder models.Folder
+	err := s.Coll.FindOne(ctx, bson.M{"_id": id, "owner": owner}).Decode(&folder)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+// List returns the owner's folders sorted by name.
+func (s *MongoFolderService) List(ctx context.Context, owner string) ([]models.Folder, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := s.Coll.Find(ctx, bson.M{"owner": owner}, opts)
+	if err != nil {
+		return nil, err
+	}
+	folders := []models.Folder{}
+	if err := cursor.All(ctx, &folders); err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+func (s *MongoFolderService) Rename(ctx context.Context, owner string, id primitive.ObjectID, name string) (*models.Folder, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var folder models.Folder
+	err := s.Coll.FindOneAndUpdate(ctx, bson.M{"_id": id, "owner": owner}, bson.M{"$set": bson.M{"name": name}}, opts).Decode(&folder)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrFolderExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+func (s *MongoFolderService) Delete(ctx context.Context, owner string, id primitive.ObjectID) (bool, error) {
+	res, err := s.Coll.DeleteOne(ctx, bson.M{"_id": id, "owner": owner})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Sort keys accepted by ListOptions.Sort.  A leading "-" sorts in
+// descending order.
+const (
+	SortCreated = "created"
+	SortClicks  = "clicks"
+	SortExpiry  = "expiry"
+)
+
+// sortFields maps sort keys to the stored fields they order by.
+var sortFields = map[string]string{
+	SortCreated: "createdAt",
+	SortClicks:  "redirectCount",
+	SortExpiry:  "expireAt",
+}
+
+// ListOptions selects and orders the links returned by ListByUser.
+// Search matches words in the slug, destination, title and notes.
+// Links must carry every tag in Tags.  Nil pointer filters are not
+// applied; Domain points at "" to select links on the default host.
+// Date ranges include From and exclude To.  Sort is one of the Sort
+// keys, optionally prefixed with "-"; empty sorts newest first.
+//
+// After continues a listing sorted by creation time from a Cursor
+// instead of skipping to Page.
+type ListOptions struct {
+	Page           int
+	Size           int
+	IncludeExpired bool
+	After          *Cursor
+
+	Search   string
+	Tags     []string
+	Domain   *string
+	FolderID *primitive.ObjectID
+
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	ExpiresFrom *time.Time
+	ExpiresTo   *time.Time
+
+	HasExpiration *bool
+	Tracked       *bool
+	Broken        *bool
+
+	Sort string
+}
+
+// Cursor is the position of a link in a listing sorted by creation
+// time.  CreatedAt has millisecond precision, as stored.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        primitive.ObjectID
+}
+
+// CursorSort reports whether sort orders links by creation time, the
+// only order cursors can continue.
+func CursorSort(sort string) bool {
+	return strings.TrimPrefix(sort, "-") == SortCreated || sort == ""
+}
+
+// ValidSort reports whether sort is a sort key accepted by ListOptions.
+func ValidSort(sort string) bool {
+	_, ok := sortFields[strings.TrimPrefix(sort, "-")]
+	return ok
+}
+
+// listFilter builds the query selecting username's links matching opts
+// at now.
+func listFilter(username string, opts ListOptions, now time.Time) bson.M {
+	filter := bson.M{"createdBy": username}
+	var and []bson.M
+	if !opts.IncludeExpired {
+		and = append(and, bson.M{"$or": []bson.M{
+			{"expireAt": bson.M{"$gt": now}},
+			{"expireAt": bson.M{"$exists": false}},
+			{"expireAt": nil},
+		}})
+	}
+	if opts.Search != "" {
+		filter["$text"] = bson.M{"$search": opts.Search}
+	}
+	if len(opts.Tags) > 0 {
+		filter["tags"] = bson.M{"$all": opts.Tags}
+	}
+	if opts.Domain != nil {
+		filter["domain"] = domainMatch(*opts.Domain)
+	}
+	if opts.FolderID != nil {
+		filter["folderId"] = *opts.FolderID
+	}
+	if r := dateRange(opts.CreatedFrom, opts.CreatedTo); r != nil {
+		filter["createdAt"] = r
+	}
+	if r := dateRange(opts.ExpiresFrom, opts.ExpiresTo); r != nil {
+		and = append(and, bson.M{"expireAt": r})
+	}
+	if opts.HasExpiration != nil {
+		if *opts.HasExpiration {
+			and = append(and, bson.M{"expireAt": bson.M{"$type": "date"}})
+		} else {
+			and = append(and, bson.M{"expireAt": bson.M{"$not": bson.M{"$type": "date"}}})
+		}
+	}
+	if opts.Tracked != nil {
+		if *opts.Tracked {
+			filter["trackClicks"] = true
+		} else {
+			filter["trackClicks"] = bson.M{"$ne": true}
+		}
+	}
+	if opts.Broken != nil {
+		if *opts.Broken {
+			filter["health.broken"] = true
+		} else {
+			filter["health.broken"] = bson.M{"$ne": true}
+		}
+	}
+	if opts.After != nil {
+		op := "$lt"
+		if opts.Sort == SortCreated {
+			op = "$gt"
+		}
+		and = append(and, bson.M{"$or": []bson.M{
+			{"createdAt": bson.M{op: opts.After.CreatedAt}},
+			{"createdAt": opts.After.CreatedAt, "_id": bson.M{op: opts.After.ID}},
+		}})
+	}
+	if len(and) > 0 {
+		filter["$and"] = and
+	}
+	return filter
+}
+
+// dateRange returns a condition matching times in [from, to), or nil
+// when neither bound is set.
+func dateRange(from, to *time.Time) bson.M {
+	if from == nil && to == nil {
+		return nil
+	}
+	r := bson.M{}
+	if from != nil {
+		r["$gte"] = *from
+	}
+	if to != nil {
+		r["$lt"] = *to
+	}
+	return r
+}
+
+// listSort returns the sort order for a ListOptions.Sort value.  Ties
+// are broken by _id so the order is stable across pages.
+func listSort(sort string) bson.D {
+	if sort == "" {
+		sort = "-" + SortCreated
+	}
+	dir := 1
+	if strings.HasPrefix(sort, "-") {
+		dir = -1
+	}
+	field, ok := sortFields[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		field = sortFields[SortCreated]
+	}
+	return bson.D{{Key: field, Value: dir}, {Key: "_id", Value: dir}}
+}
+
+// domainMatch matches links on domain; links on the default host may
+// have no domain field at all.
+func domainMatch(domain string) interface{} {
+	if domain == "" {
+		return bson.M{"$in": bson.A{"", nil}}
+	}
+	return domain
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestListFilter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	yes, no := true, false
+	empty := ""
+	filter := listFilter("tester", ListOptions{
+		IncludeExpired: true,
+		Search:         "spring sale",
+		Tags:           []string{"promo", "email"},
+		Domain:         &empty,
+		CreatedFrom:    &from,
+		HasExpiration:  &yes,
+		Tracked:        &no,
+		Broken:         &yes,
+	}, now)
+	want := bson.M{
+		"createdBy":     "tester",
+		"$text":         bson.M{"$search": "spring sale"},
+		"tags":          bson.M{"$all": []string{"promo", "email"}},
+		"domain":        bson.M{"$in": bson.A{"", nil}},
+		"createdAt":     bson.M{"$gte": from},
+		"trackClicks":   bson.M{"$ne": true},
+		"health.broken": true,
+		"$and":          []bson.M{{"expireAt": bson.M{"$type": "date"}}},
+	}
+	if !reflect.DeepEqual(filter, want) {
+		t.Errorf("unexpected filter:\n got %v\nwant %v", filter, want)
+	}
+
+	filter = listFilter("tester", ListOptions{}, now)
+	and, ok := filter["$and"].([]bson.M)
+	if !ok || len(and) != 1 || and[0]["$or"] == nil {
+		t.Errorf("expected expired links to be excluded by default, got %v", filter)
+	}
+}
+
+func TestListSort(t *testing.T) {
+	tests := []struct {
+		sort string
+		want bson.D
+	}{
+		{"", bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+		{"clicks", bson.D{{Key: "redirectCount", Value: 1}, {Key: "_id", Value: 1}}},
+		{"-expiry", bson.D{{Key: "expireAt", Value: -1}, {Key: "_id", Value: -1}}},
+	}
+	for _, tt := range tests {
+		if got := listSort(tt.sort); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("listSort(%q) = %v, want %v", tt.sort, got, tt.want)
+		}
+	}
+	if ValidSort("name") || !ValidSort("-clicks") {
+		t.Error("unexpected ValidSort result")
+	}
+}
+
+func TestListFilter_Cursor(t *testing.T) {
+	after := &Cursor{CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), ID: primitive.NewObjectID()}
+	for sort, op := range map[string]string{"": "$lt", "-created": "$lt", "created": "$gt"} {
+		filter := listFilter("tester", ListOptions{IncludeExpired: true, After: after, Sort: sort}, time.Now())
+		want := []bson.M{{"$or": []bson.M{
+			{"createdAt": bson.M{op: after.CreatedAt}},
+			{"createdAt": after.CreatedAt, "_id": bson.M{op: after.ID}},
+		}}}
+		if !reflect.DeepEqual(filter["$and"], want) {
+			t.Errorf("sort %q: unexpected cursor condition %v", sort, filter["$and"])
+		}
+	}
+	if CursorSort("clicks") || !CursorSort("") || !CursorSort("-created") {
+		t.Error("unexpected CursorSort result")
+	}
+}
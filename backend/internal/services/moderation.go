@@ -0,0 +1,24 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ModerationService stores abuse reports and the audit trail of
+// moderation actions.  CloseReports closes every open report of a link
+// and returns how many were closed; CloseReport closes a single open
+// report and returns nil when no open report has that ID.  An empty
+// status lists reports of every status, and an empty slug lists the
+// audit trail of every link.
+type ModerationService interface {
+	CreateReport(ctx context.Context, report models.Report) (models.Report, error)
+	ListReports(ctx context.Context, status string, page, size int) ([]models.Report, error)
+	CloseReports(ctx context.Context, domain, slug, status, by string, at time.Time) (int64, error)
+	CloseReport(ctx context.Context, id primitive.ObjectID, status, by string, at time.Time) (*models.Report, error)
+	RecordAudit(ctx context.Context, entry models.AuditEntry) error
+	ListAudit(ctx context.Context, domain, slug string, page, size int) ([]models.AuditEntry, error)
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var _ ModerationService = (*MongoModerationService)(nil)
+
+type MongoModerationService struct {
+	Reports *mongo.Collection // reports collection
+	Audit   *mongo.Collection // audit collection
+}
+
+func NewMongoModerationService(reports, audit *mongo.Collection) *MongoModerationService {
+	return &MongoModerationService{Reports: reports, Audit: audit}
+}
+
+func (s *MongoModerationService) CreateReport(ctx context.Context, report models.Report) (models.Report, error) {
+	res, err := s.Reports.InsertOne(ctx, report)
+	if err != nil {
+		return report, err
+	}
+	if id, ok := res.InsertedID.(primitive.ObjectID); ok {
+		report.ID = id
+	}
+	return report, nil
+}
+
+// ListReports returns reports oldest first, so the moderation queue is
+// worked in the order reports arrived.
+func (s *MongoModerationService) ListReports(ctx context.Context, status string, page, size int) ([]models.Report, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetSkip(int64((page - 1) * size)).
+		SetLimit(int64(size))
+	cursor, err := s.Reports.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	reports := []models.Report{}
+	if err := cursor.All(ctx, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+func (s *MongoModerationService) CloseReports(ctx context.Context, domain, slug, status, by string, at time.Time) (int64, error) {
+	filter := slugFilter(domain, slug)
+	filter["status"] = models.ReportOpen
+	res, err := s.Reports.UpdateMany(ctx, filter, closeReportUpdate(status, by, at))
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+func (s *MongoModerationService) CloseReport(ctx context.Context, id primitive.ObjectID, status, by string, at time.Time) (*models.Report, error) {
+	filter := bson.M{"_id": id, "status": models.ReportOpen}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var report models.Report
+	err := s.Reports.FindOneAndUpdate(ctx, filter, closeReportUpdate(status, by, at), opts).Decode(&report)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func closeReportUpdate(status, by string, at time.Time) bson.M {
+	return bson.M{"$set": bson.M{"status": status, "resolvedBy": by, "resolvedAt": at}}
+}
+
+func (s *MongoModerationService) RecordAudit(ctx context.Context, entry models.AuditEntry) error {
+	_, err := s.Audit.InsertOne(ctx, entry)
+	return err
+}
+
+// ListAudit returns audit entries newest first.
+func (s *MongoModerationService) ListAudit(ctx context.Context, domain, slug string, page, size int) ([]models.AuditEntry, error) {
+	filter := bson.M{}
+	if slug != "" {
+		filter = slugFilter(domain, slug)
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "at", Value: -1}}).
+		SetSkip(int64((page - 1) * size)).
+		SetLimit(int64(size))
+	cursor, err := s.Audit.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	entries := []models.AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,19 @@
+package services
+
+import "golang.org/x/crypto/bcrypt"
+
+// HashLinkPassword hashes the password protecting a short link with
+// bcrypt, the same way MongoUserService stores user passwords.
+func HashLinkPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckLinkPassword reports whether password matches a hash produced
+// by HashLinkPassword.
+func CheckLinkPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
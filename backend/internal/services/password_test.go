@@ -0,0 +1,19 @@
+package services
+
+import "testing"
+
+func TestLinkPassword(t *testing.T) {
+	hash, err := HashLinkPassword("s3cret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hash == "s3cret" {
+		t.Fatal("expected password to be hashed")
+	}
+	if !CheckLinkPassword(hash, "s3cret") {
+		t.Error("expected matching password to be accepted")
+	}
+	if CheckLinkPassword(hash, "wrong") {
+		t.Error("expected wrong password to be rejected")
+	}
+}
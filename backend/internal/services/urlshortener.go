@@ -2,15 +2,73 @@ package services
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"github.com/richmondwang/symph-url-shortener/internal/urltemplate"
+	"github.com/richmondwang/symph-url-shortener/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// URLShortenerService defines the interface for URL shortening logic
+// ErrClickLimitReached is returned by IncrementRedirectCount when a
+// click-limited link has no redirects left.
+var ErrClickLimitReached = errors.New("click limit reached")
+
+// URLShortenerService defines the interface for URL shortening logic.
+// Slugs are namespaced by domain; an empty domain refers to the
+// default BASE_URL host.  IncrementRedirectCount must enforce a
+// link's MaxClicks atomically and return ErrClickLimitReached once the
+// limit is exhausted.  GetBySlug returns the link with any scheduled
+// destination change already applied to URL; GetStored returns it
+// uncached and as stored, for edits that write it back.  ListByUser returns
+// the user's links selected and ordered by opts, and CountByUser how
+// many there are across all pages.  ListActive calls fn with every
+// link that is live at now, for the destination health checker, and
+// stops at the first error fn returns.  Flag
+// marks a link whose destination failed a safety check and Unflag
+// clears the mark again; ListFlagged, Unflag, SetDisabled and Delete
+// back the moderation API.  ListTags counts the links
+// carrying each of a user's tags, ReplaceTags swaps the from tags on
+// the user's links for to (renaming or merging them), and ClearFolder
+// takes the user's links out of a deleted folder; both return the
+// number of links changed.  FindByURLKey returns the user's links
+// whose URLKey is key, newest first, only those on domain unless it is
+// nil.  UpdateUTMs stores a link's
+// edited UTMs together with the URLs recomposed from them.
 type URLShortenerService interface {
 	Shorten(ctx context.Context, req models.ShortURL) (models.ShortURL, error)
-	GetBySlug(ctx context.Context, slug string) (*models.ShortURL, error)
-	IncrementRedirectCount(ctx context.Context, slug string) error
-	ListByUser(ctx context.Context, username string, page, size int, includeExpired bool) ([]models.ShortURL, error)
-	IsSlugAvailable(ctx context.Context, slug string) (bool, error)
+	GetBySlug(ctx context.Context, domain, slug string) (*models.ShortURL, error)
+	GetStored(ctx context.Context, domain, slug string) (*models.ShortURL, error)
+	IncrementRedirectCount(ctx context.Context, domain, slug string) error
+	ListByUser(ctx context.Context, username string, opts ListOptions) ([]models.ShortURL, error)
+	CountByUser(ctx context.Context, username string, opts ListOptions) (int64, error)
+	IsSlugAvailable(ctx context.Context, domain, slug string) (bool, error)
+	UpdateMetadata(ctx context.Context, domain, slug string, meta models.LinkMetadata) error
+	ListActive(ctx context.Context, now time.Time, fn func(models.ShortURL) error) error
+	UpdateHealth(ctx context.Context, domain, slug string, health models.LinkHealth) error
+	ListBroken(ctx context.Context, username string) ([]models.ShortURL, error)
+	Flag(ctx context.Context, domain, slug string, flag models.SafetyFlag) error
+	Unflag(ctx context.Context, domain, slug string) error
+	ListFlagged(ctx context.Context, page, size int) ([]models.ShortURL, error)
+	SetDisabled(ctx context.Context, domain, slug string, disabled bool) error
+	Delete(ctx context.Context, domain, slug string) error
+	ListTags(ctx context.Context, username string) ([]models.TagCount, error)
+	ReplaceTags(ctx context.Context, username string, from []string, to string) (int64, error)
+	ClearFolder(ctx context.Context, username string, folderID primitive.ObjectID) (int64, error)
+	FindByURLKey(ctx context.Context, username, key string, domain *string) ([]models.ShortURL, error)
+	UpdateUTMs(ctx context.Context, domain, slug string, link models.ShortURL) error
+}
+
+// URLKey returns the ShortURL.URLKey of a destination, or "" when it is
+// a template or cannot be normalised.
+func URLKey(dest string) string {
+	if urltemplate.IsTemplate(dest) {
+		return ""
+	}
+	key, err := utils.NormalizeURL(dest)
+	if err != nil {
+		return ""
+	}
+	return key
 }
@@ -7,28 +7,147 @@ import (
 
 	"github.com/richmondwang/symph-url-shortener/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// CacheShortURL is used for storing short URL data in Redis
+// CacheShortURL is used for storing short URL data in Redis.  It holds
+// only the fields Redirect and the public info page need to answer a
+// request, plus the UTMs and base URL the UTM editor recomposes.
 type CacheShortURL struct {
-	URL         string     `json:"url"`
-	TrackClicks bool       `json:"trackClicks"`
-	ExpireAt    *time.Time `json:"expireAt,omitempty"`
+	URL             string     `json:"url"`
+	TrackClicks     bool       `json:"trackClicks"`
+	ExpireAt        *time.Time `json:"expireAt,omitempty"`
+	ForwardPath     bool       `json:"forwardPath,omitempty"`
+	ForwardQuery    bool       `json:"forwardQuery,omitempty"`
+	QueryPrecedence string     `json:"queryPrecedence,omitempty"`
+	RedirectType    string     `json:"redirectType,omitempty"`
+
+	Pixels       *models.PixelConfig `json:"pixels,omitempty"`
+	PasswordHash string              `json:"passwordHash,omitempty"`
+	MaxClicks    int                 `json:"maxClicks,omitempty"`
+
+	ActivateAt  *time.Time                    `json:"activateAt,omitempty"`
+	HoldingPage bool                          `json:"holdingPage,omitempty"`
+	Schedule    []models.ScheduledDestination `json:"schedule,omitempty"`
+
+	Destinations []models.Destination   `json:"destinations,omitempty"`
+	Rules        []models.TargetingRule `json:"rules,omitempty"`
+
+	DeepLink *models.DeepLinkConfig `json:"deepLink,omitempty"`
+	Preview  *models.PreviewMeta    `json:"preview,omitempty"`
+
+	CreatedBy          string `json:"createdBy,omitempty"`
+	ExpiredRedirectURL string `json:"expiredRedirectUrl,omitempty"`
+
+	Flag     *models.SafetyFlag `json:"flag,omitempty"`
+	Disabled bool               `json:"disabled,omitempty"`
+
+	CreatedAt   time.Time `json:"createdAt"`
+	PrivateInfo bool      `json:"privateInfo,omitempty"`
+	ShowOwner   bool      `json:"showOwner,omitempty"`
+
+	UTMs      map[string]string `json:"utms,omitempty"`
+	BaseURL   string            `json:"baseUrl,omitempty"`
+	UTMPolicy string            `json:"utmPolicy,omitempty"`
+	Params    map[string]string `json:"params,omitempty"`
+}
+
+// newCacheShortURL copies the redirect-relevant fields of a ShortURL.
+func newCacheShortURL(s models.ShortURL) CacheShortURL {
+	return CacheShortURL{
+		URL:             s.URL,
+		TrackClicks:     s.TrackClicks,
+		ExpireAt:        s.ExpireAt,
+		ForwardPath:     s.ForwardPath,
+		ForwardQuery:    s.ForwardQuery,
+		QueryPrecedence: s.QueryPrecedence,
+		RedirectType:    s.RedirectType,
+		Pixels:          s.Pixels,
+		PasswordHash:    s.PasswordHash,
+		MaxClicks:       s.MaxClicks,
+		ActivateAt:      s.ActivateAt,
+		HoldingPage:     s.HoldingPage,
+		Schedule:        s.Schedule,
+		Destinations:    s.Destinations,
+		Rules:           s.Rules,
+		DeepLink:        s.DeepLink,
+		Preview:         s.Preview,
+
+		CreatedBy:          s.CreatedBy,
+		ExpiredRedirectURL: s.ExpiredRedirectURL,
+
+		Flag:     s.Flag,
+		Disabled: s.Disabled,
+
+		CreatedAt:   s.CreatedAt,
+		PrivateInfo: s.PrivateInfo,
+		ShowOwner:   s.ShowOwner,
+
+		UTMs:      s.UTMs,
+		BaseURL:   s.BaseURL,
+		UTMPolicy: s.UTMPolicy,
+		Params:    s.Params,
+	}
+}
+
+// toShortURL rebuilds a ShortURL from a cached entry.
+func (c CacheShortURL) toShortURL(domain, slug string) *models.ShortURL {
+	return &models.ShortURL{
+		Domain:          domain,
+		Slug:            slug,
+		URL:             c.URL,
+		TrackClicks:     c.TrackClicks,
+		ExpireAt:        c.ExpireAt,
+		ForwardPath:     c.ForwardPath,
+		ForwardQuery:    c.ForwardQuery,
+		QueryPrecedence: c.QueryPrecedence,
+		RedirectType:    c.RedirectType,
+		Pixels:          c.Pixels,
+		PasswordHash:    c.PasswordHash,
+		MaxClicks:       c.MaxClicks,
+		ActivateAt:      c.ActivateAt,
+		HoldingPage:     c.HoldingPage,
+		Schedule:        c.Schedule,
+		Destinations:    c.Destinations,
+		Rules:           c.Rules,
+		DeepLink:        c.DeepLink,
+		Preview:         c.Preview,
+
+		CreatedBy:          c.CreatedBy,
+		ExpiredRedirectURL: c.ExpiredRedirectURL,
+
+		Flag:     c.Flag,
+		Disabled: c.Disabled,
+
+		CreatedAt:   c.CreatedAt,
+		PrivateInfo: c.PrivateInfo,
+		ShowOwner:   c.ShowOwner,
+
+		UTMs:      c.UTMs,
+		BaseURL:   c.BaseURL,
+		UTMPolicy: c.UTMPolicy,
+		Params:    c.Params,
+	}
 }
 
 var _ URLShortenerService = (*MongoURLShortenerService)(nil)
 
+// MongoURLShortenerService stores links in MongoDB with an optional
+// Redis cache.  Now supplies the current time; it defaults to
+// time.Now and may be replaced in tests.
 type MongoURLShortenerService struct {
 	Coll  *mongo.Collection
 	Redis RedisCache
+	Now   func() time.Time
 }
 
 // RedisCache interface for cache operations
 type RedisCache interface {
 	Set(ctx context.Context, key string, value string, ttl time.Duration) error
 	Get(ctx context.Context, key string) (string, error)
+	Del(ctx context.Context, key string) error
 }
 
 func NewMongoURLShortenerService(coll *mongo.Collection) *MongoURLShortenerService {
@@ -39,12 +158,43 @@ func NewMongoURLShortenerServiceWithCache(coll *mongo.Collection, redis RedisCac
 	return &MongoURLShortenerService{Coll: coll, Redis: redis}
 }
 
+// now returns the current UTC time from the injected clock.
+func (s *MongoURLShortenerService) now() time.Time {
+	if s.Now != nil {
+		return s.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// slugFilter matches a slug within a domain namespace.  Links on the
+// default host are stored without a domain field, which MongoDB
+// matches with a nil comparison.
+func slugFilter(domain, slug string) bson.M {
+	if domain == "" {
+		return bson.M{"slug": slug, "domain": nil}
+	}
+	return bson.M{"slug": slug, "domain": domain}
+}
+
+// cacheKey namespaces cached entries by domain so that identical
+// slugs on different hosts do not collide.
+func cacheKey(domain, slug string) string {
+	if domain == "" {
+		return slug
+	}
+	return domain + "/" + slug
+}
+
 // Helper to set cache for a ShortURL
 func (s *MongoURLShortenerService) cacheShortURL(ctx context.Context, shortURL models.ShortURL) {
+	// Exhausted click-limited links are never cached
+	if shortURL.MaxClicks > 0 && shortURL.RedirectCount >= shortURL.MaxClicks {
+		return
+	}
 	if s.Redis != nil && shortURL.URL != "" {
 		ttl := 24 * time.Hour
 		if shortURL.ExpireAt != nil {
-			diff := shortURL.ExpireAt.Sub(time.Now().UTC())
+			diff := shortURL.ExpireAt.Sub(s.now())
 			if diff > 0 {
 				ttl = diff
 			} else {
@@ -53,13 +203,9 @@ func (s *MongoURLShortenerService) cacheShortURL(ctx context.Context, shortURL m
 		}
 		if ttl > 0 {
 			// Store only relevant fields using ShortURL struct
-			cacheObj := CacheShortURL{
-				URL:         shortURL.URL,
-				TrackClicks: shortURL.TrackClicks,
-				ExpireAt:    shortURL.ExpireAt,
-			}
+			cacheObj := newCacheShortURL(shortURL)
 			cacheBytes, _ := json.Marshal(cacheObj)
-			_ = s.Redis.Set(ctx, shortURL.Slug, string(cacheBytes), ttl)
+			_ = s.Redis.Set(ctx, cacheKey(shortURL.Domain, shortURL.Slug), string(cacheBytes), ttl)
 		}
 	}
 }
@@ -71,45 +217,87 @@ func (s *MongoURLShortenerService) Shorten(ctx context.Context, req models.Short
 	return req, err
 }
 
-func (s *MongoURLShortenerService) GetBySlug(ctx context.Context, slug string) (*models.ShortURL, error) {
+func (s *MongoURLShortenerService) GetBySlug(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
 	// Try cache first
 	if s.Redis != nil {
-		val, err := s.Redis.Get(ctx, slug)
+		val, err := s.Redis.Get(ctx, cacheKey(domain, slug))
 		if err == nil && val != "" {
 			// Parse cached JSON
 			var cacheObj CacheShortURL
 			if err := json.Unmarshal([]byte(val), &cacheObj); err == nil {
-				return &models.ShortURL{Slug: slug, URL: cacheObj.URL, TrackClicks: cacheObj.TrackClicks, ExpireAt: cacheObj.ExpireAt}, nil
+				result := cacheObj.toShortURL(domain, slug)
+				result.URL = result.DestinationAt(s.now())
+				return result, nil
 			}
 		}
 	}
+	result, err := s.GetStored(ctx, domain, slug)
+	if result == nil || err != nil {
+		return nil, err
+	}
+	s.cacheShortURL(ctx, *result)
+	// Apply any scheduled destination change that has taken effect
+	result.URL = result.DestinationAt(s.now())
+	return result, nil
+}
+
+// GetStored reads the link from MongoDB, bypassing the cache, with URL
+// left as stored rather than replaced by the scheduled destination.
+func (s *MongoURLShortenerService) GetStored(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
 	var result models.ShortURL
-	err := s.Coll.FindOne(ctx, bson.M{"slug": slug}).Decode(&result)
+	err := s.Coll.FindOne(ctx, slugFilter(domain, slug)).Decode(&result)
 	if err == mongo.ErrNoDocuments {
 		return nil, nil
 	}
-	s.cacheShortURL(ctx, result)
-	return &result, err
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
 }
 
-func (s *MongoURLShortenerService) IncrementRedirectCount(ctx context.Context, slug string) error {
-	_, err := s.Coll.UpdateOne(ctx, bson.M{"slug": slug}, bson.M{"$inc": bson.M{"redirectCount": 1}})
-	return err
+// IncrementRedirectCount counts a redirect.  For click-limited links
+// the increment is a single conditional update that only matches while
+// redirectCount is below maxClicks, so concurrent redirects can never
+// exceed the limit.  The cached entry is dropped once the limit is
+// reached so later requests consult MongoDB.
+func (s *MongoURLShortenerService) IncrementRedirectCount(ctx context.Context, domain, slug string) error {
+	filter := slugFilter(domain, slug)
+	filter["$or"] = []bson.M{
+		{"maxClicks": bson.M{"$exists": false}},
+		{"maxClicks": bson.M{"$lte": 0}},
+		{"$expr": bson.M{"$lt": bson.A{"$redirectCount", "$maxClicks"}}},
+	}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"redirectCount": 1, "maxClicks": 1})
+	var updated models.ShortURL
+	err := s.Coll.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"redirectCount": 1}}, opts).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		s.uncache(ctx, domain, slug)
+		return ErrClickLimitReached
+	}
+	if err != nil {
+		return err
+	}
+	if updated.MaxClicks > 0 && updated.RedirectCount >= updated.MaxClicks {
+		s.uncache(ctx, domain, slug)
+	}
+	return nil
 }
 
-func (s *MongoURLShortenerService) ListByUser(ctx context.Context, username string, page, size int, includeExpired bool) ([]models.ShortURL, error) {
-	skip := (page - 1) * size
-	opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(size)).SetSort(bson.D{{Key: "createdAt", Value: -1}})
-	filter := bson.M{"createdBy": username}
-	if !includeExpired {
-		now := time.Now().UTC()
-		filter["$or"] = []bson.M{
-			{"expireAt": bson.M{"$gt": now}},
-			{"expireAt": bson.M{"$exists": false}},
-			{"expireAt": nil},
-		}
+// uncache removes a cached entry, if caching is enabled.
+func (s *MongoURLShortenerService) uncache(ctx context.Context, domain, slug string) {
+	if s.Redis != nil {
+		_ = s.Redis.Del(ctx, cacheKey(domain, slug))
 	}
-	cursor, err := s.Coll.Find(ctx, filter, opts)
+}
+
+func (s *MongoURLShortenerService) ListByUser(ctx context.Context, username string, opts ListOptions) ([]models.ShortURL, error) {
+	findOpts := options.Find().SetLimit(int64(opts.Size)).SetSort(listSort(opts.Sort))
+	if opts.After == nil {
+		findOpts.SetSkip(int64((opts.Page - 1) * opts.Size))
+	}
+	cursor, err := s.Coll.Find(ctx, listFilter(username, opts, s.now()), findOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -124,9 +312,15 @@ func (s *MongoURLShortenerService) ListByUser(ctx context.Context, username stri
 	return results, nil
 }
 
-// IsSlugAvailable checks if a slug is not present in the database (available for use)
-func (s *MongoURLShortenerService) IsSlugAvailable(ctx context.Context, slug string) (bool, error) {
-	err := s.Coll.FindOne(ctx, bson.M{"slug": slug}).Err()
+// CountByUser counts the links ListByUser would return over all pages.
+func (s *MongoURLShortenerService) CountByUser(ctx context.Context, username string, opts ListOptions) (int64, error) {
+	opts.After = nil
+	return s.Coll.CountDocuments(ctx, listFilter(username, opts, s.now()))
+}
+
+// IsSlugAvailable checks if a slug is not present in the given domain (available for use)
+func (s *MongoURLShortenerService) IsSlugAvailable(ctx context.Context, domain, slug string) (bool, error) {
+	err := s.Coll.FindOne(ctx, slugFilter(domain, slug)).Err()
 	if err == mongo.ErrNoDocuments {
 		return true, nil
 	}
@@ -135,3 +329,255 @@ func (s *MongoURLShortenerService) IsSlugAvailable(ctx context.Context, slug str
 	}
 	return false, nil
 }
+
+// UpdateMetadata stores the fetched destination metadata of a link.
+// Metadata is not needed to redirect, so the cache is left alone.
+func (s *MongoURLShortenerService) UpdateMetadata(ctx context.Context, domain, slug string, meta models.LinkMetadata) error {
+	_, err := s.Coll.UpdateOne(ctx, slugFilter(domain, slug), bson.M{"$set": bson.M{"metadata": meta}})
+	return err
+}
+
+// ListActive streams the links that are live at now to fn: activated,
+// neither disabled nor flagged, and not expired unless they have an
+// expired fallback.  Only the fields the health checker needs are
+// loaded.
+func (s *MongoURLShortenerService) ListActive(ctx context.Context, now time.Time, fn func(models.ShortURL) error) error {
+	filter := bson.M{
+		"disabled": bson.M{"$ne": true},
+		"flag":     bson.M{"$exists": false},
+		"$and": []bson.M{
+			{"$or": []bson.M{
+				{"expireAt": bson.M{"$gt": now}},
+				{"expireAt": nil},
+				{"expiredRedirectUrl": bson.M{"$nin": bson.A{nil, ""}}},
+			}},
+			{"$or": []bson.M{{"activateAt": bson.M{"$lte": now}}, {"activateAt": nil}}},
+		},
+	}
+	opts := options.Find().SetProjection(bson.M{
+		"domain": 1, "slug": 1, "url": 1, "schedule": 1, "health": 1,
+		"destinations": 1, "rules": 1, "expireAt": 1, "expiredRedirectUrl": 1,
+	})
+	cursor, err := s.Coll.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var link models.ShortURL
+		if err := cursor.Decode(&link); err != nil {
+			return err
+		}
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// UpdateHealth stores the result of a destination health check.
+func (s *MongoURLShortenerService) UpdateHealth(ctx context.Context, domain, slug string, health models.LinkHealth) error {
+	_, err := s.Coll.UpdateOne(ctx, slugFilter(domain, slug), bson.M{"$set": bson.M{"health": health}})
+	return err
+}
+
+// ListBroken returns the user's links whose destination is flagged as
+// broken, most recently checked first.
+func (s *MongoURLShortenerService) ListBroken(ctx context.Context, username string) ([]models.ShortURL, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "health.lastCheckedAt", Value: -1}})
+	cursor, err := s.Coll.Find(ctx, bson.M{"createdBy": username, "health.broken": true}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var results []models.ShortURL
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Flag marks a link as blocked by a safety check.  The cached entry is
+// dropped so the next redirect sees the flag.
+func (s *MongoURLShortenerService) Flag(ctx context.Context, domain, slug string, flag models.SafetyFlag) error {
+	_, err := s.Coll.UpdateOne(ctx, slugFilter(domain, slug), bson.M{"$set": bson.M{"flag": flag}})
+	if err != nil {
+		return err
+	}
+	s.uncache(ctx, domain, slug)
+	return nil
+}
+
+// Unflag clears a safety flag a moderator found to be a false
+// positive.  The cached entry is dropped so redirects resume at once.
+func (s *MongoURLShortenerService) Unflag(ctx context.Context, domain, slug string) error {
+	_, err := s.Coll.UpdateOne(ctx, slugFilter(domain, slug), bson.M{"$unset": bson.M{"flag": ""}})
+	if err != nil {
+		return err
+	}
+	s.uncache(ctx, domain, slug)
+	return nil
+}
+
+// ListFlagged returns a page of the links blocked by a safety check,
+// oldest flag first, for the moderation queue.
+func (s *MongoURLShortenerService) ListFlagged(ctx context.Context, page, size int) ([]models.ShortURL, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "flag.flaggedAt", Value: 1}, {Key: "_id", Value: 1}}).
+		SetSkip(int64((page - 1) * size)).
+		SetLimit(int64(size))
+	cursor, err := s.Coll.Find(ctx, bson.M{"flag": bson.M{"$exists": true}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	results := []models.ShortURL{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// SetDisabled takes a link down, or restores it, on behalf of a
+// moderator.  The cached entry is dropped so the change applies at once.
+func (s *MongoURLShortenerService) SetDisabled(ctx context.Context, domain, slug string, disabled bool) error {
+	update := bson.M{"$set": bson.M{"disabled": true}}
+	if !disabled {
+		update = bson.M{"$unset": bson.M{"disabled": ""}}
+	}
+	if _, err := s.Coll.UpdateOne(ctx, slugFilter(domain, slug), update); err != nil {
+		return err
+	}
+	s.uncache(ctx, domain, slug)
+	return nil
+}
+
+// Delete removes a link and its cached entry.
+func (s *MongoURLShortenerService) Delete(ctx context.Context, domain, slug string) error {
+	if _, err := s.Coll.DeleteOne(ctx, slugFilter(domain, slug)); err != nil {
+		return err
+	}
+	s.uncache(ctx, domain, slug)
+	return nil
+}
+
+// ListTags returns the user's tags, most used first.
+func (s *MongoURLShortenerService) ListTags(ctx context.Context, username string) ([]models.TagCount, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"createdBy": username, "tags.0": bson.M{"$exists": true}}}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{"_id": "$tags", "links": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "links", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+	cursor, err := s.Coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	tags := []models.TagCount{}
+	if err := cursor.All(ctx, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// ReplaceTags adds to to the user's links carrying any of the from tags
+// before pulling the from tags, as one update cannot both add to and
+// remove from the same array.
+func (s *MongoURLShortenerService) ReplaceTags(ctx context.Context, username string, from []string, to string) (int64, error) {
+	filter := bson.M{"createdBy": username, "tags": bson.M{"$in": from}}
+	res, err := s.Coll.UpdateMany(ctx, filter, bson.M{"$addToSet": bson.M{"tags": to}})
+	if err != nil {
+		return 0, err
+	}
+	var pull []string
+	for _, tag := range from {
+		if tag != to {
+			pull = append(pull, tag)
+		}
+	}
+	if len(pull) == 0 {
+		return res.MatchedCount, nil
+	}
+	if _, err := s.Coll.UpdateMany(ctx, filter, bson.M{"$pull": bson.M{"tags": bson.M{"$in": pull}}}); err != nil {
+		return 0, err
+	}
+	return res.MatchedCount, nil
+}
+
+// ClearFolder takes the user's links out of the folder folderID and
+// returns how many were modified.
+func (s *MongoURLShortenerService) ClearFolder(ctx context.Context, username string, folderID primitive.ObjectID) (int64, error) {
+	res, err := s.Coll.UpdateMany(ctx, bson.M{"createdBy": username, "folderId": folderID}, bson.M{"$unset": bson.M{"folderId": ""}})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// maxURLKeyMatches caps the links returned by FindByURLKey.
+const maxURLKeyMatches = 100
+
+func (s *MongoURLShortenerService) FindByURLKey(ctx context.Context, username, key string, domain *string) ([]models.ShortURL, error) {
+	filter := bson.M{"createdBy": username, "urlKey": key}
+	if domain != nil {
+		filter["domain"] = domainMatch(*domain)
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(maxURLKeyMatches)
+	cursor, err := s.Coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var results []models.ShortURL
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// BackfillURLKeys sets the URLKey of links created before it existed
+// and returns how many were updated.  Links whose destination has no
+// key get an empty one so they are not revisited.
+func (s *MongoURLShortenerService) BackfillURLKeys(ctx context.Context) (int, error) {
+	opts := options.Find().SetProjection(bson.M{"url": 1})
+	cursor, err := s.Coll.Find(ctx, bson.M{"urlKey": bson.M{"$exists": false}}, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+	n := 0
+	for cursor.Next(ctx) {
+		var link struct {
+			ID  primitive.ObjectID `bson:"_id"`
+			URL string             `bson:"url"`
+		}
+		if err := cursor.Decode(&link); err != nil {
+			return n, err
+		}
+		update := bson.M{"$set": bson.M{"urlKey": URLKey(link.URL)}}
+		if _, err := s.Coll.UpdateByID(ctx, link.ID, update); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, cursor.Err()
+}
+
+// UpdateUTMs stores the UTMs and custom parameters of link and every
+// destination composed from them.
+func (s *MongoURLShortenerService) UpdateUTMs(ctx context.Context, domain, slug string, link models.ShortURL) error {
+	update := bson.M{"$set": bson.M{
+		"url":          link.URL,
+		"baseUrl":      link.BaseURL,
+		"utms":         link.UTMs,
+		"utmPolicy":    link.UTMPolicy,
+		"params":       link.Params,
+		"utmPresetId":  link.UTMPresetID,
+		"urlKey":       link.URLKey,
+		"destinations": link.Destinations,
+		"rules":        link.Rules,
+		"schedule":     link.Schedule,
+	}}
+	if _, err := s.Coll.UpdateOne(ctx, slugFilter(domain, slug), update); err != nil {
+		return err
+	}
+	s.uncache(ctx, domain, slug)
+	return nil
+}
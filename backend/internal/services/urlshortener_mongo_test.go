@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+// fakeCache is an in-memory RedisCache used to exercise the caching
+// paths of MongoURLShortenerService without a database.
+type fakeCache struct {
+	data map[string]string
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{data: make(map[string]string)}
+}
+
+func (c *fakeCache) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	c.data[key] = value
+	return nil
+}
+func (c *fakeCache) Get(ctx context.Context, key string) (string, error) {
+	v, ok := c.data[key]
+	if !ok {
+		return "", errors.New("miss")
+	}
+	return v, nil
+}
+func (c *fakeCache) Del(ctx context.Context, key string) error {
+	delete(c.data, key)
+	return nil
+}
+
+func TestCacheRoundTrip(t *testing.T) {
+	cache := newFakeCache()
+	s := NewMongoURLShortenerServiceWithCache(nil, cache)
+	ctx := context.Background()
+	s.cacheShortURL(ctx, models.ShortURL{Domain: "go.brand.com", Slug: "abc12345", URL: "https://x.com", MaxClicks: 2, ForwardPath: true})
+	if _, ok := cache.data["go.brand.com/abc12345"]; !ok {
+		t.Fatalf("expected entry cached under domain key, got %v", cache.data)
+	}
+	out, err := s.GetBySlug(ctx, "go.brand.com", "abc12345")
+	if err != nil || out == nil {
+		t.Fatalf("expected cached link, got %v, err %v", out, err)
+	}
+	if out.URL != "https://x.com" || out.MaxClicks != 2 || !out.ForwardPath {
+		t.Errorf("unexpected cached link %+v", out)
+	}
+}
+
+func TestCacheSkipsExhaustedLinks(t *testing.T) {
+	cache := newFakeCache()
+	s := NewMongoURLShortenerServiceWithCache(nil, cache)
+	s.cacheShortURL(context.Background(), models.ShortURL{Slug: "once1234", URL: "https://x.com", MaxClicks: 1, RedirectCount: 1})
+	if len(cache.data) != 0 {
+		t.Errorf("expected exhausted link not to be cached, got %v", cache.data)
+	}
+}
+
+func TestGetBySlugAppliesSchedule(t *testing.T) {
+	cache := newFakeCache()
+	s := NewMongoURLShortenerServiceWithCache(nil, cache)
+	launch := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	now := launch.Add(-time.Hour)
+	s.Now = func() time.Time { return now }
+	ctx := context.Background()
+	s.cacheShortURL(ctx, models.ShortURL{
+		Slug:     "launch24",
+		URL:      "https://shop.example.com/presale",
+		Schedule: []models.ScheduledDestination{{At: launch, URL: "https://shop.example.com/sale"}},
+	})
+	out, _ := s.GetBySlug(ctx, "", "launch24")
+	if out == nil || out.URL != "https://shop.example.com/presale" {
+		t.Fatalf("expected presale before launch, got %+v", out)
+	}
+	now = launch
+	out, _ = s.GetBySlug(ctx, "", "launch24")
+	if out == nil || out.URL != "https://shop.example.com/sale" {
+		t.Fatalf("expected sale at launch, got %+v", out)
+	}
+}
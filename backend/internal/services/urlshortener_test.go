@@ -14,22 +14,26 @@ func (f *fakeShortener) Shorten(ctx context.Context, req models.ShortURL) (model
 	req.Slug = "slugged"
 	return req, nil
 }
-func (f *fakeShortener) GetBySlug(ctx context.Context, slug string) (*models.ShortURL, error) {
+func (f *fakeShortener) GetBySlug(ctx context.Context, domain, slug string) (*models.ShortURL, error) {
 	if slug == "notfound" {
 		return nil, nil
 	}
 	return &models.ShortURL{Slug: slug, URL: "https://x.com"}, nil
 }
-func (f *fakeShortener) IncrementRedirectCount(ctx context.Context, slug string) error {
+func (f *fakeShortener) IncrementRedirectCount(ctx context.Context, domain, slug string) error {
 	if slug == "fail" {
 		return errors.New("fail")
 	}
 	return nil
 }
-func (f *fakeShortener) ListByUser(ctx context.Context, username string, page, size int, includeExpired bool) ([]models.ShortURL, error) {
+func (f *fakeShortener) ListByUser(ctx context.Context, username string, opts ListOptions) ([]models.ShortURL, error) {
 	return []models.ShortURL{{Slug: "slugged", URL: "https://x.com"}}, nil
 }
 
+func (f *fakeShortener) CountByUser(ctx context.Context, username string, opts ListOptions) (int64, error) {
+	return 1, nil
+}
+
 func TestShorten(t *testing.T) {
 	s := &fakeShortener{}
 	ctx := context.Background()
@@ -46,11 +50,11 @@ func TestShorten(t *testing.T) {
 func TestGetBySlug(t *testing.T) {
 	s := &fakeShortener{}
 	ctx := context.Background()
-	out, err := s.GetBySlug(ctx, "slugged")
+	out, err := s.GetBySlug(ctx, "", "slugged")
 	if err != nil || out == nil || out.Slug != "slugged" {
 		t.Errorf("expected slugged, got %v, err %v", out, err)
 	}
-	out, err = s.GetBySlug(ctx, "notfound")
+	out, err = s.GetBySlug(ctx, "", "notfound")
 	if out != nil {
 		t.Errorf("expected nil for notfound")
 	}
@@ -59,10 +63,10 @@ func TestGetBySlug(t *testing.T) {
 func TestIncrementRedirectCount(t *testing.T) {
 	s := &fakeShortener{}
 	ctx := context.Background()
-	if err := s.IncrementRedirectCount(ctx, "fail"); err == nil {
+	if err := s.IncrementRedirectCount(ctx, "", "fail"); err == nil {
 		t.Errorf("expected error for fail")
 	}
-	if err := s.IncrementRedirectCount(ctx, "ok"); err != nil {
+	if err := s.IncrementRedirectCount(ctx, "", "ok"); err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 }
@@ -70,7 +74,7 @@ func TestIncrementRedirectCount(t *testing.T) {
 func TestListByUser(t *testing.T) {
 	s := &fakeShortener{}
 	ctx := context.Background()
-	out, err := s.ListByUser(ctx, "tester", 1, 10, false)
+	out, err := s.ListByUser(ctx, "tester", ListOptions{Page: 1, Size: 10})
 	if err != nil || len(out) == 0 {
 		t.Errorf("expected at least one result, got %v, err %v", out, err)
 	}
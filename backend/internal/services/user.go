@@ -11,4 +11,5 @@ type UserService interface {
 	Register(ctx context.Context, username, password string) error
 	Login(ctx context.Context, username, password string) (*models.User, error)
 	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	UpdateFallbacks(ctx context.Context, username, expiredURL, notFoundURL string) error
 }
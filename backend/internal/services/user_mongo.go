@@ -63,3 +63,19 @@ func (s *MongoUserService) GetByUsername(ctx context.Context, username string) (
 	}
 	return &user, nil
 }
+
+// UpdateFallbacks stores the user's default fallback destinations for
+// expired links and unknown slugs.  Empty values clear a fallback.
+func (s *MongoUserService) UpdateFallbacks(ctx context.Context, username, expiredURL, notFoundURL string) error {
+	res, err := s.Coll.UpdateOne(ctx, bson.M{"username": username}, bson.M{"$set": bson.M{
+		"expiredRedirectUrl":  expiredURL,
+		"notFoundRedirectUrl": notFoundURL,
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
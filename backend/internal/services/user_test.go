@@ -28,6 +28,12 @@ func (f *fakeUserService) GetByUsername(ctx context.Context, username string) (*
 	}
 	return &models.User{Username: username}, nil
 }
+func (f *fakeUserService) UpdateFallbacks(ctx context.Context, username, expiredURL, notFoundURL string) error {
+	if username == "notfound" {
+		return errors.New("not found")
+	}
+	return nil
+}
 
 func TestRegister(t *testing.T) {
 	s := &fakeUserService{}
@@ -65,3 +71,14 @@ func TestGetByUsername(t *testing.T) {
 		t.Errorf("expected tester, got %v, err %v", u, err)
 	}
 }
+
+func TestUpdateFallbacks(t *testing.T) {
+	s := &fakeUserService{}
+	ctx := context.Background()
+	if err := s.UpdateFallbacks(ctx, "notfound", "", ""); err == nil {
+		t.Errorf("expected error for notfound")
+	}
+	if err := s.UpdateFallbacks(ctx, "tester", "https://x.com/expired", ""); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+package services
+
+import (
+	"context"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UTMService stores UTM presets and vocabularies.  GetPreset returns nil
+// when there is no such preset; callers check access themselves.
+// ListPresets returns the presets owned by owner or shared with one of
+// teams, and DeletePreset only deletes presets owned by owner, returning
+// false when there is none.  Vocabularies returns owner's personal
+// vocabulary and those of teams, and SetVocabulary replaces the
+// vocabulary of the owner or team it names.
+type UTMService interface {
+	CreatePreset(ctx context.Context, preset models.UTMPreset) (models.UTMPreset, error)
+	GetPreset(ctx context.Context, id primitive.ObjectID) (*models.UTMPreset, error)
+	ListPresets(ctx context.Context, owner string, teams []string) ([]models.UTMPreset, error)
+	DeletePreset(ctx context.Context, owner string, id primitive.ObjectID) (bool, error)
+	Vocabularies(ctx context.Context, owner string, teams []string) ([]models.UTMVocabulary, error)
+	SetVocabulary(ctx context.Context, vocab models.UTMVocabulary) error
+}
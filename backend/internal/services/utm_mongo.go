@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var _ UTMService = (*MongoUTMService)(nil)
+
+type MongoUTMService struct {
+	Presets *mongo.Collection // utmPresets collection
+	Vocabs  *mongo.Collection // utmVocabularies collection
+}
+
+func NewMongoUTMService(presets, vocabularies *mongo.Collection) *MongoUTMService {
+	return &MongoUTMService{Presets: presets, Vocabs: vocabularies}
+}
+
+func (s *MongoUTMService) CreatePreset(ctx context.Context, preset models.UTMPreset) (models.UTMPreset, error) {
+	res, err := s.Presets.InsertOne(ctx, preset)
+	if err != nil {
+		return preset, err
+	}
+	if id, ok := res.InsertedID.(primitive.ObjectID); ok {
+		preset.ID = id
+	}
+	return preset, nil
+}
+
+func (s *MongoUTMService) GetPreset(ctx context.Context, id primitive.ObjectID) (*models.UTMPreset, error) {
+	var preset models.UTMPreset
+	err := s.Presets.FindOne(ctx, bson.M{"_id": id}).Decode(&preset)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &preset, nil
+}
+
+// ListPresets returns the presets available to owner sorted by name.
+func (s *MongoUTMService) ListPresets(ctx context.Context, owner string, teams []string) ([]models.UTMPreset, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := s.Presets.Find(ctx, scopeFilter(owner, teams), opts)
+	if err != nil {
+		return nil, err
+	}
+	presets := []models.UTMPreset{}
+	if err := cursor.All(ctx, &presets); err != nil {
+		return nil, err
+	}
+	return presets, nil
+}
+
+func (s *MongoUTMService) DeletePreset(ctx context.Context, owner string, id primitive.ObjectID) (bool, error) {
+	res, err := s.Presets.DeleteOne(ctx, bson.M{"_id": id, "owner": owner})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
+
+func (s *MongoUTMService) Vocabularies(ctx context.Context, owner string, teams []string) ([]models.UTMVocabulary, error) {
+	filter := bson.M{"owner": owner, "team": ""}
+	if len(teams) > 0 {
+		filter = bson.M{"$or": []bson.M{
+			filter,
+			{"owner": "", "team": bson.M{"$in": teams}},
+		}}
+	}
+	cursor, err := s.Vocabs.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	vocabs := []models.UTMVocabulary{}
+	if err := cursor.All(ctx, &vocabs); err != nil {
+		return nil, err
+	}
+	return vocabs, nil
+}
+
+func (s *MongoUTMService) SetVocabulary(ctx context.Context, vocab models.UTMVocabulary) error {
+	filter := bson.M{"owner": vocab.Owner, "team": vocab.Team}
+	opts := options.Replace().SetUpsert(true)
+	_, err := s.Vocabs.ReplaceOne(ctx, filter, vocab, opts)
+	return err
+}
+
+// scopeFilter matches documents owned by owner or shared with one of
+// teams.
+func scopeFilter(owner string, teams []string) bson.M {
+	if len(teams) == 0 {
+		return bson.M{"owner": owner}
+	}
+	return bson.M{"$or": []bson.M{
+		{"owner": owner},
+		{"team": bson.M{"$in": teams}},
+	}}
+}
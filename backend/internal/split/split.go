@@ -0,0 +1,54 @@
+// Package split assigns visitors to the weighted destinations of an
+// A/B split link.  Assignment is deterministic for a given visitor key
+// so that a visitor keeps seeing the same variant.
+package split
+
+import (
+	"hash/fnv"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+// Pick returns the index of the destination assigned to key.  The key
+// is hashed onto the cumulative weights, so each destination receives
+// a share of visitors proportional to its Weight.  Destinations with a
+// non-positive weight are never picked.  Pick returns -1 when no
+// destination has a positive weight.
+func Pick(dests []models.Destination, key string) int {
+	total := 0
+	for _, d := range dests {
+		if d.Weight > 0 {
+			total += d.Weight
+		}
+	}
+	if total == 0 {
+		return -1
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	point := int(h.Sum64() % uint64(total))
+	for i, d := range dests {
+		if d.Weight <= 0 {
+			continue
+		}
+		if point < d.Weight {
+			return i
+		}
+		point -= d.Weight
+	}
+	return -1
+}
+
+// Find returns the index of the destination labelled label, or -1.
+// It is used to honour a variant remembered in a visitor's cookie.
+func Find(dests []models.Destination, label string) int {
+	if label == "" {
+		return -1
+	}
+	for i, d := range dests {
+		if d.Label == label && d.Weight > 0 {
+			return i
+		}
+	}
+	return -1
+}
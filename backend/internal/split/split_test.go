@@ -0,0 +1,51 @@
+package split
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+func TestPickIsDeterministic(t *testing.T) {
+	dests := []models.Destination{{Label: "A", Weight: 1}, {Label: "B", Weight: 1}}
+	first := Pick(dests, "203.0.113.7|Mozilla/5.0")
+	for i := 0; i < 10; i++ {
+		if got := Pick(dests, "203.0.113.7|Mozilla/5.0"); got != first {
+			t.Fatalf("expected sticky assignment %d, got %d", first, got)
+		}
+	}
+}
+
+func TestPickHonoursWeights(t *testing.T) {
+	dests := []models.Destination{{Label: "A", Weight: 80}, {Label: "B", Weight: 20}, {Label: "off", Weight: 0}}
+	counts := make([]int, len(dests))
+	for i := 0; i < 10000; i++ {
+		counts[Pick(dests, fmt.Sprintf("visitor-%d", i))]++
+	}
+	if counts[2] != 0 {
+		t.Errorf("expected zero-weight destination never to be picked, got %d", counts[2])
+	}
+	if counts[0] < 7500 || counts[0] > 8500 {
+		t.Errorf("expected roughly 80%% for A, got %d/10000", counts[0])
+	}
+}
+
+func TestPickWithoutWeights(t *testing.T) {
+	if got := Pick([]models.Destination{{Label: "A"}}, "x"); got != -1 {
+		t.Errorf("expected -1 without positive weights, got %d", got)
+	}
+}
+
+func TestFind(t *testing.T) {
+	dests := []models.Destination{{Label: "A", Weight: 1}, {Label: "B", Weight: 1}, {Label: "C", Weight: 0}}
+	if Find(dests, "B") != 1 {
+		t.Error("expected to find B")
+	}
+	if Find(dests, "C") != -1 {
+		t.Error("expected disabled variant not to be found")
+	}
+	if Find(dests, "") != -1 || Find(dests, "Z") != -1 {
+		t.Error("expected unknown labels not to be found")
+	}
+}
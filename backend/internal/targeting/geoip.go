@@ -0,0 +1,109 @@
+package targeting
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// GeoIP maps client addresses to ISO country codes using a table of
+// non-overlapping networks loaded from a local file, so no lookup
+// leaves the server.
+type GeoIP struct {
+	ranges []geoRange // sorted by start
+}
+
+type geoRange struct {
+	start, end netip.Addr
+	country    string
+}
+
+// LoadGeoIP reads a GeoIP table from path; see ParseGeoIP.
+func LoadGeoIP(path string) (*GeoIP, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseGeoIP(f)
+}
+
+// ParseGeoIP reads CSV lines of the form "network,country", where
+// network is an IPv4 or IPv6 CIDR prefix and country an ISO 3166-1
+// alpha-2 code (e.g. "203.0.113.0/24,PH").  Blank lines, lines starting
+// with '#' and a leading header line are ignored.
+func ParseGeoIP(r io.Reader) (*GeoIP, error) {
+	g := &GeoIP{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		network, country, ok := strings.Cut(text, ",")
+		if !ok {
+			return nil, fmt.Errorf("geoip line %d: expected network,country", line)
+		}
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(network))
+		if err != nil {
+			if line == 1 {
+				continue // header
+			}
+			return nil, fmt.Errorf("geoip line %d: %w", line, err)
+		}
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if !countryPattern.MatchString(country) {
+			return nil, fmt.Errorf("geoip line %d: invalid country %q", line, country)
+		}
+		prefix = prefix.Masked()
+		g.ranges = append(g.ranges, geoRange{
+			start:   prefix.Addr(),
+			end:     lastAddr(prefix),
+			country: country,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(g.ranges, func(i, j int) bool { return g.ranges[i].start.Less(g.ranges[j].start) })
+	return g, nil
+}
+
+// Country returns the country of ip, or "" when it is not listed.
+func (g *GeoIP) Country(ip net.IP) string {
+	if g == nil || ip == nil {
+		return ""
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return ""
+	}
+	addr = addr.Unmap()
+	// Find the last range starting at or before addr
+	i := sort.Search(len(g.ranges), func(i int) bool { return addr.Less(g.ranges[i].start) }) - 1
+	if i < 0 {
+		return ""
+	}
+	r := g.ranges[i]
+	if addr.BitLen() != r.start.BitLen() || r.end.Less(addr) {
+		return ""
+	}
+	return r.country
+}
+
+// lastAddr returns the highest address inside prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(b)*8; bit++ {
+		b[bit/8] |= 0x80 >> (bit % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
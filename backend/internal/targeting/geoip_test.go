@@ -0,0 +1,57 @@
+package targeting
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+const testGeoIP = `network,country
+# documentation ranges
+192.0.2.0/24,US
+198.51.100.0/25,sg
+198.51.100.128/25,PH
+2001:db8::/32,JP
+`
+
+func TestGeoIPCountry(t *testing.T) {
+	geo, err := ParseGeoIP(strings.NewReader(testGeoIP))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"192.0.2.1", "US"},
+		{"192.0.2.255", "US"},
+		{"192.0.3.0", ""},
+		{"198.51.100.127", "SG"},
+		{"198.51.100.128", "PH"},
+		{"::ffff:198.51.100.200", "PH"},
+		{"2001:db8::1", "JP"},
+		{"2001:db9::1", ""},
+		{"10.0.0.1", ""},
+	}
+	for _, tt := range tests {
+		if got := geo.Country(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("Country(%s) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+	var nilGeo *GeoIP
+	if nilGeo.Country(net.ParseIP("192.0.2.1")) != "" {
+		t.Error("expected nil GeoIP to return no country")
+	}
+}
+
+func TestParseGeoIPErrors(t *testing.T) {
+	for _, input := range []string{
+		"192.0.2.0/24,US\nnot-a-network,US\n",
+		"192.0.2.0/24,USA\n",
+		"192.0.2.0/24\n",
+	} {
+		if _, err := ParseGeoIP(strings.NewReader(input)); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Package targeting matches visitors against the ordered targeting
+// rules of a short link.  A Visitor is derived from the request's
+// User-Agent and Accept-Language headers, the client address (looked
+// up in an optional local GeoIP table) and the current time.
+package targeting
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+// Operating systems reported by ParseUserAgent.
+const (
+	OSIOS      = "ios"
+	OSAndroid  = "android"
+	OSWindows  = "windows"
+	OSMacOS    = "macos"
+	OSLinux    = "linux"
+	OSChromeOS = "chromeos"
+)
+
+// Device types reported by ParseUserAgent.
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+)
+
+// MaxRules caps the number of rules on a link.
+const MaxRules = 20
+
+var (
+	knownOS      = []string{OSIOS, OSAndroid, OSWindows, OSMacOS, OSLinux, OSChromeOS}
+	knownDevices = []string{DeviceMobile, DeviceTablet, DeviceDesktop}
+
+	countryPattern  = regexp.MustCompile(`^[A-Z]{2}$`)
+	languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+)
+
+// Visitor holds the request attributes rules are matched against.
+// Empty fields are unknown and never match a condition on them.
+type Visitor struct {
+	OS       string
+	Device   string
+	Country  string
+	Language string // preferred language, lowercase BCP 47 tag
+	Time     time.Time
+}
+
+// FromRequest describes the visitor making r at now.  geo may be nil,
+// in which case the country is unknown.
+func FromRequest(r *http.Request, geo *GeoIP, now time.Time) Visitor {
+	os, device := ParseUserAgent(r.UserAgent())
+	v := Visitor{OS: os, Device: device, Time: now}
+	if langs := ParseAcceptLanguage(r.Header.Get("Accept-Language")); len(langs) > 0 {
+		v.Language = langs[0]
+	}
+	if geo != nil {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		v.Country = geo.Country(net.ParseIP(host))
+	}
+	return v
+}
+
+// Match returns the index of the first rule v satisfies, or -1.
+func Match(rules []models.TargetingRule, v Visitor) int {
+	for i := range rules {
+		if Matches(rules[i], v) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Matches reports whether v satisfies every condition of rule.
+func Matches(rule models.TargetingRule, v Visitor) bool {
+	if len(rule.OS) > 0 && !contains(rule.OS, v.OS) {
+		return false
+	}
+	if len(rule.Devices) > 0 && !contains(rule.Devices, v.Device) {
+		return false
+	}
+	if len(rule.Countries) > 0 && !contains(rule.Countries, v.Country) {
+		return false
+	}
+	if len(rule.Languages) > 0 && !matchLanguage(rule.Languages, v.Language) {
+		return false
+	}
+	if rule.TimeOfDay != nil && !inWindow(*rule.TimeOfDay, v.Time) {
+		return false
+	}
+	return true
+}
+
+// Normalize canonicalises the case of a rule's conditions and checks
+// that every value is supported.  The destination URL is left to the
+// caller to validate.
+func Normalize(rule models.TargetingRule) (models.TargetingRule, error) {
+	rule.OS = lowerAll(rule.OS)
+	for _, os := range rule.OS {
+		if !contains(knownOS, os) {
+			return rule, fmt.Errorf("unsupported os %q", os)
+		}
+	}
+	rule.Devices = lowerAll(rule.Devices)
+	for _, d := range rule.Devices {
+		if !contains(knownDevices, d) {
+			return rule, fmt.Errorf("unsupported device %q", d)
+		}
+	}
+	for i, c := range rule.Countries {
+		c = strings.ToUpper(strings.TrimSpace(c))
+		if !countryPattern.MatchString(c) {
+			return rule, fmt.Errorf("invalid country code %q", c)
+		}
+		rule.Countries[i] = c
+	}
+	rule.Languages = lowerAll(rule.Languages)
+	for _, l := range rule.Languages {
+		if !languagePattern.MatchString(l) {
+			return rule, fmt.Errorf("invalid language tag %q", l)
+		}
+	}
+	if w := rule.TimeOfDay; w != nil {
+		if _, err := parseClock(w.Start); err != nil {
+			return rule, err
+		}
+		if _, err := parseClock(w.End); err != nil {
+			return rule, err
+		}
+		if w.Start == w.End {
+			return rule, errors.New("time window start and end must differ")
+		}
+		if _, err := time.LoadLocation(w.Timezone); err != nil {
+			return rule, fmt.Errorf("unknown timezone %q", w.Timezone)
+		}
+	}
+	if len(rule.OS) == 0 && len(rule.Devices) == 0 && len(rule.Countries) == 0 &&
+		len(rule.Languages) == 0 && rule.TimeOfDay == nil {
+		return rule, errors.New("rule must have at least one condition")
+	}
+	return rule, nil
+}
+
+// matchLanguage reports whether the visitor's preferred language lang
+// matches one of tags, either exactly or as a more specific tag of the
+// same language ("en" matches "en-gb", "en-gb" does not match "en").
+func matchLanguage(tags []string, lang string) bool {
+	if lang == "" {
+		return false
+	}
+	for _, t := range tags {
+		if lang == t || strings.HasPrefix(lang, t+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// inWindow reports whether t falls inside the daily window w.  Invalid
+// windows never match.
+func inWindow(w models.TimeWindow, t time.Time) bool {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return false
+	}
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return start <= minute && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// parseClock converts "HH:MM" to minutes after midnight.
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	if ok && len(h) == 2 && len(m) == 2 {
+		hour, errH := strconv.Atoi(h)
+		minute, errM := strconv.Atoi(m)
+		if errH == nil && errM == nil && hour >= 0 && hour < 24 && minute >= 0 && minute < 60 {
+			return hour*60 + minute, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+}
+
+func lowerAll(values []string) []string {
+	for i, v := range values {
+		values[i] = strings.ToLower(strings.TrimSpace(v))
+	}
+	return values
+}
+
+func contains(list []string, s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
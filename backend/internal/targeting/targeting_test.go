@@ -0,0 +1,155 @@
+package targeting
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/richmondwang/symph-url-shortener/internal/models"
+)
+
+func TestMatchFirstRuleWins(t *testing.T) {
+	rules := []models.TargetingRule{
+		{OS: []string{OSIOS}, URL: "https://apps.apple.com/app/id1"},
+		{OS: []string{OSAndroid}, URL: "https://play.google.com/store/apps/details?id=x"},
+		{Devices: []string{DeviceMobile}, URL: "https://m.example.com"},
+	}
+	tests := []struct {
+		name    string
+		visitor Visitor
+		want    int
+	}{
+		{"ios", Visitor{OS: OSIOS, Device: DeviceMobile}, 0},
+		{"android", Visitor{OS: OSAndroid, Device: DeviceMobile}, 1},
+		{"other mobile", Visitor{OS: OSWindows, Device: DeviceMobile}, 2},
+		{"desktop", Visitor{OS: OSMacOS, Device: DeviceDesktop}, -1},
+		{"unknown", Visitor{}, -1},
+	}
+	for _, tt := range tests {
+		if got := Match(rules, tt.visitor); got != tt.want {
+			t.Errorf("%s: expected rule %d, got %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestMatchesAllConditions(t *testing.T) {
+	rule := models.TargetingRule{
+		Countries: []string{"PH", "SG"},
+		Languages: []string{"en"},
+	}
+	if !Matches(rule, Visitor{Country: "PH", Language: "en-ph"}) {
+		t.Error("expected PH English visitor to match")
+	}
+	if Matches(rule, Visitor{Country: "PH", Language: "fil"}) {
+		t.Error("expected language mismatch")
+	}
+	if Matches(rule, Visitor{Country: "US", Language: "en"}) {
+		t.Error("expected country mismatch")
+	}
+	if Matches(rule, Visitor{Language: "en"}) {
+		t.Error("expected unknown country not to match")
+	}
+}
+
+func TestMatchLanguage(t *testing.T) {
+	tests := []struct {
+		tags []string
+		lang string
+		want bool
+	}{
+		{[]string{"en"}, "en", true},
+		{[]string{"en"}, "en-gb", true},
+		{[]string{"en-gb"}, "en", false},
+		{[]string{"en"}, "eng", false},
+		{[]string{"pt-br"}, "pt-br", true},
+		{[]string{"en"}, "", false},
+	}
+	for _, tt := range tests {
+		if got := matchLanguage(tt.tags, tt.lang); got != tt.want {
+			t.Errorf("matchLanguage(%v, %q) = %v, want %v", tt.tags, tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestTimeOfDay(t *testing.T) {
+	at := func(s string) time.Time {
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	office := models.TargetingRule{TimeOfDay: &models.TimeWindow{Start: "09:00", End: "17:00", Timezone: "Asia/Manila"}}
+	night := models.TargetingRule{TimeOfDay: &models.TimeWindow{Start: "22:00", End: "06:00"}}
+	tests := []struct {
+		name string
+		rule models.TargetingRule
+		at   string
+		want bool
+	}{
+		{"office open (01:00Z is 09:00 Manila)", office, "2025-01-01T01:00:00Z", true},
+		{"office closed at end", office, "2025-01-01T09:00:00Z", false},
+		{"office before open", office, "2025-01-01T00:59:00Z", false},
+		{"night late", night, "2025-01-01T23:30:00Z", true},
+		{"night early", night, "2025-01-01T05:59:00Z", true},
+		{"night daytime", night, "2025-01-01T12:00:00Z", false},
+	}
+	for _, tt := range tests {
+		if got := Matches(tt.rule, Visitor{Time: at(tt.at)}); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	rule, err := Normalize(models.TargetingRule{
+		OS:        []string{" iOS "},
+		Countries: []string{"ph"},
+		Languages: []string{"EN-GB"},
+		TimeOfDay: &models.TimeWindow{Start: "08:00", End: "12:30", Timezone: "Europe/London"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rule.OS[0] != "ios" || rule.Countries[0] != "PH" || rule.Languages[0] != "en-gb" {
+		t.Errorf("expected canonical case, got %+v", rule)
+	}
+
+	invalid := []models.TargetingRule{
+		{},
+		{OS: []string{"symbian"}},
+		{Devices: []string{"watch"}},
+		{Countries: []string{"PHL"}},
+		{Languages: []string{"english!"}},
+		{TimeOfDay: &models.TimeWindow{Start: "9:00", End: "17:00"}},
+		{TimeOfDay: &models.TimeWindow{Start: "09:00", End: "24:00"}},
+		{TimeOfDay: &models.TimeWindow{Start: "09:00", End: "09:00"}},
+		{TimeOfDay: &models.TimeWindow{Start: "09:00", End: "17:00", Timezone: "Mars/Base"}},
+	}
+	for i, r := range invalid {
+		if _, err := Normalize(r); err == nil {
+			t.Errorf("rule %d: expected validation error", i)
+		}
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	geo, err := ParseGeoIP(strings.NewReader("203.0.113.0/24,PH\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("GET", "/abc", nil)
+	r.RemoteAddr = "203.0.113.9:4321"
+	r.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15")
+	r.Header.Set("Accept-Language", "fil;q=0.8, en-PH")
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	v := FromRequest(r, geo, now)
+	want := Visitor{OS: OSIOS, Device: DeviceMobile, Country: "PH", Language: "en-ph", Time: now}
+	if v != want {
+		t.Errorf("expected %+v, got %+v", want, v)
+	}
+	if v := FromRequest(r, nil, now); v.Country != "" {
+		t.Errorf("expected unknown country without GeoIP, got %q", v.Country)
+	}
+}
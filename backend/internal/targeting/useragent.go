@@ -0,0 +1,75 @@
+package targeting
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseUserAgent derives the operating system and device type from a
+// User-Agent header.  Unrecognised agents return an empty OS; the
+// device defaults to desktop unless the agent is empty.
+func ParseUserAgent(ua string) (os, device string) {
+	if ua == "" {
+		return "", ""
+	}
+	switch {
+	case strings.Contains(ua, "iPad"):
+		return OSIOS, DeviceTablet
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPod"):
+		return OSIOS, DeviceMobile
+	case strings.Contains(ua, "Android"):
+		// Android tablets omit the "Mobile" token
+		if strings.Contains(ua, "Mobile") {
+			return OSAndroid, DeviceMobile
+		}
+		return OSAndroid, DeviceTablet
+	case strings.Contains(ua, "Windows Phone"):
+		return OSWindows, DeviceMobile
+	case strings.Contains(ua, "Windows"):
+		return OSWindows, DeviceDesktop
+	case strings.Contains(ua, "CrOS"):
+		return OSChromeOS, DeviceDesktop
+	case strings.Contains(ua, "Macintosh"), strings.Contains(ua, "Mac OS X"):
+		return OSMacOS, DeviceDesktop
+	case strings.Contains(ua, "Linux"):
+		return OSLinux, DeviceDesktop
+	}
+	return "", DeviceDesktop
+}
+
+// ParseAcceptLanguage returns the language tags of an Accept-Language
+// header, lowercased and ordered by preference.  Tags with q=0 and the
+// "*" wildcard are dropped.
+func ParseAcceptLanguage(header string) []string {
+	type lang struct {
+		tag string
+		q   float64
+	}
+	var langs []lang
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		langs = append(langs, lang{tag, q})
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	tags := make([]string, len(langs))
+	for i, l := range langs {
+		tags[i] = l.tag
+	}
+	return tags
+}
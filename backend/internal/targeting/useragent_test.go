@@ -0,0 +1,49 @@
+package targeting
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		ua     string
+		os     string
+		device string
+	}{
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148", OSIOS, DeviceMobile},
+		{"Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148", OSIOS, DeviceTablet},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36", OSAndroid, DeviceMobile},
+		{"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 Chrome/120.0 Safari/537.36", OSAndroid, DeviceTablet},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36", OSWindows, DeviceDesktop},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 Version/17.0 Safari/605.1.15", OSMacOS, DeviceDesktop},
+		{"Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 Chrome/120.0 Safari/537.36", OSChromeOS, DeviceDesktop},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0", OSLinux, DeviceDesktop},
+		{"curl/8.4.0", "", DeviceDesktop},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		os, device := ParseUserAgent(tt.ua)
+		if os != tt.os || device != tt.device {
+			t.Errorf("ParseUserAgent(%q) = %q, %q; want %q, %q", tt.ua, os, device, tt.os, tt.device)
+		}
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"en-US", []string{"en-us"}},
+		{"fr;q=0.5, de-DE, en;q=0.9", []string{"de-de", "en", "fr"}},
+		{"*, ja;q=0.1, es;q=0", []string{"ja"}},
+		{"en;q=abc, it", []string{"it"}},
+	}
+	for _, tt := range tests {
+		if got := ParseAcceptLanguage(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseAcceptLanguage(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Package urltemplate expands destination templates such as
+// "https://shop.example.com/p/{query.id}?ref={slug}".  Placeholders
+// may only appear after the host, and each value is escaped for the
+// URL component it lands in, so a template can never change the
+// scheme or host of the destination.
+//
+// Supported placeholders:
+//
+//	{slug}        the short link's slug
+//	{query.NAME}  the incoming query parameter NAME (empty if absent)
+//	{path}        the trailing path after /{slug}/
+//	{date}        the current UTC date as YYYY-MM-DD
+//	{country}     the visitor's ISO country code (empty if unknown)
+package urltemplate
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// component identifies the part of the URL a placeholder expands in.
+type component int
+
+const (
+	inPath component = iota
+	inQuery
+	inFragment
+)
+
+// segment is either a literal run of the template or a placeholder.
+type segment struct {
+	literal string
+	name    string // placeholder name; empty for literals
+	in      component
+}
+
+// Template is a parsed destination template.
+type Template struct {
+	segments []segment
+	usesPath bool
+}
+
+// Vars are the request values available to placeholders.
+type Vars struct {
+	Slug    string
+	Path    string
+	Query   url.Values
+	Now     time.Time
+	Country string
+}
+
+var queryNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,64}$`)
+
+// IsTemplate reports whether s contains placeholders.  Braces are not
+// valid unescaped URL characters, so any '{' marks a template.
+func IsTemplate(s string) bool {
+	return strings.ContainsAny(s, "{}")
+}
+
+// Parse parses and validates a template.  It rejects unknown or
+// malformed placeholders, placeholders in the scheme or host, and
+// templates that are not absolute http(s) URLs.
+func Parse(s string) (*Template, error) {
+	authorityEnd, err := checkPrefix(s)
+	if err != nil {
+		return nil, err
+	}
+	t := &Template{}
+	in := inPath
+	rest := s
+	offset := 0
+	for rest != "" {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			t.segments = append(t.segments, segment{literal: rest})
+			break
+		}
+		if rest[open] == '}' {
+			return nil, errors.New("unmatched '}' in URL template")
+		}
+		literal := rest[:open]
+		in = advance(in, literal)
+		if offset+open < authorityEnd {
+			return nil, errors.New("placeholders are not allowed in the scheme or host")
+		}
+		close := strings.IndexByte(rest[open:], '}')
+		if close < 0 {
+			return nil, errors.New("unterminated placeholder in URL template")
+		}
+		name := rest[open+1 : open+close]
+		if err := checkName(name); err != nil {
+			return nil, err
+		}
+		if name == "path" {
+			t.usesPath = true
+		}
+		if literal != "" {
+			t.segments = append(t.segments, segment{literal: literal})
+		}
+		t.segments = append(t.segments, segment{name: name, in: in})
+		rest = rest[open+close+1:]
+		offset += open + close + 1
+	}
+	return t, nil
+}
+
+// UsesPath reports whether the template consumes the trailing path.
+func (t *Template) UsesPath() bool {
+	return t.usesPath
+}
+
+// Expand substitutes vars into the template.  The result is checked
+// again to be an absolute http(s) URL.
+func (t *Template) Expand(vars Vars) (string, error) {
+	var b strings.Builder
+	for _, seg := range t.segments {
+		if seg.name == "" {
+			b.WriteString(seg.literal)
+			continue
+		}
+		b.WriteString(escape(value(seg.name, vars), seg))
+	}
+	out := b.String()
+	u, err := url.Parse(out)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("template expanded to an invalid URL")
+	}
+	return out, nil
+}
+
+// checkPrefix verifies that the template starts with a literal
+// "http://" or "https://" scheme and host, and returns the offset at
+// which the host ends.
+func checkPrefix(s string) (int, error) {
+	var scheme string
+	switch {
+	case strings.HasPrefix(s, "https://"):
+		scheme = "https://"
+	case strings.HasPrefix(s, "http://"):
+		scheme = "http://"
+	default:
+		return 0, errors.New("URL must start with http:// or https://")
+	}
+	end := len(s)
+	if i := strings.IndexAny(s[len(scheme):], "/?#"); i >= 0 {
+		end = len(scheme) + i
+	}
+	if end == len(scheme) {
+		return 0, errors.New("URL must have a valid host")
+	}
+	if strings.ContainsAny(s[:end], "{}") {
+		return 0, errors.New("placeholders are not allowed in the scheme or host")
+	}
+	// Check the template is a URL once placeholders are filled in
+	probe := strings.NewReplacer("{", "x", "}", "x").Replace(s)
+	u, err := url.Parse(probe)
+	if err != nil || u.Host == "" {
+		return 0, errors.New("invalid URL template")
+	}
+	return end, nil
+}
+
+// checkName validates a placeholder name.
+func checkName(name string) error {
+	switch name {
+	case "slug", "path", "date", "country":
+		return nil
+	}
+	if q, ok := strings.CutPrefix(name, "query."); ok && queryNamePattern.MatchString(q) {
+		return nil
+	}
+	return fmt.Errorf("unknown placeholder {%s}", name)
+}
+
+// advance tracks which URL component a literal run leaves us in.
+func advance(in component, literal string) component {
+	for i := 0; i < len(literal); i++ {
+		switch {
+		case literal[i] == '?' && in == inPath:
+			in = inQuery
+		case literal[i] == '#':
+			in = inFragment
+		}
+	}
+	return in
+}
+
+func value(name string, vars Vars) string {
+	switch name {
+	case "slug":
+		return vars.Slug
+	case "path":
+		return vars.Path
+	case "date":
+		return vars.Now.UTC().Format("2006-01-02")
+	case "country":
+		return vars.Country
+	}
+	return vars.Query.Get(strings.TrimPrefix(name, "query."))
+}
+
+// escape encodes v for the component of seg.  In the path, {path}
+// keeps its slashes but is cleaned of dot segments; every other value
+// becomes a single segment that cannot traverse upwards.
+func escape(v string, seg segment) string {
+	switch seg.in {
+	case inQuery:
+		return url.QueryEscape(v)
+	case inFragment:
+		return url.PathEscape(v)
+	}
+	if seg.name == "path" {
+		if v == "" {
+			return ""
+		}
+		cleaned := strings.TrimPrefix(path.Clean("/"+v), "/")
+		parts := strings.Split(cleaned, "/")
+		for i, p := range parts {
+			parts[i] = url.PathEscape(p)
+		}
+		return strings.Join(parts, "/")
+	}
+	if v == "." || v == ".." {
+		return strings.ReplaceAll(v, ".", "%2E")
+	}
+	return url.PathEscape(v)
+}
@@ -0,0 +1,96 @@
+package urltemplate
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestIsTemplate(t *testing.T) {
+	if IsTemplate("https://example.com/p?id=1") {
+		t.Error("expected plain URL not to be a template")
+	}
+	if !IsTemplate("https://example.com/p/{query.id}") {
+		t.Error("expected placeholder URL to be a template")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{
+		"{query.scheme}://example.com",
+		"javascript:{slug}",
+		"https://{query.host}/p",
+		"https://example.{country}/p",
+		"https://example.com{slug}",
+		"https://example.com/p/{unknown}",
+		"https://example.com/p/{query.}",
+		"https://example.com/p/{query.a b}",
+		"https://example.com/p/{slug",
+		"https://example.com/p/slug}",
+		"https:///{slug}",
+		"ftp://example.com/{slug}",
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	now := time.Date(2025, 3, 7, 23, 30, 0, 0, time.FixedZone("PHT", 8*3600))
+	vars := Vars{
+		Slug:    "product",
+		Path:    "shoes/red",
+		Query:   url.Values{"id": {"123"}, "q": {"a b&c=d"}, "evil": {"../admin?x=1#y"}},
+		Now:     now,
+		Country: "PH",
+	}
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"https://shop.example.com/p/{query.id}?ref={slug}", "https://shop.example.com/p/123?ref=product"},
+		{"https://example.com/search?q={query.q}", "https://example.com/search?q=a+b%26c%3Dd"},
+		{"https://example.com/{query.evil}", "https://example.com/..%2Fadmin%3Fx=1%23y"},
+		{"https://example.com/docs/{path}", "https://example.com/docs/shoes/red"},
+		{"https://example.com/{country}/{date}", "https://example.com/PH/2025-03-07"},
+		{"https://example.com/p?c={query.missing}", "https://example.com/p?c="},
+		{"https://example.com/p#{query.q}", "https://example.com/p#a%20b&c=d"},
+	}
+	for _, tt := range tests {
+		tmpl, err := Parse(tt.tmpl)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.tmpl, err)
+		}
+		got, err := tmpl.Expand(vars)
+		if err != nil {
+			t.Fatalf("Expand(%q): %v", tt.tmpl, err)
+		}
+		if got != tt.want {
+			t.Errorf("Expand(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestExpandPathCannotTraverse(t *testing.T) {
+	tmpl, err := Parse("https://example.com/docs/{path}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tmpl.Expand(Vars{Path: "../../etc/passwd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "https://example.com/docs/etc/passwd" {
+		t.Errorf("expected dot segments removed, got %q", got)
+	}
+	if !tmpl.UsesPath() {
+		t.Error("expected template to use {path}")
+	}
+
+	single, _ := Parse("https://example.com/docs/{query.p}/view")
+	got, _ = single.Expand(Vars{Query: url.Values{"p": {".."}}})
+	if got != "https://example.com/docs/%2E%2E/view" {
+		t.Errorf("expected escaped dot segment, got %q", got)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"crypto/rand"
 	"fmt"
 	"net/url"
+	"path"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -39,56 +42,282 @@ func GenerateSlug(n int) string {
 	return string(b)
 }
 
-// ComposeDestination appends non‑empty UTM parameters to the given base
-// URL.  Each key/value pair in utms results in a query parameter
-// named "utm_<key>".  If the base URL already contains a query
-// string (detected by the presence of '?'), additional parameters are
-// concatenated with '&'.  Keys and values are URL‑encoded via
-// url.QueryEscape.
+// ComposeDestination adds non-empty UTM parameters to the given base
+// URL, replacing any the base already carries.  See MergeUTMs.
 func ComposeDestination(base string, utms map[string]string) string {
-	// Trim whitespace from the base; avoid modifying input string
-	urlStr := base
-	// Collect non‑empty UTM values
-	pairs := make([]string, 0)
-	for k, v := range utms {
-		if v == "" {
+	return MergeUTMs(base, utms, false)
+}
+
+// utmOrder lists the standard UTM keys in the order they are written.
+// Other keys follow in alphabetical order.
+var utmOrder = []string{"source", "medium", "campaign", "term", "content"}
+
+// MergeUTMs merges non-empty UTM parameters into the query of base.
+// Each key/value pair in utms becomes a query parameter named
+// "utm_<key>" (keys already starting with "utm_" are used as is).
+// When base already has the parameter, keepExisting decides whether
+// its values are kept or the first is replaced in place; duplicates of
+// a replaced parameter are dropped.  New parameters are appended in a fixed
+// order, the rest of the query keeps its original order and encoding,
+// and any fragment stays at the end.
+func MergeUTMs(base string, utms map[string]string, keepExisting bool) string {
+	return MergeTracking(base, utms, nil, keepExisting)
+}
+
+// MergeTracking is MergeUTMs with custom tracking parameters: each
+// non-empty pair in params is merged under its own name after the UTMs,
+// in alphabetical order.
+func MergeTracking(base string, utms, params map[string]string, keepExisting bool) string {
+	pairs := trackingParams(utms, params)
+	if len(pairs) == 0 {
+		return base
+	}
+	prefix, query, fragment := splitURL(base)
+	return joinURL(prefix, mergeQuery(query, pairs, keepExisting), fragment)
+}
+
+// RemoveUTMs removes the UTM parameters of utms from dest where they
+// still have the given value, recovering the base of a destination
+// composed by MergeUTMs.
+func RemoveUTMs(dest string, utms map[string]string) string {
+	return RemoveTracking(dest, utms, nil)
+}
+
+// RemoveTracking is RemoveUTMs for destinations composed by
+// MergeTracking.
+func RemoveTracking(dest string, utms, params map[string]string) string {
+	pairs := trackingParams(utms, params)
+	if len(pairs) == 0 {
+		return dest
+	}
+	want := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		want[p[0]] = p[1]
+	}
+	prefix, query, fragment := splitURL(dest)
+	var kept []string
+	for _, seg := range splitQuery(query) {
+		if v, ok := want[seg.key]; ok && v == seg.value {
 			continue
 		}
-		// URL‑encode the value
-		pairs = append(pairs, fmt.Sprintf("utm_%s=%s", k, url.QueryEscape(v)))
+		kept = append(kept, seg.raw)
 	}
-	if len(pairs) == 0 {
-		return urlStr
+	return joinURL(prefix, strings.Join(kept, "&"), fragment)
+}
+
+// trackingParams returns the UTM parameters of utms followed by the
+// non-empty custom parameters of params in alphabetical order, as
+// name/value pairs.  A name is only listed once.
+func trackingParams(utms, params map[string]string) [][2]string {
+	pairs := utmParams(utms)
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if k != "" && v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		pairs = append(pairs, [2]string{k, params[k]})
+	}
+	seen := make(map[string]bool, len(pairs))
+	out := pairs[:0]
+	for _, p := range pairs {
+		if !seen[p[0]] {
+			seen[p[0]] = true
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// splitURL splits raw into the part before the query, the raw query
+// and the fragment including its "#".  Splitting by hand rather than
+// re-encoding a parsed URL leaves the path of destination templates
+// such as "/p/{query.id}" untouched.
+func splitURL(raw string) (prefix, query, fragment string) {
+	if i := strings.IndexByte(raw, '#'); i >= 0 {
+		raw, fragment = raw[:i], raw[i:]
 	}
-	sep := "?"
-	if hasQuery(urlStr) {
-		sep = "&"
+	prefix, query, _ = strings.Cut(raw, "?")
+	return prefix, query, fragment
+}
+
+// joinURL reassembles the parts returned by splitURL, omitting an
+// empty query.
+func joinURL(prefix, query, fragment string) string {
+	if query != "" {
+		prefix += "?" + query
 	}
-	return urlStr + sep + joinParams(pairs)
+	return prefix + fragment
 }
 
-// hasQuery checks whether the provided URL already contains a
-// query component by looking for '?' in the string.  This simple
-// heuristic suffices for the purposes of this application.
-func hasQuery(s string) bool {
-	for i := 0; i < len(s); i++ {
-		if s[i] == '?' {
-			return true
+// utmParams returns the non-empty UTM parameters of utms as name/value
+// pairs in utmOrder.
+func utmParams(utms map[string]string) [][2]string {
+	keys := make([]string, 0, len(utms))
+	for k, v := range utms {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	rank := func(k string) int {
+		for i, std := range utmOrder {
+			if strings.TrimPrefix(k, "utm_") == std {
+				return i
+			}
+		}
+		return len(utmOrder)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ri, rj := rank(keys[i]), rank(keys[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return keys[i] < keys[j]
+	})
+	params := make([][2]string, 0, len(keys))
+	for _, k := range keys {
+		name := k
+		if !strings.HasPrefix(name, "utm_") {
+			name = "utm_" + name
 		}
+		params = append(params, [2]string{name, utms[k]})
 	}
-	return false
+	return params
 }
 
-// joinParams concatenates a slice of "key=value" strings using '&'.
-func joinParams(params []string) string {
-	result := ""
+// querySegment is one "key=value" pair of a raw query, with its decoded
+// key and value.
+type querySegment struct {
+	raw, key, value string
+}
+
+// splitQuery splits a raw query into its segments, skipping empty ones.
+func splitQuery(raw string) []querySegment {
+	var segs []querySegment
+	for _, part := range strings.Split(raw, "&") {
+		if part == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(part, "=")
+		key, err := url.QueryUnescape(k)
+		if err != nil {
+			key = k
+		}
+		value, err := url.QueryUnescape(v)
+		if err != nil {
+			value = v
+		}
+		segs = append(segs, querySegment{raw: part, key: key, value: value})
+	}
+	return segs
+}
+
+// mergeQuery merges params into the raw query as described by
+// MergeUTMs.
+func mergeQuery(raw string, params [][2]string, keepExisting bool) string {
+	index := make(map[string]int, len(params))
+	for i, p := range params {
+		index[p[0]] = i
+	}
+	written := make([]bool, len(params))
+	var out []string
+	for _, seg := range splitQuery(raw) {
+		i, ok := index[seg.key]
+		switch {
+		case !ok, keepExisting:
+			out = append(out, seg.raw)
+		case !written[i]:
+			out = append(out, url.QueryEscape(params[i][0])+"="+url.QueryEscape(params[i][1]))
+		default:
+			// A duplicate of a parameter already written
+			continue
+		}
+		if ok {
+			written[i] = true
+		}
+	}
 	for i, p := range params {
-		if i > 0 {
-			result += "&"
+		if !written[i] {
+			out = append(out, url.QueryEscape(p[0])+"="+url.QueryEscape(p[1]))
+		}
+	}
+	return strings.Join(out, "&")
+}
+
+// ForwardDestination appends a trailing path and merges incoming query
+// parameters into the destination URL dest.  The tail is joined onto
+// the destination path; dot segments are cleaned so the tail cannot
+// climb above it.  When an incoming parameter already exists in dest,
+// the incoming value replaces it only if incomingWins is true;
+// otherwise the stored value is kept.  The fragment of dest is
+// preserved.
+func ForwardDestination(dest, tail string, incoming url.Values, incomingWins bool) (string, error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return "", err
+	}
+	if tail != "" {
+		// Clean the tail on its own, rooted, so ".." cannot escape
+		// the destination path; keep a trailing slash if present.
+		cleaned := strings.TrimPrefix(path.Clean("/"+tail), "/")
+		if cleaned != "" && strings.HasSuffix(tail, "/") {
+			cleaned += "/"
 		}
-		result += p
+		if cleaned != "" {
+			u = u.JoinPath(cleaned)
+		}
+	}
+	if len(incoming) > 0 {
+		q := u.Query()
+		for k, vs := range incoming {
+			if _, exists := q[k]; exists && !incomingWins {
+				continue
+			}
+			q[k] = vs
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.String(), nil
+}
+
+// NormalizeURL returns the key under which equivalent destination URLs
+// are matched: the scheme and host are lower-cased, the scheme's
+// default port is dropped, an empty path becomes "/" and query
+// parameters are sorted by name and then value.  The path and fragment
+// are otherwise kept as given.  Only absolute http(s) URLs are
+// accepted.
+func NormalizeURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("not an absolute http(s) URL: %q", raw)
+	}
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	if u.Path == "" && u.RawPath == "" {
+		u.Path = "/"
+	}
+	q := u.Query()
+	for _, vs := range q {
+		sort.Strings(vs)
 	}
-	return result
+	u.RawQuery = q.Encode()
+	u.ForceQuery = false
+	return u.String(), nil
 }
 
 // ParseExpiration parses an ISO‑8601 timestamp string into a
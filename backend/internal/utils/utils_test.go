@@ -1,6 +1,9 @@
 package utils
 
-import "testing"
+import (
+	"net/url"
+	"testing"
+)
 
 // TestGenerateSlug ensures the generated slug has the correct length
 // and consists solely of characters in the allowed set.
@@ -43,3 +46,99 @@ func TestComposeDestination(t *testing.T) {
 		t.Fatalf("unexpected destination: %s", dest)
 	}
 }
+
+// TestMergeUTMs verifies UTM parameters merge into existing queries
+// deterministically, respecting fragments and the keep policy.
+func TestMergeUTMs(t *testing.T) {
+	utms := map[string]string{"campaign": "spring", "source": "news letter", "medium": "email", "content": ""}
+	tests := []struct {
+		name string
+		base string
+		keep bool
+		want string
+	}{
+		{"ordered", "https://example.com/p", false, "https://example.com/p?utm_source=news+letter&utm_medium=email&utm_campaign=spring"},
+		{"fragment", "https://example.com/p#section", false, "https://example.com/p?utm_source=news+letter&utm_medium=email&utm_campaign=spring#section"},
+		{"override in place", "https://example.com/?a=1&utm_source=old&b=2&utm_source=dup", false, "https://example.com/?a=1&utm_source=news+letter&b=2&utm_medium=email&utm_campaign=spring"},
+		{"keep existing", "https://example.com/?utm_source=old&x=%2F", true, "https://example.com/?utm_source=old&x=%2F&utm_medium=email&utm_campaign=spring"},
+		{"template", "https://{country}.example.com/{path}?q=1#top", false, "https://{country}.example.com/{path}?q=1&utm_source=news+letter&utm_medium=email&utm_campaign=spring#top"},
+	}
+	for _, tt := range tests {
+		if got := MergeUTMs(tt.base, utms, tt.keep); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if got := MergeUTMs("https://example.com/?q=1", map[string]string{"utm_id": "7", "zeta": "z", "source": "s"}, false); got != "https://example.com/?q=1&utm_source=s&utm_id=7&utm_zeta=z" {
+		t.Errorf("unexpected custom key order: %s", got)
+	}
+}
+
+// TestRemoveUTMs verifies composed UTM parameters can be stripped
+// again without touching other parameters.
+func TestRemoveUTMs(t *testing.T) {
+	utms := map[string]string{"source": "news", "medium": "email"}
+	got := RemoveUTMs("https://example.com/?a=1&utm_source=news&utm_medium=other#top", utms)
+	if got != "https://example.com/?a=1&utm_medium=other#top" {
+		t.Errorf("unexpected base: %s", got)
+	}
+}
+
+// TestMergeTracking verifies custom parameters follow the UTMs and
+// duplicate names are written once.
+func TestMergeTracking(t *testing.T) {
+	utms := map[string]string{"source": "news", "utm_source": "dup"}
+	params := map[string]string{"ref": "spring", "cid": "42", "empty": ""}
+	got := MergeTracking("https://example.com/?ref=old#top", utms, params, false)
+	if got != "https://example.com/?ref=spring&utm_source=news&cid=42#top" {
+		t.Errorf("unexpected destination: %s", got)
+	}
+	if base := RemoveTracking(got, utms, params); base != "https://example.com/#top" {
+		t.Errorf("unexpected base: %s", base)
+	}
+}
+
+// TestForwardDestination verifies path and query forwarding onto a
+// stored destination.
+func TestForwardDestination(t *testing.T) {
+	incoming := url.Values{"ref": {"x"}, "utm_source": {"ads"}}
+	dest, err := ForwardDestination("https://docs.example.com/base?utm_source=mail#top", "guide/page", incoming, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dest != "https://docs.example.com/base/guide/page?ref=x&utm_source=mail#top" {
+		t.Fatalf("unexpected destination: %s", dest)
+	}
+	dest, _ = ForwardDestination("https://docs.example.com/base?utm_source=mail", "", incoming, true)
+	if dest != "https://docs.example.com/base?ref=x&utm_source=ads" {
+		t.Fatalf("unexpected destination: %s", dest)
+	}
+	// Dot segments in the tail cannot escape the destination path
+	dest, _ = ForwardDestination("https://docs.example.com/base/", "../../etc", nil, false)
+	if dest != "https://docs.example.com/base/etc" {
+		t.Fatalf("unexpected destination: %s", dest)
+	}
+}
+
+// TestNormalizeURL verifies equivalent URLs share a key.
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"HTTPS://Example.COM", "https://example.com/"},
+		{"https://example.com:443/a?b=2&a=1&b=1", "https://example.com/a?a=1&b=1&b=2"},
+		{"http://example.com:80/?", "http://example.com/"},
+		{"http://example.com:8080/Path#Top", "http://example.com:8080/Path#Top"},
+		{"https://[2001:DB8::1]:443/x", "https://[2001:db8::1]/x"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeURL(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizeURL(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"ftp://example.com", "/relative", "https://"} {
+		if _, err := NormalizeURL(bad); err == nil {
+			t.Errorf("NormalizeURL(%q): expected error", bad)
+		}
+	}
+}